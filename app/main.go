@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/zeekhoks/quiz-backend/routes"
 	"github.com/zeekhoks/quiz-backend/services"
 	"log"
 	"os"
+	"time"
 )
 
 func init() {
@@ -27,9 +29,21 @@ func init() {
 	} else {
 		log.Println("Connected to DB")
 	}
+
+	if err = services.EnsureAttemptIndex(context.Background()); err != nil {
+		log.Fatalln("Failed to create the attempt index:", err)
+	}
 }
 
 func main() {
+	if interval := os.Getenv("ITEM_ANALYSIS_INTERVAL"); interval != "" {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			log.Fatalln("ITEM_ANALYSIS_INTERVAL is not a valid duration")
+		}
+		services.StartItemAnalysisJob(duration)
+	}
+
 	router := routes.GetRouter()
 
 	err := router.Run(":" + os.Getenv("SERVER_PORT"))
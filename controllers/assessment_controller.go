@@ -0,0 +1,469 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type assessmentRequest struct {
+	Name             string                 `json:"name"`
+	Description      string                 `json:"description"`
+	Topic            string                 `json:"topic"`
+	Blueprint        *models.Blueprint      `json:"blueprint"`
+	NumQuestions     int                    `json:"num_questions"`
+	TimeLimitMinutes int                    `json:"time_limit_minutes"`
+	MaxAttempts      int                    `json:"max_attempts"`
+	CooldownMinutes  int                    `json:"cooldown_minutes"`
+	AttemptScoring   string                 `json:"attempt_scoring"`
+	OpensAt          *time.Time             `json:"opens_at"`
+	ClosesAt         *time.Time             `json:"closes_at"`
+	PassingScore     float64                `json:"passing_score"`
+	Scoring          models.ScoringConfig   `json:"scoring"`
+	FeedbackMode     string                 `json:"feedback_mode"`
+	Adaptive         *models.AdaptiveConfig `json:"adaptive"`
+	ClassIds         []primitive.ObjectID   `json:"class_ids"`
+}
+
+func CreateAssessmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var body assessmentRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		if errs := validateAssessmentRequest(&body); len(errs) != 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": errs,
+			})
+			return
+		}
+		if err := services.CheckClassesExist(context, body.ClassIds); err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		now := time.Now()
+		assessment := models.Assessment{
+			CreatedBy: user.Username,
+			CreatedAt: now,
+		}
+		applyAssessmentRequest(&assessment, &body)
+		assessment.UpdatedAt = now
+
+		DB := services.GetConnection()
+		assessmentCollection := services.GetCollection(DB, "assessments")
+
+		res, err := assessmentCollection.InsertOne(context, assessment)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		assessment.ID = res.InsertedID.(primitive.ObjectID)
+
+		context.JSON(http.StatusCreated, gin.H{
+			"assessment": assessment,
+		})
+	}
+}
+
+func GetAssessmentsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		filter := bson.M{}
+		if !user.IsAdmin {
+			classIds, err := services.MemberClassIds(context, user.Username)
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Server error. Please try again later",
+				})
+				return
+			}
+			filter = services.StudentAssessmentFilter(time.Now(), classIds)
+		}
+		if topic := context.Query("topic"); topic != "" {
+			filter["topic"] = topic
+		}
+
+		DB := services.GetConnection()
+		assessmentCollection := services.GetCollection(DB, "assessments")
+
+		cursor, err := assessmentCollection.Find(context, filter)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		assessments := make([]models.Assessment, 0)
+		if err = cursor.All(context, &assessments); err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"assessments": assessments,
+		})
+	}
+}
+
+func GetAssessmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		assessment, ok := findAssessmentFromParam(context)
+		if !ok {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !user.IsAdmin {
+			classIds, err := services.MemberClassIds(context, user.Username)
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Server error. Please try again later",
+				})
+				return
+			}
+			if !assessment.IsOpen(time.Now()) || !services.CanTakeAssessment(assessment, classIds) {
+				context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "Assessment with given ID not found",
+				})
+				return
+			}
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"assessment": assessment,
+		})
+	}
+}
+
+func UpdateAssessmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		assessment, ok := findAssessmentFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body assessmentRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		if errs := validateAssessmentRequest(&body); len(errs) != 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": errs,
+			})
+			return
+		}
+		if err := services.CheckClassesExist(context, body.ClassIds); err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		applyAssessmentRequest(&assessment, &body)
+		assessment.UpdatedAt = time.Now()
+
+		DB := services.GetConnection()
+		assessmentCollection := services.GetCollection(DB, "assessments")
+
+		_, err := assessmentCollection.ReplaceOne(context, bson.M{"_id": assessment.ID}, assessment)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"assessment": assessment,
+		})
+	}
+}
+
+func DeleteAssessmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		assessment, ok := findAssessmentFromParam(context)
+		if !ok {
+			return
+		}
+
+		DB := services.GetConnection()
+		assessmentCollection := services.GetCollection(DB, "assessments")
+		quizCollection := services.GetCollection(DB, "quizzes")
+
+		attempts, err := quizCollection.CountDocuments(context, bson.M{"assessment_id": assessment.ID})
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if attempts > 0 {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Assessment already has attempts and cannot be deleted. Close it instead",
+			})
+			return
+		}
+
+		assignments, err := services.GetCollection(DB, "assignments").CountDocuments(context, bson.M{"assessment_id": assessment.ID})
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if assignments > 0 {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Assessment is assigned to a class. Remove the assignment first",
+			})
+			return
+		}
+
+		_, err = assessmentCollection.DeleteOne(context, bson.M{"_id": assessment.ID})
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func GetAssessmentScoreHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		assessment, ok := findAssessmentFromParam(context)
+		if !ok {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		username := user.Username
+		if requested := context.Query("username"); requested != "" && requested != user.Username {
+			if !authorizeStudentView(context, user, requested, assessment.ID, "You don't have permissions to view this user's score") {
+				return
+			}
+			username = requested
+		}
+
+		attempts, err := services.GetAttempts(context, assessment.ID, username)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		now := time.Now()
+		summaries := make([]gin.H, 0, len(attempts))
+		for _, attempt := range attempts {
+			finished := attempt.Completed || attempt.Remaining(now) <= 0
+			summary := gin.H{
+				"quiz_id":    attempt.Id,
+				"attempt":    attempt.Attempt,
+				"start_time": attempt.StartTime,
+				"finished":   finished,
+			}
+			if finished {
+				summary["percentage"] = fmt.Sprintf("%.2f", services.QuizPercentage(attempt))
+			}
+			summaries = append(summaries, summary)
+		}
+
+		response := gin.H{
+			"assessment_id":   assessment.ID,
+			"username":        username,
+			"attempt_scoring": assessment.AttemptScoring,
+			"attempts":        summaries,
+		}
+		if score, ok := services.CountedScore(assessment, attempts, now); ok {
+			response["counted_score"] = fmt.Sprintf("%.2f", score)
+			response["passed"] = score >= assessment.PassingScore
+		}
+
+		context.JSON(http.StatusOK, response)
+	}
+}
+
+func findAssessmentFromParam(context *gin.Context) (models.Assessment, bool) {
+	assessmentIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Assessment ID is in the wrong format",
+		})
+		return models.Assessment{}, false
+	}
+
+	assessment, err := services.GetAssessmentById(context, assessmentIdParsed)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Assessment with given ID not found",
+		})
+		return models.Assessment{}, false
+	}
+	return assessment, true
+}
+
+func validateAssessmentRequest(body *assessmentRequest) []string {
+	errorStrings := make([]string, 0)
+
+	body.Name = strings.TrimSpace(body.Name)
+	body.Topic = strings.TrimSpace(body.Topic)
+
+	if body.Name == "" {
+		errorStrings = append(errorStrings, "name should not be empty")
+	}
+	if body.Blueprint != nil && len(body.Blueprint.Sections) != 0 {
+		errorStrings = append(errorStrings, validateBlueprint(body.Blueprint, body.Topic)...)
+		body.NumQuestions = body.Blueprint.TotalQuestions()
+		if body.Adaptive != nil && body.Adaptive.Enabled {
+			errorStrings = append(errorStrings, "adaptive assessments cannot use a blueprint")
+		}
+	} else {
+		body.Blueprint = nil
+		if body.Topic == "" {
+			errorStrings = append(errorStrings, "topic should not be empty")
+		}
+	}
+	if body.NumQuestions < 1 {
+		errorStrings = append(errorStrings, "num_questions should be at least 1")
+	}
+	if body.TimeLimitMinutes < 0 {
+		errorStrings = append(errorStrings, "time_limit_minutes should not be negative")
+	}
+	if body.MaxAttempts < 0 {
+		errorStrings = append(errorStrings, "max_attempts should not be negative")
+	}
+	if body.CooldownMinutes < 0 {
+		errorStrings = append(errorStrings, "cooldown_minutes should not be negative")
+	}
+	body.AttemptScoring = strings.ToLower(strings.TrimSpace(body.AttemptScoring))
+	switch body.AttemptScoring {
+	case "":
+		body.AttemptScoring = models.AttemptScoringBest
+	case models.AttemptScoringBest, models.AttemptScoringLast, models.AttemptScoringAverage:
+	default:
+		errorStrings = append(errorStrings, "attempt_scoring should be one of best, last or average")
+	}
+	if body.PassingScore < 0 || body.PassingScore > 100 {
+		errorStrings = append(errorStrings, "passing_score should be between 0 and 100")
+	}
+	if body.Scoring.Policy == "" {
+		body.Scoring.Policy = models.ScoringPolicyPercentage
+	}
+	if !services.IsScoringPolicy(body.Scoring.Policy) {
+		errorStrings = append(errorStrings, fmt.Sprintf("scoring policy %v is not supported", body.Scoring.Policy))
+	}
+	if body.Scoring.NegativeMarking < 0 || body.Scoring.NegativeMarking > 1 {
+		errorStrings = append(errorStrings, "scoring negative_marking should be between 0 and 1")
+	}
+	body.FeedbackMode = strings.ToLower(strings.TrimSpace(body.FeedbackMode))
+	switch body.FeedbackMode {
+	case "":
+		body.FeedbackMode = models.FeedbackImmediate
+	case models.FeedbackImmediate, models.FeedbackOnCompletion, models.FeedbackNever:
+	case models.FeedbackAfterClose:
+		if body.ClosesAt == nil {
+			errorStrings = append(errorStrings, "closes_at is required when feedback_mode is after_close")
+		}
+	default:
+		errorStrings = append(errorStrings, "feedback_mode should be one of immediate, on_completion, after_close or never")
+	}
+	if body.Adaptive != nil && body.Adaptive.Enabled {
+		body.Adaptive.Model = strings.ToLower(strings.TrimSpace(body.Adaptive.Model))
+		if body.Adaptive.Model == "" {
+			body.Adaptive.Model = models.IRTModelRasch
+		}
+		if body.Adaptive.Model != models.IRTModelRasch && body.Adaptive.Model != models.IRTModel2PL {
+			errorStrings = append(errorStrings, "adaptive model should be one of rasch or 2pl")
+		}
+		if body.Adaptive.MaxQuestions == 0 {
+			body.Adaptive.MaxQuestions = body.NumQuestions
+		}
+		if body.Adaptive.TargetSE < 0 {
+			errorStrings = append(errorStrings, "adaptive target_standard_error should not be negative")
+		}
+		if body.Adaptive.MinQuestions < 0 || body.Adaptive.MinQuestions > body.Adaptive.MaxQuestions {
+			errorStrings = append(errorStrings, "adaptive min_questions should be between 0 and max_questions")
+		}
+	} else {
+		body.Adaptive = nil
+	}
+	if body.OpensAt != nil && body.ClosesAt != nil && !body.ClosesAt.After(*body.OpensAt) {
+		errorStrings = append(errorStrings, "closes_at should be after opens_at")
+	}
+
+	return errorStrings
+}
+
+func applyAssessmentRequest(assessment *models.Assessment, body *assessmentRequest) {
+	assessment.Name = body.Name
+	assessment.Description = body.Description
+	assessment.Topic = body.Topic
+	assessment.Blueprint = body.Blueprint
+	assessment.NumQuestions = body.NumQuestions
+	assessment.TimeLimitMinutes = body.TimeLimitMinutes
+	if assessment.TimeLimitMinutes == 0 {
+		assessment.TimeLimitMinutes = services.DefaultQuizDurationMinutes
+	}
+	assessment.MaxAttempts = body.MaxAttempts
+	assessment.CooldownMinutes = body.CooldownMinutes
+	assessment.AttemptScoring = body.AttemptScoring
+	assessment.OpensAt = body.OpensAt
+	assessment.ClosesAt = body.ClosesAt
+	assessment.PassingScore = body.PassingScore
+	assessment.Scoring = body.Scoring
+	assessment.FeedbackMode = body.FeedbackMode
+	assessment.Adaptive = body.Adaptive
+	assessment.ClassIds = body.ClassIds
+}
+
+func validateBlueprint(blueprint *models.Blueprint, topic string) []string {
+	errorStrings := make([]string, 0)
+
+	for i := range blueprint.Sections {
+		section := &blueprint.Sections[i]
+		section.Name = strings.TrimSpace(section.Name)
+		section.Topic = strings.TrimSpace(section.Topic)
+		section.Difficulty = strings.ToLower(strings.TrimSpace(section.Difficulty))
+		section.Tags = services.NormalizeTags(section.Tags)
+
+		if section.Count < 1 {
+			errorStrings = append(errorStrings, fmt.Sprintf("blueprint section %d count should be at least 1", i+1))
+		}
+		if section.Difficulty != "" && !models.IsDifficulty(section.Difficulty) {
+			errorStrings = append(errorStrings, fmt.Sprintf("blueprint section %d difficulty should be one of easy, medium or hard", i+1))
+		}
+		if section.Topic == "" && topic == "" && len(section.Tags) == 0 {
+			errorStrings = append(errorStrings, fmt.Sprintf("blueprint section %d should have a topic or tags", i+1))
+		}
+	}
+
+	return errorStrings
+}
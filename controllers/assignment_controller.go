@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"time"
+)
+
+type assignmentRequest struct {
+	AssessmentId primitive.ObjectID `json:"assessment_id"`
+	OpensAt      *time.Time         `json:"opens_at"`
+	DueAt        time.Time          `json:"due_at"`
+	LatePolicy   models.LatePolicy  `json:"late_policy"`
+}
+
+// CreateAssignmentHandler assigns an assessment to a class with a due date
+// and late policy.
+func CreateAssignmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		var body assignmentRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		now := time.Now()
+		assignment := models.Assignment{
+			ClassId:      class.ID,
+			AssessmentId: body.AssessmentId,
+			OpensAt:      body.OpensAt,
+			DueAt:        body.DueAt,
+			LatePolicy:   body.LatePolicy,
+			CreatedBy:    user.Username,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if errs := services.ValidateAssignment(assignment); len(errs) != 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": errs,
+			})
+			return
+		}
+
+		assessment, err := services.GetAssessmentById(context, body.AssessmentId)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Assessment with given ID not found",
+			})
+			return
+		}
+
+		if err = services.CheckAssignable(assignment, assessment, user); err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		assignment, err = services.CreateAssignment(context, assignment)
+		if err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusCreated, gin.H{
+			"assignment": assignment,
+		})
+	}
+}
+
+// GetAssignmentsHandler lists the assignments of a class to its instructors
+// and students.
+func GetAssignmentsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findClassFromParam(context)
+		if !ok {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !class.CanManage(user) && !class.HasStudent(user.Username) {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Class with given ID not found",
+			})
+			return
+		}
+
+		assignments, err := services.GetClassAssignments(context, class.ID)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"assignments": assignments,
+		})
+	}
+}
+
+// UpdateAssignmentHandler changes the dates or late policy of an
+// assignment. Attempts already started keep the ones they started with.
+func UpdateAssignmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+		assignment, ok := findClassAssignment(context, class)
+		if !ok {
+			return
+		}
+
+		var body assignmentRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		assignment.OpensAt = body.OpensAt
+		assignment.DueAt = body.DueAt
+		assignment.LatePolicy = body.LatePolicy
+		assignment.UpdatedAt = time.Now()
+		if errs := services.ValidateAssignment(assignment); len(errs) != 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": errs,
+			})
+			return
+		}
+
+		assessment, err := services.GetAssessmentById(context, assignment.AssessmentId)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Assessment with given ID not found",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		if err = services.CheckAssignable(assignment, assessment, userAny.(models.User)); err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		if err = services.UpdateAssignment(context, assignment); err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"assignment": assignment,
+		})
+	}
+}
+
+// DeleteAssignmentHandler removes an assignment nobody has started.
+func DeleteAssignmentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+		assignment, ok := findClassAssignment(context, class)
+		if !ok {
+			return
+		}
+
+		if err := services.DeleteAssignment(context, assignment); err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// GetAssignmentRosterHandler shows which students of the class have not
+// started, are working on or have completed an assignment. The status query
+// parameter limits the list to one of them.
+func GetAssignmentRosterHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+		assignment, ok := findClassAssignment(context, class)
+		if !ok {
+			return
+		}
+
+		status := context.Query("status")
+		if status != "" && !services.IsRosterStatus(status) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "status should be not_started, in_progress or completed",
+			})
+			return
+		}
+
+		assessment, err := services.GetAssessmentById(context, assignment.AssessmentId)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Assessment with given ID not found",
+			})
+			return
+		}
+
+		roster, err := services.GetAssignmentRoster(context, class, assignment, assessment, time.Now())
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		students := roster.Students
+		if status != "" {
+			students = make([]services.RosterEntry, 0)
+			for _, entry := range roster.Students {
+				if entry.Status == status {
+					students = append(students, entry)
+				}
+			}
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"assignment": assignment,
+			"students":   students,
+			"summary": gin.H{
+				services.RosterNotStarted: roster.NotStarted,
+				services.RosterInProgress: roster.InProgress,
+				services.RosterCompleted:  roster.Completed,
+			},
+		})
+	}
+}
+
+// findClassAssignment loads the assignment in the URL if it belongs to the
+// class.
+func findClassAssignment(context *gin.Context, class models.Class) (models.Assignment, bool) {
+	id, err := primitive.ObjectIDFromHex(context.Param("assignment_id"))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Assignment ID is in the wrong format",
+		})
+		return models.Assignment{}, false
+	}
+
+	assignment, err := services.GetAssignmentById(context, id)
+	if err != nil || assignment.ClassId != class.ID {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Assignment with given ID not found",
+		})
+		return models.Assignment{}, false
+	}
+	return assignment, true
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAssignmentHandlers(t *testing.T) {
+	router := gin.Default()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("loggedInAccount", models.User{Username: "jane_doe", IsAdmin: true})
+	})
+	router.POST("/classes/:id/assignments", CreateAssignmentHandler())
+	router.GET("/classes/:id/assignments", GetAssignmentsHandler())
+	router.PUT("/classes/:id/assignments/:assignment_id", UpdateAssignmentHandler())
+	router.DELETE("/classes/:id/assignments/:assignment_id", DeleteAssignmentHandler())
+	router.GET("/classes/:id/assignments/:assignment_id/roster", GetAssignmentRosterHandler())
+
+	// Test case: Invalid class ID
+	for _, request := range [][2]string{
+		{"POST", "/classes/invalid/assignments"},
+		{"GET", "/classes/invalid/assignments"},
+		{"PUT", "/classes/invalid/assignments/invalid"},
+		{"DELETE", "/classes/invalid/assignments/invalid"},
+		{"GET", "/classes/invalid/assignments/invalid/roster"},
+	} {
+		req, _ := http.NewRequest(request[0], request[1], nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code, request[1])
+	}
+}
@@ -0,0 +1,480 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type classRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CreateClassHandler creates a class taught by the instructor who creates
+// it.
+func CreateClassHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		body, ok := bindClassRequest(context)
+		if !ok {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		now := time.Now()
+		class, err := services.CreateClass(context, models.Class{
+			Name:        body.Name,
+			Description: body.Description,
+			Instructors: []string{user.Username},
+			Students:    make([]string, 0),
+			CreatedBy:   user.Username,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusCreated, gin.H{
+			"class": class,
+		})
+	}
+}
+
+// GetClassesHandler lists the classes a user teaches or is enrolled in, or
+// every class for admins.
+func GetClassesHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		classes, err := services.GetClasses(context, user)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		views := make([]interface{}, 0, len(classes))
+		for _, class := range classes {
+			views = append(views, classView(user, class))
+		}
+		context.JSON(http.StatusOK, gin.H{
+			"classes": views,
+		})
+	}
+}
+
+func GetClassHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findClassFromParam(context)
+		if !ok {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !class.CanManage(user) && !class.HasStudent(user.Username) {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Class with given ID not found",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"class": classView(user, class),
+		})
+	}
+}
+
+func UpdateClassHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		body, ok := bindClassRequest(context)
+		if !ok {
+			return
+		}
+
+		class.Name = body.Name
+		class.Description = body.Description
+		class.UpdatedAt = time.Now()
+		if err := services.UpdateClass(context, class); err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"class": class,
+		})
+	}
+}
+
+// DeleteClassHandler removes a class that no assessment is assigned to.
+func DeleteClassHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		if err := services.DeleteClass(context, class); err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// SetClassInstructorsHandler replaces the instructors of a class.
+func SetClassInstructorsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findClassFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Instructors []string `json:"instructors"`
+		}
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		instructors, err := services.SetClassInstructors(context, class, body.Instructors)
+		if err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"class_id":    class.ID,
+			"instructors": instructors,
+		})
+	}
+}
+
+// EnrollStudentsHandler adds students to a class, either listed in a JSON
+// body or as a CSV file in the students_file form field.
+func EnrollStudentsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		var usernames []string
+		if context.ContentType() == "multipart/form-data" {
+			fileHeader, err := context.FormFile("students_file")
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "students_file should be a CSV file",
+				})
+				return
+			}
+			file, err := fileHeader.Open()
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "students_file should be a CSV file",
+				})
+				return
+			}
+			defer file.Close()
+			if usernames, err = services.ParseRosterCSV(file); err != nil {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "CSV file is invalid",
+				})
+				return
+			}
+		} else {
+			var body struct {
+				Students []string `json:"students"`
+			}
+			if err := context.ShouldBindJSON(&body); err != nil {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "JSON is invalid",
+				})
+				return
+			}
+			usernames = body.Students
+		}
+
+		if len(usernames) == 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "students should not be empty",
+			})
+			return
+		}
+
+		enrollment, err := services.EnrollStudents(context, class, usernames)
+		if err != nil {
+			log.Println("Failed to enroll students", err)
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"class_id":   class.ID,
+			"enrollment": enrollment,
+		})
+	}
+}
+
+func UnenrollStudentHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		found, err := services.UnenrollStudent(context, class, context.Param("username"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if !found {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Student is not enrolled in this class",
+			})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// ResetJoinCodeHandler gives a class a new join code, turning joining by
+// code back on if it was disabled.
+func ResetJoinCodeHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		code, err := services.ResetJoinCode(context, class)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"class_id":  class.ID,
+			"join_code": code,
+		})
+	}
+}
+
+func DisableJoinCodeHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		class, ok := findManagedClass(context)
+		if !ok {
+			return
+		}
+
+		if err := services.DisableJoinCode(context, class); err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+// JoinClassHandler enrolls the logged-in user in the class with the given
+// join code.
+func JoinClassHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		class, err := services.JoinClass(context, body.Code, user.Username)
+		if err != nil {
+			abortWithClassError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"class": classView(user, class),
+		})
+	}
+}
+
+// classView hides the roster and join code from students.
+func classView(user models.User, class models.Class) interface{} {
+	if class.CanManage(user) {
+		return class
+	}
+	view := gin.H{
+		"id":          class.ID,
+		"name":        class.Name,
+		"instructors": class.Instructors,
+	}
+	if class.Description != "" {
+		view["description"] = class.Description
+	}
+	return view
+}
+
+func bindClassRequest(context *gin.Context) (classRequest, bool) {
+	var body classRequest
+	if err := context.ShouldBindJSON(&body); err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "JSON is invalid",
+		})
+		return body, false
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	body.Description = strings.TrimSpace(body.Description)
+	if body.Name == "" {
+		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "name should not be empty",
+		})
+		return body, false
+	}
+	return body, true
+}
+
+func findClassFromParam(context *gin.Context) (models.Class, bool) {
+	id, err := primitive.ObjectIDFromHex(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Class ID is in the wrong format",
+		})
+		return models.Class{}, false
+	}
+
+	class, err := services.GetClassById(context, id)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Class with given ID not found",
+		})
+		return models.Class{}, false
+	}
+	return class, true
+}
+
+// findManagedClass loads the class in the URL if the logged-in user teaches
+// it or is an admin.
+func findManagedClass(context *gin.Context) (models.Class, bool) {
+	class, ok := findClassFromParam(context)
+	if !ok {
+		return class, false
+	}
+
+	userAny, _ := context.Get("loggedInAccount")
+	user := userAny.(models.User)
+
+	if !class.CanManage(user) {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "Only instructors of this class can make this request",
+		})
+		return class, false
+	}
+	return class, true
+}
+
+// authorizeStudentView lets the student, admins and the student's
+// instructors see the student's attempts. It answers the request itself with
+// message when the user may not.
+func authorizeStudentView(context *gin.Context, user models.User, student string, assessmentId primitive.ObjectID, message string) bool {
+	if user.Username == student {
+		return true
+	}
+	return authorizeInstructor(context, user, student, assessmentId, message)
+}
+
+// authorizeInstructor lets admins and the student's instructors through.
+// When the assessment is assigned to classes, only instructors of those
+// classes count.
+func authorizeInstructor(context *gin.Context, user models.User, student string, assessmentId primitive.ObjectID, message string) bool {
+	if user.IsAdmin {
+		return true
+	}
+	if !user.HasRole(models.RoleInstructor) || user.Username == student {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": message,
+		})
+		return false
+	}
+
+	// An assessment that has since been deleted no longer limits which
+	// classes count.
+	var classIds []primitive.ObjectID
+	if !assessmentId.IsZero() {
+		assessment, err := services.GetAssessmentById(context, assessmentId)
+		if err != nil && err != mongo.ErrNoDocuments {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return false
+		}
+		classIds = assessment.ClassIds
+	}
+	instructs, err := services.InstructsStudent(context, user.Username, student, classIds)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Server error. Please try again later",
+		})
+		return false
+	}
+	if !instructs {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": message,
+		})
+		return false
+	}
+	return true
+}
+
+func abortWithClassError(context *gin.Context, err error) {
+	if classErr, ok := err.(*services.ClassError); ok {
+		context.AbortWithStatusJSON(classErr.Status, gin.H{
+			"error": classErr.Message,
+		})
+		return
+	}
+	context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"error": "Server error. Please try again later",
+	})
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClassHandlers(t *testing.T) {
+	router := gin.Default()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("loggedInAccount", models.User{Username: "jane_doe", IsAdmin: true})
+	})
+	router.POST("/classes", CreateClassHandler())
+	router.POST("/classes/join", JoinClassHandler())
+	router.GET("/classes/:id", GetClassHandler())
+	router.PUT("/classes/:id", UpdateClassHandler())
+	router.DELETE("/classes/:id", DeleteClassHandler())
+	router.POST("/classes/:id/students", EnrollStudentsHandler())
+
+	// Test case: Invalid class ID
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		req, _ := http.NewRequest(method, "/classes/invalid", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	}
+	req, _ := http.NewRequest("POST", "/classes/invalid/students", strings.NewReader(`{"students": ["john_doe"]}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Test case: A class needs a name
+	req, _ = http.NewRequest("POST", "/classes", strings.NewReader(`{"name": " "}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Test case: Joining needs a code
+	req, _ = http.NewRequest("POST", "/classes/join", strings.NewReader(`{"code": ""}`))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
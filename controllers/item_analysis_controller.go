@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"net/http"
+	"strings"
+)
+
+func RunItemAnalysisHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		results, err := services.RunItemAnalysis(context)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Unable to compute item statistics",
+			})
+			return
+		}
+
+		flagged := 0
+		for _, stats := range results {
+			if isFlagged(stats) {
+				flagged++
+			}
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"questions_analyzed": len(results),
+			"questions_flagged":  flagged,
+		})
+	}
+}
+
+func GetItemAnalysisHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		params := context.Request.URL.Query()
+		topic := params.Get("topic")
+		flaggedOnly := params.Get("flagged") == "true"
+
+		results, err := services.GetItemStatistics(context, topic, flaggedOnly)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		switch params.Get("format") {
+		case "", "json":
+			context.JSON(http.StatusOK, gin.H{
+				"topic":      topic,
+				"statistics": results,
+			})
+		case "csv":
+			filename := "item-analysis.csv"
+			if topic != "" {
+				filename = fmt.Sprintf("item-analysis-%s.csv", strings.ReplaceAll(topic, " ", "-"))
+			}
+			context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+			context.Header("Content-Type", "text/csv")
+			context.Status(http.StatusOK)
+			writeItemStatisticsCSV(csv.NewWriter(context.Writer), results)
+		default:
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Unsupported format. Use json or csv",
+			})
+		}
+	}
+}
+
+func isFlagged(stats models.ItemStatistics) bool {
+	for _, flag := range stats.Flags {
+		if flag != models.ItemFlagInsufficientData {
+			return true
+		}
+	}
+	return false
+}
+
+func writeItemStatisticsCSV(writer *csv.Writer, results []models.ItemStatistics) {
+	_ = writer.Write([]string{
+		"question_id", "topic", "question", "exposures", "responses", "omissions",
+		"p_value", "point_biserial", "option_rates", "non_functional_distractors", "flags",
+	})
+	for _, stats := range results {
+		rates := make([]string, 0, len(stats.OptionRates))
+		for option, rate := range stats.OptionRates {
+			rates = append(rates, fmt.Sprintf("%s=%.3f", option, rate))
+		}
+		_ = writer.Write([]string{
+			stats.QuestionId.Hex(),
+			stats.Topic,
+			stats.Question,
+			fmt.Sprint(stats.Exposures),
+			fmt.Sprint(stats.Responses),
+			fmt.Sprint(stats.Omissions),
+			fmt.Sprintf("%.3f", stats.PValue),
+			fmt.Sprintf("%.3f", stats.PointBiserial),
+			strings.Join(rates, "; "),
+			strings.Join(stats.NonFunctionalDistractors, "; "),
+			strings.Join(stats.Flags, "; "),
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func UploadMediaHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		file, err := context.FormFile("file")
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "File not provided",
+			})
+			return
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		defer f.Close()
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		media, err := services.SaveMedia(context, file.Filename, f, user.Username)
+		if err != nil {
+			if mediaErr, ok := err.(*services.MediaError); ok {
+				context.AbortWithStatusJSON(mediaErr.Status, gin.H{
+					"error": mediaErr.Message,
+				})
+				return
+			}
+			log.Println("Failed to save media", err)
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Unable to save media",
+			})
+			return
+		}
+
+		media.URL = services.SignMediaURL(media.ID, time.Now())
+		context.JSON(http.StatusCreated, gin.H{
+			"media": media,
+		})
+	}
+}
+
+func GetMediaHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		media, ok := findMediaFromParam(context)
+		if !ok {
+			return
+		}
+
+		etag := `"` + media.Checksum + `"`
+		maxAge := int64(time.Hour / time.Second)
+		if expires, err := strconv.ParseInt(context.Query("expires"), 10, 64); err == nil && expires > time.Now().Unix() {
+			maxAge = expires - time.Now().Unix()
+		}
+		context.Header("ETag", etag)
+		context.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+		if context.GetHeader("If-None-Match") == etag {
+			context.Status(http.StatusNotModified)
+			return
+		}
+
+		content, err := services.OpenMedia(context, media)
+		if err != nil {
+			log.Println("Failed to open media", err)
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Media content not found",
+			})
+			return
+		}
+		defer content.Close()
+
+		context.Header("X-Content-Type-Options", "nosniff")
+		context.DataFromReader(http.StatusOK, media.Size, media.ContentType, content, nil)
+	}
+}
+
+func GetMediaInfoHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		media, ok := findMediaFromParam(context)
+		if !ok {
+			return
+		}
+
+		media.URL = services.SignMediaURL(media.ID, time.Now())
+		context.JSON(http.StatusOK, gin.H{
+			"media": media,
+		})
+	}
+}
+
+func DeleteMediaHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		media, ok := findMediaFromParam(context)
+		if !ok {
+			return
+		}
+
+		inUse, err := services.MediaInUse(context, media.ID)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if inUse {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Media is used by a question or a past quiz and can't be deleted",
+			})
+			return
+		}
+
+		if err = services.DeleteMedia(context, media); err != nil {
+			log.Println("Failed to delete media", err)
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Unable to delete media",
+			})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func findMediaFromParam(context *gin.Context) (models.Media, bool) {
+	mediaIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Media ID is in the wrong format",
+		})
+		return models.Media{}, false
+	}
+
+	media, err := services.GetMediaById(context, mediaIdParsed)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Media with given ID not found",
+		})
+		return models.Media{}, false
+	}
+	return media, true
+}
+
+// checkMediaReferences rejects questions that reference media which was
+// never uploaded.
+func checkMediaReferences(context *gin.Context, questions []models.QuestionUnmarshal) bool {
+	missing, err := services.MissingMedia(context, questions)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Server error. Please try again later",
+		})
+		return false
+	}
+	if len(missing) != 0 {
+		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":         "Questions reference media that has not been uploaded",
+			"missing_media": missing,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetMediaHandler(t *testing.T) {
+	// Set up the router
+	router := gin.Default()
+	router.GET("/media/:id", GetMediaHandler())
+	router.DELETE("/media/:id", DeleteMediaHandler())
+
+	// Test case: Media ID in wrong format
+	req, _ := http.NewRequest("GET", "/media/invalidID", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req, _ = http.NewRequest("DELETE", "/media/invalidID", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
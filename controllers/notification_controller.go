@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+)
+
+func GetNotificationsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		notifications, err := services.GetNotifications(context, user.Username, context.Query("unread") == "true")
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"notifications": notifications,
+		})
+	}
+}
+
+func MarkNotificationReadHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		notificationIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Notification ID is in the wrong format",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		found, err := services.MarkNotificationRead(context, notificationIdParsed, user.Username)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if !found {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Notification with given ID not found",
+			})
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
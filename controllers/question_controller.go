@@ -8,8 +8,10 @@ import (
 	"github.com/zeekhoks/quiz-backend/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,8 +34,23 @@ func GetDisplayQuestionsByTopicHandler() gin.HandlerFunc {
 		//retrieve topic from param
 		topic := params.Get("topic")
 
+		status := params.Get("status")
+		if status != "" && !models.IsQuestionStatus(status) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "status should be draft, in_review, published, rejected or retired",
+			})
+			return
+		}
+
 		//MongoDB filter to search the question related to the `topic` param
 		filter := bson.M{"$text": bson.M{"$search": topic}}
+		if status != "" {
+			if status == models.QuestionStatusPublished {
+				filter["status"] = services.PublishedStatus()
+			} else {
+				filter["status"] = status
+			}
+		}
 		cursor, err := questionsCollection.Find(context, filter)
 		if err != nil {
 			return
@@ -51,6 +68,14 @@ func GetDisplayQuestionsByTopicHandler() gin.HandlerFunc {
 			return
 		}
 
+		now := time.Now()
+		for i := range questions {
+			services.SignMediaReferences(questions[i].Media, now)
+			if renderHTML(context) {
+				services.RenderQuestionDetail(&questions[i])
+			}
+		}
+
 		context.JSON(http.StatusOK, gin.H{
 			"questions": questions,
 		})
@@ -62,9 +87,14 @@ func UploadQuestionHandler() gin.HandlerFunc {
 
 		DB := services.GetConnection()
 		questionsCollection := services.GetCollection(DB, "questions")
-		topicsCollection := services.GetCollection(DB, "topics")
 
-		file, _ := c.FormFile("questions_file")
+		file, err := c.FormFile("questions_file")
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Questions file not provided",
+			})
+			return
+		}
 		topic := c.PostForm("topic")
 		if topic == "" || len(topic) == 0 {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -73,52 +103,181 @@ func UploadQuestionHandler() gin.HandlerFunc {
 			return
 		}
 
-		f, _ := file.Open()
+		format := services.DetectImportFormat(c.PostForm("format"), file.Filename)
+		if !services.IsImportFormat(format) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Unsupported format %q", format),
+			})
+			return
+		}
+
+		duplicatePolicy := c.DefaultPostForm("duplicates", services.DuplicateSkip)
+		if !services.IsDuplicatePolicy(duplicatePolicy) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "duplicates should be skip, merge or flag",
+			})
+			return
+		}
+		threshold := services.DefaultDuplicateThreshold
+		if value := c.PostForm("duplicate_threshold"); value != "" {
+			threshold, err = strconv.ParseFloat(value, 64)
+			if err != nil || threshold <= 0 || threshold > 1 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "duplicate_threshold should be a number above 0 and at most 1",
+				})
+				return
+			}
+		}
+
+		importOptions := services.ImportOptions{}
+		if mapping := c.PostForm("mapping"); mapping != "" {
+			if err = json.Unmarshal([]byte(mapping), &importOptions.Mapping); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "mapping should be a JSON object of question fields to column names",
+				})
+				return
+			}
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
 		defer f.Close()
-		content := make([]byte, file.Size)
-		_, err := f.Read(content)
+		maxBytes := services.ImportMaxBytes()
+		content, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 				"error": "Server error. Please try again later",
 			})
 			return
 		}
+		if int64(len(content)) > maxBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("File is larger than %d bytes", maxBytes),
+			})
+			return
+		}
+		importOptions.MaxBytes = maxBytes
 
-		var questions []models.QuestionUnmarshal
-		err = json.Unmarshal(content, &questions)
+		result, err := services.DecodeQuestions(format, content, importOptions)
 		if err != nil {
+			response := gin.H{
+				"error": err.Error(),
+			}
+			if len(result.Warnings) != 0 {
+				response["warnings"] = result.Warnings
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, response)
+			return
+		}
+		if len(result.Errors) != 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":    fmt.Sprintf("%d of %d questions are invalid. Nothing was uploaded", len(result.Errors), result.Total()),
+				"format":   format,
+				"rows":     result.Errors,
+				"warnings": result.Warnings,
+			})
+			return
+		}
+		if len(result.Questions) == 0 {
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid JSON file. Please upload valid JSON",
+				"error":    "No questions could be imported from this file",
+				"format":   format,
+				"warnings": result.Warnings,
 			})
 			return
 		}
-		var interfaces []interface{}
-		for _, question := range questions {
-			interfaces = append(interfaces, question)
+		questions := result.Questions
+		if !checkMediaReferences(c, questions) {
+			return
 		}
 
-		topicDocument := bson.M{
-			"topic": topic,
+		userAny, _ := c.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		status := models.QuestionStatusDraft
+		if user.IsAdmin && c.PostForm("publish") == "true" {
+			status = models.QuestionStatusPublished
+		}
+		now := time.Now()
+
+		for i, question := range questions {
+			question.Topic = topic
+			question.ID = primitive.NewObjectID()
+			question.Revision = 1
+			question.Status = status
+			question.CreatedBy = user.Username
+			question.ReviewLog = nil
+			question.DuplicateOf = nil
+			if status == models.QuestionStatusPublished {
+				question.ReviewLog = []models.ReviewEvent{{
+					Action: services.WorkflowPublish,
+					To:     status,
+					By:     user.Username,
+					At:     now,
+				}}
+			}
+			questions[i] = question
 		}
-		_, err = topicsCollection.InsertOne(c, topicDocument)
+
+		existing, err := services.GetDuplicateCandidates(c, topic)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Server error. Unable to insert topics",
+				"error": "Server error. Please try again later",
 			})
 			return
 		}
-
-		res, err := questionsCollection.InsertMany(c, interfaces)
+		duplicates := services.FindDuplicates(existing, questions, threshold)
+		questions, err = services.ResolveDuplicates(c, duplicatePolicy, existing, questions, duplicates, user.Username)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Server error. Please try again later",
+				"error": "Server error. Unable to merge duplicate questions",
+			})
+			return
+		}
+
+		if err = services.EnsureTopic(c, topic, user.Username); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Unable to insert topics",
 			})
 			return
 		}
 
+		inserted := 0
+		if len(questions) != 0 {
+			var interfaces []interface{}
+			for _, question := range questions {
+				interfaces = append(interfaces, question)
+			}
+			res, err := questionsCollection.InsertMany(c, interfaces)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Server error. Please try again later",
+				})
+				return
+			}
+			inserted = len(res.InsertedIDs)
+
+			if err = services.RecordUploadedRevisions(c, questions, user.Username, now); err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Server error. Unable to record question history",
+				})
+				return
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"number_of_questions_inserted": len(res.InsertedIDs),
+			"number_of_questions_inserted": inserted,
 			"topic":                        topic,
+			"format":                       format,
+			"status":                       status,
+			"warnings":                     result.Warnings,
+			"duplicate_policy":             duplicatePolicy,
+			"duplicates":                   duplicates,
 		})
 
 	}
@@ -127,57 +286,160 @@ func UploadQuestionHandler() gin.HandlerFunc {
 func GenerateQuizHandler() gin.HandlerFunc {
 	return func(context *gin.Context) {
 
-		params := context.Request.URL.Query()
-		if params.Get("topic") == "" {
+		assessmentId := context.Param("id")
+		if assessmentId == "" {
+			assessmentId = context.Request.URL.Query().Get("assessment_id")
+		}
+		if assessmentId == "" {
 			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-				"error": "Topic not provided in URL",
+				"error": "Assessment ID not provided",
+			})
+			return
+		}
+
+		assessmentIdParsed, err := primitive.ObjectIDFromHex(assessmentId)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Assessment ID is in the wrong format",
+			})
+			return
+		}
+
+		assessment, err := services.GetAssessmentById(context, assessmentIdParsed)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Assessment with given ID not found",
 			})
 			return
 		}
 
 		DB := services.GetConnection()
-		questionsCollection := services.GetCollection(DB, "questions")
 		quizCollection := services.GetCollection(DB, "quizzes")
 
-		topic := params.Get("topic")
+		userAny, _ := context.Get("loggedInAccount")
 
-		filter := bson.M{"$text": bson.M{"$search": topic}}
-		cursor, err := questionsCollection.Find(context, filter)
+		user := userAny.(models.User)
+
+		assignment, err := services.FindAssignment(context, assessment.ID, user.Username, time.Now())
 		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
 			return
 		}
-		var questions []models.Question
-		if err = cursor.All(context, &questions); err != nil {
-			context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		attempts, err := services.CheckAttemptPolicy(context, assessment, user, assignment, time.Now())
+		if err != nil {
+			if attemptErr, ok := err.(*services.AttemptError); ok {
+				if attemptErr.RetryAfter > 0 {
+					context.Header("Retry-After", strconv.Itoa(int(attemptErr.RetryAfter.Seconds())+1))
+				}
+				context.AbortWithStatusJSON(attemptErr.Status, gin.H{
+					"error": attemptErr.Message,
+					"code":  attemptErr.Code,
+				})
+				return
+			}
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
 			return
 		}
 
-		if len(questions) == 0 {
-			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
-				"error": "No questions found with this topic",
+		locale := quizLocale(context, user)
+		if locale != "" && !services.IsLocale(locale) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Locale should be a language tag such as hi or hi-IN",
 			})
 			return
 		}
 
-		startTime := time.Now()
-		endTime := startTime.Add(30 * time.Minute)
+		questions, err := services.AssembleQuestions(context, assessment, locale)
+		if err != nil {
+			if assemblyErr, ok := err.(*services.AssemblyError); ok {
+				response := gin.H{
+					"error": assemblyErr.Message,
+				}
+				if len(assemblyErr.Details) != 0 {
+					response["details"] = assemblyErr.Details
+				}
+				context.AbortWithStatusJSON(assemblyErr.Status, response)
+				return
+			}
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
 
-		userAny, _ := context.Get("loggedInAccount")
+		multiplier, err := services.GetTimeMultiplier(context, user.Username)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
 
-		user := userAny.(models.User)
+		timeLimitMinutes := assessment.TimeLimitMinutes
+		if timeLimitMinutes == 0 {
+			timeLimitMinutes = services.DefaultQuizDurationMinutes
+		}
+		timeLimit := time.Duration(float64(time.Duration(timeLimitMinutes)*time.Minute) * multiplier).Truncate(time.Second)
+		startTime := time.Now()
+		endTime := startTime.Add(timeLimit)
+		// Attempts of an assignment end when it stops taking work.
+		if assignment != nil && endTime.After(assignment.Deadline()) {
+			endTime = assignment.Deadline()
+			timeLimit = endTime.Sub(startTime)
+		}
 
 		quiz := &models.Quiz{
-			Topic:         topic,
-			User:          user,
-			Questions:     questions,
-			UserResponses: make([]models.UserResponse, 0),
-			Completed:     false,
-			StartTime:     startTime,
-			EndTime:       endTime,
+			Topic:            assessment.Topic,
+			AssessmentId:     assessment.ID,
+			Attempt:          len(attempts) + 1,
+			PassingScore:     assessment.PassingScore,
+			Scoring:          assessment.Scoring,
+			FeedbackMode:     assessment.FeedbackMode,
+			Adaptive:         assessment.Adaptive,
+			Locale:           locale,
+			User:             user,
+			Questions:        questions,
+			UserResponses:    make([]models.UserResponse, 0),
+			Completed:        false,
+			StartTime:        startTime,
+			EndTime:          endTime,
+			TimeLimitSeconds: int64(timeLimit / time.Second),
+			TimeMultiplier:   multiplier,
+			Pauses:           make([]models.QuizPause, 0),
+		}
+
+		if assignment != nil {
+			quiz.AssignmentId = &assignment.ID
+			quiz.DueAt = &assignment.DueAt
+			quiz.LatePolicy = &assignment.LatePolicy
+		}
+
+		if assessment.IsAdaptive() {
+			quiz.Ability = &models.AbilityEstimate{Theta: 0, StandardError: 1}
+		} else {
+			quiz.Adaptive = nil
+		}
+
+		if assessment.FeedbackMode == models.FeedbackAfterClose {
+			quiz.FeedbackAt = assessment.ClosesAt
 		}
 
 		res, err := quizCollection.InsertOne(context, &quiz)
 
+		if mongo.IsDuplicateKeyError(err) {
+			// Another request started this attempt between the policy check
+			// and the insert.
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "An attempt for this assessment is already in progress",
+				"code":  services.AttemptErrorInProgress,
+			})
+			return
+		}
 		if err != nil {
 			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 				"error": "Server error. Please try again later",
@@ -186,6 +448,10 @@ func GenerateQuizHandler() gin.HandlerFunc {
 		}
 
 		quiz.Id = res.InsertedID.(primitive.ObjectID)
+		services.SignQuestionMedia(quiz.Questions, startTime)
+		if renderHTML(context) {
+			services.RenderQuestions(quiz.Questions)
+		}
 
 		context.JSON(http.StatusOK, gin.H{
 			"quiz": quiz,
@@ -239,14 +505,30 @@ func SubmitAnswerHandler() gin.HandlerFunc {
 			return
 		}
 
-		if time.Now().After(quiz.EndTime) {
+		if quiz.Paused {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Quiz is paused. Wait for the proctor to resume it",
+			})
+			return
+		}
+
+		now := time.Now()
+		if quiz.Remaining(now) <= 0 && !quiz.Completed {
 			quiz.Completed = true
+			completedAt := services.AttemptFinishedAt(quiz)
+			quiz.CompletedAt = &completedAt
 		}
 
 		if quiz.Completed == true {
+			if quiz.Score == nil {
+				score := services.ScoreQuiz(quiz, now)
+				quiz.Score = &score
+			}
 			_, err = quizCollection.UpdateByID(context, quiz.Id, bson.D{
-				{"$set", bson.D{
-					{"completed", quiz.Completed},
+				{Key: "$set", Value: bson.D{
+					{Key: "completed", Value: quiz.Completed},
+					{Key: "completed_at", Value: quiz.CompletedAt},
+					{Key: "score", Value: quiz.Score},
 				}},
 			})
 
@@ -273,7 +555,7 @@ func SubmitAnswerHandler() gin.HandlerFunc {
 			return
 		}
 
-		questionIdParsed, err := primitive.ObjectIDFromHex(bodyParsed["question_id"])
+		questionIdParsed, err := primitive.ObjectIDFromHex(bodyParsed.QuestionId)
 
 		if err != nil {
 			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
@@ -285,18 +567,26 @@ func SubmitAnswerHandler() gin.HandlerFunc {
 
 		for _, q := range quiz.Questions {
 			if questionIdParsed.String() == q.ID.String() {
-				found := false
-				for _, option := range q.Options {
-					if strings.ToLower(option) == bodyParsed["choice"] {
-						found = true
-					}
-				}
-				if !found {
+				if !q.IsMultipleChoice() && len(bodyParsed.Choices) != 1 {
 					context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
-						"error": "User choice is invalid for this current question",
+						"error": "Only one choice is allowed for this question",
 					})
 					return
 				}
+				for _, choice := range bodyParsed.Choices {
+					found := false
+					for _, option := range q.Options {
+						if strings.ToLower(option) == choice {
+							found = true
+						}
+					}
+					if !found {
+						context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+							"error": "User choice is invalid for this current question",
+						})
+						return
+					}
+				}
 				question = q
 			}
 		}
@@ -318,27 +608,49 @@ func SubmitAnswerHandler() gin.HandlerFunc {
 		}
 
 		userResponse := models.UserResponse{
-			QuestionId:    question.ID,
-			Response:      bodyParsed["choice"],
-			CorrectAnswer: strings.ToLower(question.CorrectAnswer),
+			QuestionId:        question.ID,
+			QuestionRevision:  question.Revision,
+			AnswerKeyRevision: question.Revision,
+			Response:          strings.Join(bodyParsed.Choices, ", "),
+			CorrectAnswer:     strings.ToLower(question.CorrectAnswer),
 		}
 
-		if userResponse.Response == strings.ToLower(question.CorrectAnswer) {
-			userResponse.Result = "Right"
-		} else {
-			userResponse.Result = "Wrong"
+		if question.IsMultipleChoice() {
+			userResponse.Responses = bodyParsed.Choices
+			userResponse.CorrectAnswer = strings.ToLower(strings.Join(question.CorrectAnswers, ", "))
 		}
 
+		userResponse.Result, userResponse.Credit = services.GradeResponse(question, bodyParsed.Choices)
+
 		quiz.UserResponses = append(quiz.UserResponses, userResponse)
 
+		var nextQuestion *models.Question
+		if quiz.Adaptive != nil {
+			nextQuestion, err = services.AdvanceAdaptiveQuiz(context, &quiz)
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Internal server error please try again",
+				})
+				return
+			}
+		}
+
 		if len(quiz.UserResponses) == len(quiz.Questions) {
 			quiz.Completed = true
+			quiz.CompletedAt = &now
+			score := services.ScoreQuiz(quiz, now)
+			quiz.Score = &score
 		}
 
 		updateDocument := bson.D{
-			{"$set", bson.D{
-				{"user_responses", quiz.UserResponses},
-				{"completed", quiz.Completed},
+			{Key: "$set", Value: bson.D{
+				{Key: "questions", Value: quiz.Questions},
+				{Key: "ability", Value: quiz.Ability},
+				{Key: "user_responses", Value: quiz.UserResponses},
+				{Key: "completed", Value: quiz.Completed},
+				{Key: "completed_at", Value: quiz.CompletedAt},
+				{Key: "score", Value: quiz.Score},
+				{Key: "elapsed_seconds", Value: int64(quiz.Elapsed(now) / time.Second)},
 			}},
 		}
 
@@ -351,9 +663,22 @@ func SubmitAnswerHandler() gin.HandlerFunc {
 			return
 		}
 
-		context.JSON(http.StatusOK, gin.H{
-			"result": userResponse,
-		})
+		response := gin.H{
+			"result":    userResponse,
+			"completed": quiz.Completed,
+		}
+		if quiz.FeedbackMode != "" && quiz.FeedbackMode != models.FeedbackImmediate {
+			response["result"] = redactUserResponse(userResponse)
+		}
+		if nextQuestion != nil {
+			services.SignMediaReferences(nextQuestion.Media, now)
+			if renderHTML(context) {
+				services.RenderQuestion(nextQuestion)
+			}
+			response["next_question"] = nextQuestion
+		}
+
+		context.JSON(http.StatusOK, response)
 	}
 }
 
@@ -402,9 +727,16 @@ func QuizResultHandler() gin.HandlerFunc {
 			return
 		}
 
-		if !user.IsAdmin && user.Username != quiz.User.Username {
-			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error": "You don't have permissions to view this quiz's result",
+		if !authorizeStudentView(context, user, quiz.User.Username, quiz.AssessmentId, "You don't have permissions to view this quiz's result") {
+			return
+		}
+		// Admins and the student's instructors see every detail.
+		staff := user.IsAdmin || user.Username != quiz.User.Username
+
+		now := time.Now()
+		if err = services.FinalizeQuiz(context, &quiz, now); err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error. Please try again",
 			})
 			return
 		}
@@ -416,56 +748,133 @@ func QuizResultHandler() gin.HandlerFunc {
 			return
 		}
 
-		correctAnswers := 0
+		feedback := gin.H{
+			"mode":            quiz.FeedbackMode,
+			"details_visible": true,
+		}
+		if quiz.FeedbackAt != nil {
+			feedback["available_at"] = quiz.FeedbackAt
+		}
 
-		for _, response := range quiz.UserResponses {
-			if response.Result == "Right" {
-				correctAnswers += 1
+		services.SignQuestionMedia(quiz.Questions, now)
+		if renderHTML(context) {
+			services.RenderQuestions(quiz.Questions)
+		}
+
+		if !staff && !quiz.FeedbackVisible(now) {
+			feedback["details_visible"] = false
+			userResponses := make([]gin.H, 0, len(quiz.UserResponses))
+			for _, response := range quiz.UserResponses {
+				userResponses = append(userResponses, redactUserResponse(response))
 			}
+
+			// only the overall outcome, since the counts of correct and
+			// incorrect answers would give the per-question results away
+			context.JSON(http.StatusOK, gin.H{
+				"quiz":           quiz,
+				"user_responses": userResponses,
+				"score": gin.H{
+					"percentage": quiz.Score.Percentage,
+					"passed":     quiz.Score.Passed,
+				},
+				"ability":  quiz.Ability,
+				"feedback": feedback,
+				"stats": gin.H{
+					"total_questions_answered": len(quiz.UserResponses),
+					"total_questions":          len(quiz.Questions),
+					"percentage":               fmt.Sprintf("%.2f", quiz.Score.Percentage),
+					"passing_score":            quiz.PassingScore,
+					"passed":                   quiz.Score.Passed,
+				},
+			})
+			return
 		}
 
 		context.JSON(http.StatusOK, gin.H{
 			"quiz":           quiz,
 			"user_responses": quiz.UserResponses,
+			"score":          quiz.Score,
+			"ability":        quiz.Ability,
+			"feedback":       feedback,
 			"stats": gin.H{
 				"total_questions_answered":  len(quiz.UserResponses),
-				"number_of_correct_answers": correctAnswers,
+				"number_of_correct_answers": quiz.Score.Correct,
 				"total_questions":           len(quiz.Questions),
-				"percentage":                fmt.Sprintf("%.2f", float64(correctAnswers)/float64(len(quiz.Questions))*100),
+				"percentage":                fmt.Sprintf("%.2f", quiz.Score.Percentage),
+				"passing_score":             quiz.PassingScore,
+				"passed":                    quiz.Score.Passed,
 			},
 		})
 	}
 }
 
-func validateUserResponseBody(body []byte) (map[string]string, []string) {
+// redactUserResponse strips correctness information from a response for
+// quizzes whose feedback is not yet visible to the student.
+func redactUserResponse(response models.UserResponse) gin.H {
+	redacted := gin.H{
+		"question_id": response.QuestionId,
+		"response":    response.Response,
+	}
+	if len(response.Responses) != 0 {
+		redacted["responses"] = response.Responses
+	}
+	return redacted
+}
+
+type userResponseBody struct {
+	QuestionId string
+	Choices    []string
+}
+
+func validateUserResponseBody(body []byte) (*userResponseBody, []string) {
 
 	var data map[string]interface{}
 	err := json.Unmarshal(body, &data)
 	errorStrings := make([]string, 0)
 
-	parsed := make(map[string]string)
+	parsed := &userResponseBody{}
 
 	if err != nil {
 		errorStrings = append(errorStrings, "JSON is invalid")
 		return nil, errorStrings
 	}
 
-	keysToValidate := []string{
-		"question_id",
-		"choice",
+	if data["question_id"] == nil {
+		errorStrings = append(errorStrings, "question_id should be included in the body")
+	} else {
+		parsed.QuestionId = strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", data["question_id"])))
+		if len(parsed.QuestionId) == 0 {
+			errorStrings = append(errorStrings, "question_id should not be empty")
+		}
 	}
 
-	for _, key := range keysToValidate {
-		if data[key] == nil {
-			errorStrings = append(errorStrings, fmt.Sprintf("%v should be included in the body", key))
-			continue
-		} else {
-			parsed[key] = strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", data[key])))
-			if len(parsed[key]) == 0 {
-				errorStrings = append(errorStrings, fmt.Sprintf("%v should not be empty", key))
-				continue
+	switch choice := data["choice"].(type) {
+	case nil:
+		errorStrings = append(errorStrings, "choice should be included in the body")
+	case []interface{}:
+		seen := make(map[string]bool)
+		for _, item := range choice {
+			value := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", item)))
+			if len(value) == 0 {
+				errorStrings = append(errorStrings, "choice should not contain empty values")
+				break
 			}
+			if seen[value] {
+				errorStrings = append(errorStrings, "choice should not contain repeated values")
+				break
+			}
+			seen[value] = true
+			parsed.Choices = append(parsed.Choices, value)
+		}
+		if len(choice) == 0 {
+			errorStrings = append(errorStrings, "choice should not be empty")
+		}
+	default:
+		value := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", choice)))
+		if len(value) == 0 {
+			errorStrings = append(errorStrings, "choice should not be empty")
 		}
+		parsed.Choices = []string{value}
 	}
 
 	if len(errorStrings) != 0 {
@@ -475,3 +884,22 @@ func validateUserResponseBody(body []byte) (map[string]string, []string) {
 	}
 
 }
+
+// renderHTML reports whether the client asked for questions to include
+// sanitized HTML with ?render=html.
+func renderHTML(context *gin.Context) bool {
+	return context.Query("render") == "html"
+}
+
+// quizLocale returns the language a new quiz should be in: the locale query
+// parameter, else the student's preferred language, else the first language
+// in Accept-Language.
+func quizLocale(context *gin.Context, user models.User) string {
+	if locale, ok := context.GetQuery("locale"); ok {
+		return services.NormalizeLocale(locale)
+	}
+	if user.Locale != "" {
+		return user.Locale
+	}
+	return services.PreferredLocale(context.GetHeader("Accept-Language"))
+}
@@ -77,14 +77,20 @@ func TestGenerateQuizHandler(t *testing.T) {
 	router := gin.Default()
 	router.GET("/quiz", GenerateQuizHandler())
 
-	// Test case: Topic not provided in URL
+	// Test case: Assessment ID not provided in URL
 	req, _ := http.NewRequest("GET", "/quiz", nil)
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 
-	// Test case: No questions found with this topic
-	req, _ = http.NewRequest("GET", "/quiz?topic=nonexistent", nil)
+	// Test case: Assessment ID in wrong format
+	req, _ = http.NewRequest("GET", "/quiz?assessment_id=invalidID", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Test case: No assessment found with this ID
+	req, _ = http.NewRequest("GET", "/quiz?assessment_id=000000000000000000000000", nil)
 	rr = httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusNotFound, rr.Code)
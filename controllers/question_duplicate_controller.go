@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"net/http"
+	"strconv"
+)
+
+const defaultDuplicateReportLimit = 100
+
+func GetDuplicateReportHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		threshold := services.DefaultDuplicateThreshold
+		if value := context.Query("threshold"); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed <= 0 || parsed > 1 {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "threshold should be a number above 0 and at most 1",
+				})
+				return
+			}
+			threshold = parsed
+		}
+
+		limit := defaultDuplicateReportLimit
+		if value := context.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "limit should be a positive number",
+				})
+				return
+			}
+			limit = parsed
+		}
+
+		filter := bson.M{}
+		if topic := context.Query("topic"); topic != "" {
+			filter["topic"] = topic
+		}
+
+		pairs, err := services.DuplicateReport(context, filter, threshold)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		total := len(pairs)
+		if len(pairs) > limit {
+			pairs = pairs[:limit]
+		}
+		context.JSON(http.StatusOK, gin.H{
+			"threshold":  threshold,
+			"total":      total,
+			"duplicates": pairs,
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetDuplicateReportHandler(t *testing.T) {
+	// Set up the router
+	router := gin.Default()
+	router.GET("/questions/duplicates", GetDuplicateReportHandler())
+
+	// Test case: Threshold out of range
+	req, _ := http.NewRequest("GET", "/questions/duplicates?threshold=1.5", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Test case: Limit is not a number
+	req, _ = http.NewRequest("GET", "/questions/duplicates?limit=all", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
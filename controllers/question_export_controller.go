@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func ExportQuestionQTIHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		version := context.DefaultQuery("version", services.QTIVersion21)
+		if !services.IsQTIVersion(version) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "version should be 2.1 or 3.0",
+			})
+			return
+		}
+
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+		if question.Template != nil {
+			context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Template questions can't be exported to QTI",
+			})
+			return
+		}
+
+		context.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": services.QTIItemIdentifier(question) + ".xml",
+		}))
+		context.Header("Content-Type", "application/xml")
+		context.Status(http.StatusOK)
+		if err := services.WriteQTIItem(context.Writer, question, version); err != nil {
+			log.Println("Failed to write QTI item", err)
+		}
+	}
+}
+
+func ExportQuestionsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		format := strings.ToLower(context.DefaultQuery("format", services.ImportFormatJSON))
+		if !services.IsExportFormat(format) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "format should be json, csv or qti",
+			})
+			return
+		}
+
+		exportOptions := services.ExportOptions{QTIVersion: context.DefaultQuery("version", services.QTIVersion21)}
+		if format == services.ImportFormatQTI && !services.IsQTIVersion(exportOptions.QTIVersion) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "version should be 2.1 or 3.0",
+			})
+			return
+		}
+
+		status := context.Query("status")
+		if status != "" && !models.IsQuestionStatus(status) {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "status should be draft, in_review, published, rejected or retired",
+			})
+			return
+		}
+
+		topic := context.Query("topic")
+		var tags []string
+		if tagsParam := context.Query("tags"); tagsParam != "" {
+			tags = strings.Split(tagsParam, ",")
+		}
+		filter := services.QuestionExportFilter(topic, tags, status)
+
+		count, err := services.CountQuestions(context, filter)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if count == 0 {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "No questions found to export",
+			})
+			return
+		}
+
+		// QTI can't hold template questions, so the package leaves them out
+		var skipped int64
+		if format == services.ImportFormatQTI {
+			skipped, err = services.CountQuestions(context, services.TemplateQuestionFilter(filter))
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Server error. Please try again later",
+				})
+				return
+			}
+			if skipped == count {
+				context.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"error": "Template questions can't be exported to QTI",
+				})
+				return
+			}
+		}
+
+		name := "questions"
+		if topic != "" {
+			name = topic
+		}
+		contentType, extension := services.ExportContentType(format)
+		context.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": name + "." + extension,
+		}))
+		context.Header("Content-Type", contentType)
+		context.Header("X-Question-Count", strconv.FormatInt(count-skipped, 10))
+		if skipped != 0 {
+			context.Header("X-Skipped-Question-Count", strconv.FormatInt(skipped, 10))
+		}
+		context.Status(http.StatusOK)
+
+		encoder, err := services.NewQuestionEncoder(format, context.Writer, exportOptions)
+		if err != nil {
+			log.Println("Failed to start question export", err)
+			return
+		}
+		// the response has started, so errors can only be logged from here
+		if _, err = services.ExportQuestions(context, filter, encoder); err != nil {
+			log.Println("Failed to export questions", err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExportQuestionQTIHandler(t *testing.T) {
+	// Set up the router
+	router := gin.Default()
+	router.GET("/questions/:id/qti", ExportQuestionQTIHandler())
+
+	// Test case: unsupported QTI version
+	req, _ := http.NewRequest("GET", "/questions/000000000000000000000000/qti?version=1.2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Test case: Question ID in wrong format
+	req, _ = http.NewRequest("GET", "/questions/invalidID/qti", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestExportQuestionsHandler(t *testing.T) {
+	// Set up the router
+	router := gin.Default()
+	router.GET("/questions/export", ExportQuestionsHandler())
+
+	// Test case: unsupported format
+	req, _ := http.NewRequest("GET", "/questions/export?format=xml", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Test case: unsupported QTI version
+	req, _ = http.NewRequest("GET", "/questions/export?format=qti&version=1.2", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type questionUpdateRequest struct {
+	models.QuestionUnmarshal
+	Comment string `json:"comment"`
+}
+
+func GetQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		services.SignMediaReferences(question.Media, time.Now())
+		if renderHTML(context) {
+			services.RenderQuestionDetail(&question)
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"question": question,
+		})
+	}
+}
+
+func UpdateQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		current, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body questionUpdateRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		if body.Revision != 0 && body.Revision != current.Revision {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error":            "Question has been changed since this revision. Reload it and try again",
+				"current_revision": current.Revision,
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !user.IsAdmin {
+			if current.CreatedBy != user.Username {
+				context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": "Only the author of this question can edit it",
+				})
+				return
+			}
+			if !services.AuthorCanEdit(current) {
+				status := services.QuestionStatus(current)
+				context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error":  "This action is not allowed for a question that is " + status,
+					"status": status,
+				})
+				return
+			}
+		}
+
+		updated := body.QuestionUnmarshal
+		if updated.Topic == "" {
+			updated.Topic = current.Topic
+		}
+		if errs := services.ValidateQuestion(&updated); len(errs) != 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"errors": errs,
+			})
+			return
+		}
+
+		if !checkMediaReferences(context, []models.QuestionUnmarshal{updated}) {
+			return
+		}
+
+		question, changes, err := services.UpdateQuestion(context, current, updated, body.Comment, user.Username)
+		if err != nil {
+			if err == services.ErrRevisionConflict {
+				context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error": "Question has been changed since this revision. Reload it and try again",
+				})
+				return
+			}
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		if question.Topic != current.Topic {
+			if err = services.EnsureTopic(context, question.Topic, user.Username); err != nil {
+				context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Server error. Unable to insert topics",
+				})
+				return
+			}
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"question":           question,
+			"changes":            changes,
+			"answer_key_changed": services.AnswerKeyChanged(changes),
+		})
+	}
+}
+
+func GetQuestionRevisionsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		revisions, err := services.GetQuestionRevisions(context, question.ID)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"question_id":      question.ID,
+			"current_revision": question.Revision,
+			"revisions":        revisions,
+		})
+	}
+}
+
+func GetQuestionRevisionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		questionIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Question ID is in the wrong format",
+			})
+			return
+		}
+
+		revision, err := strconv.Atoi(context.Param("revision"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Revision should be a number",
+			})
+			return
+		}
+
+		questionRevision, err := services.GetQuestionRevision(context, questionIdParsed, revision)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Revision not found for this question",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"revision": questionRevision,
+		})
+	}
+}
+
+func findQuestionFromParam(context *gin.Context) (models.QuestionUnmarshal, bool) {
+	questionIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Question ID is in the wrong format",
+		})
+		return models.QuestionUnmarshal{}, false
+	}
+
+	question, err := services.GetQuestionById(context, questionIdParsed)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Question with given ID not found",
+		})
+		return models.QuestionUnmarshal{}, false
+	}
+	return question, true
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"net/http"
+)
+
+type workflowRequest struct {
+	Decision string `json:"decision"`
+	Comment  string `json:"comment"`
+}
+
+func SubmitQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body workflowRequest
+		if !bindOptionalJSON(context, &body) {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !user.IsAdmin && question.CreatedBy != user.Username {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Only the author of this question can submit it for review",
+			})
+			return
+		}
+
+		applyQuestionTransition(context, &question, services.WorkflowSubmit, user.Username, body.Comment)
+	}
+}
+
+func ReviewQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body workflowRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		action := body.Decision
+		if action != services.WorkflowApprove && action != services.WorkflowReject {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "decision should be approve or reject",
+			})
+			return
+		}
+		if action == services.WorkflowReject && body.Comment == "" {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "A comment is required when rejecting a question",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !user.IsAdmin && question.CreatedBy == user.Username {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "You can't review your own question",
+			})
+			return
+		}
+
+		applyQuestionTransition(context, &question, action, user.Username, body.Comment)
+	}
+}
+
+func PublishQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body workflowRequest
+		if !bindOptionalJSON(context, &body) {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		applyQuestionTransition(context, &question, services.WorkflowPublish, user.Username, body.Comment)
+	}
+}
+
+func RetireQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body workflowRequest
+		if !bindOptionalJSON(context, &body) {
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		applyQuestionTransition(context, &question, services.WorkflowRetire, user.Username, body.Comment)
+	}
+}
+
+func applyQuestionTransition(context *gin.Context, question *models.QuestionUnmarshal, action, by, comment string) {
+	from := services.QuestionStatus(*question)
+	if err := services.TransitionQuestion(context, question, action, by, comment); err != nil {
+		if err == services.ErrInvalidTransition {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error":  "This action is not allowed for a question that is " + from,
+				"status": from,
+			})
+			return
+		}
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Server error. Please try again later",
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"question": question,
+	})
+}
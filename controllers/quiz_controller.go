@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func PauseQuizHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		quizIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Quiz ID is in the wrong format",
+			})
+			return
+		}
+
+		quiz, err := services.GetQuizById(context, quizIdParsed)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Quiz with given ID not found",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !authorizeInstructor(context, user, quiz.User.Username, quiz.AssessmentId, "You don't have access to make this request") {
+			return
+		}
+
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if !bindOptionalJSON(context, &body) {
+			return
+		}
+
+		now := time.Now()
+		if quiz.Completed || quiz.Remaining(now) <= 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Quiz has already ended and cannot be paused",
+			})
+			return
+		}
+
+		if quiz.Paused {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Quiz is already paused",
+			})
+			return
+		}
+
+		pause := models.QuizPause{
+			PausedAt: now,
+			PausedBy: user.Username,
+			Reason:   body.Reason,
+		}
+		quiz.Pauses = append(quiz.Pauses, pause)
+		quiz.Paused = true
+		quiz.ElapsedSeconds = int64(quiz.Elapsed(now) / time.Second)
+
+		DB := services.GetConnection()
+		quizCollection := services.GetCollection(DB, "quizzes")
+		res, err := quizCollection.UpdateOne(context, bson.M{"_id": quiz.Id, "paused": bson.M{"$ne": true}}, bson.M{
+			"$set": bson.M{
+				"paused":          true,
+				"elapsed_seconds": quiz.ElapsedSeconds,
+			},
+			"$push": bson.M{"pauses": pause},
+		})
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error. Please try again",
+			})
+			return
+		}
+		if res.MatchedCount == 0 {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Quiz is already paused",
+			})
+			return
+		}
+
+		services.SignQuestionMedia(quiz.Questions, now)
+		if renderHTML(context) {
+			services.RenderQuestions(quiz.Questions)
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"quiz":              quiz,
+			"remaining_seconds": int64(quiz.Remaining(now) / time.Second),
+		})
+	}
+}
+
+func ResumeQuizHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		quizIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Quiz ID is in the wrong format",
+			})
+			return
+		}
+
+		quiz, err := services.GetQuizById(context, quizIdParsed)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Quiz with given ID not found",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !authorizeInstructor(context, user, quiz.User.Username, quiz.AssessmentId, "You don't have access to make this request") {
+			return
+		}
+
+		if !quiz.Paused || len(quiz.Pauses) == 0 {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Quiz is not paused",
+			})
+			return
+		}
+
+		now := time.Now()
+		last := len(quiz.Pauses) - 1
+		pausedFor := now.Sub(quiz.Pauses[last].PausedAt).Truncate(time.Second)
+
+		quiz.Pauses[last].ResumedAt = &now
+		quiz.Pauses[last].ResumedBy = user.Username
+		quiz.Paused = false
+		quiz.PausedSeconds += int64(pausedFor / time.Second)
+		quiz.EndTime = quiz.EndTime.Add(pausedFor)
+		quiz.ElapsedSeconds = int64(quiz.Elapsed(now) / time.Second)
+
+		DB := services.GetConnection()
+		quizCollection := services.GetCollection(DB, "quizzes")
+		// The pause being ended must still be the last one, so a resume
+		// racing another resume or a new pause doesn't overwrite it.
+		res, err := quizCollection.UpdateOne(context, bson.M{
+			"_id":    quiz.Id,
+			"paused": true,
+			"pauses": bson.M{"$size": len(quiz.Pauses)},
+		}, bson.M{
+			"$set": bson.M{
+				"paused":          false,
+				"pauses":          quiz.Pauses,
+				"paused_seconds":  quiz.PausedSeconds,
+				"end_time":        quiz.EndTime,
+				"elapsed_seconds": quiz.ElapsedSeconds,
+			},
+		})
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error. Please try again",
+			})
+			return
+		}
+		if res.MatchedCount == 0 {
+			context.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "Quiz is not paused",
+			})
+			return
+		}
+
+		services.SignQuestionMedia(quiz.Questions, now)
+		if renderHTML(context) {
+			services.RenderQuestions(quiz.Questions)
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"quiz":              quiz,
+			"remaining_seconds": int64(quiz.Remaining(now) / time.Second),
+		})
+	}
+}
+
+func QuizReviewHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		quizIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Quiz ID is in the wrong format",
+			})
+			return
+		}
+
+		quiz, err := services.GetQuizById(context, quizIdParsed)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Quiz with given ID not found",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		if !authorizeStudentView(context, user, quiz.User.Username, quiz.AssessmentId, "You don't have permissions to review this quiz") {
+			return
+		}
+		staff := user.IsAdmin || user.Username != quiz.User.Username
+
+		now := time.Now()
+		if err = services.FinalizeQuiz(context, &quiz, now); err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error. Please try again",
+			})
+			return
+		}
+
+		if !quiz.Completed {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Quiz has not ended yet. Review is available once the quiz is completed",
+			})
+			return
+		}
+
+		if !staff && !quiz.FeedbackVisible(now) {
+			response := gin.H{
+				"error": "Review is not available for this quiz",
+			}
+			if quiz.FeedbackMode == models.FeedbackAfterClose && quiz.FeedbackAt != nil {
+				response["available_at"] = quiz.FeedbackAt
+			}
+			context.AbortWithStatusJSON(http.StatusForbidden, response)
+			return
+		}
+
+		responses := make(map[primitive.ObjectID]models.UserResponse)
+		for _, response := range quiz.UserResponses {
+			responses[response.QuestionId] = response
+		}
+
+		items := make([]gin.H, 0, len(quiz.Questions))
+		for _, question := range quiz.Questions {
+			item := gin.H{
+				"question_id":    question.ID,
+				"question":       question.QuestionName,
+				"format":         question.Format,
+				"type":           question.Type,
+				"options":        question.Options,
+				"correct_answer": question.CorrectAnswer,
+				"explanation":    question.Explanation,
+				"answered":       false,
+			}
+			if len(question.Media) != 0 {
+				services.SignMediaReferences(question.Media, now)
+				item["media"] = question.Media
+			}
+			if renderHTML(context) {
+				services.RenderQuestion(&question)
+				question.HTML.Explanation = services.RenderContent(question.Format, question.Explanation, false)
+				item["html"] = question.HTML
+			}
+			if question.IsMultipleChoice() {
+				item["correct_answers"] = question.CorrectAnswers
+			}
+
+			if response, ok := responses[question.ID]; ok {
+				item["answered"] = true
+				item["response"] = response.Response
+				item["result"] = response.Result
+				item["credit"] = response.Credit
+
+				choices := response.Responses
+				if len(choices) == 0 {
+					choices = []string{response.Response}
+				}
+				item["option_feedback"] = optionFeedbackFor(question, choices)
+			}
+
+			items = append(items, item)
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"quiz_id": quiz.Id,
+			"topic":   quiz.Topic,
+			"score":   quiz.Score,
+			"review":  items,
+		})
+	}
+}
+
+// optionFeedbackFor returns the author's feedback for each option the
+// student picked. Responses are stored lowercased, so keys are matched
+// case-insensitively.
+func optionFeedbackFor(question models.Question, choices []string) map[string]string {
+	feedback := make(map[string]string)
+	for option, text := range question.OptionFeedback {
+		for _, choice := range choices {
+			if strings.ToLower(option) == choice {
+				feedback[option] = text
+			}
+		}
+	}
+	return feedback
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPauseAndResumeQuizHandler(t *testing.T) {
+	// Set up the router
+	router := gin.Default()
+	router.POST("/quiz/:id/pause", PauseQuizHandler())
+	router.POST("/quiz/:id/resume", ResumeQuizHandler())
+
+	// Test case: Quiz ID in wrong format
+	req, _ := http.NewRequest("POST", "/quiz/invalidID/pause", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// Test case: Quiz with given ID not found
+	req, _ = http.NewRequest("POST", "/quiz/000000000000000000000000/resume", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestQuizReviewHandler(t *testing.T) {
+	// Set up the router
+	router := gin.Default()
+	router.GET("/quiz/:id/review", QuizReviewHandler())
+
+	// Test case: Quiz ID in wrong format
+	req, _ := http.NewRequest("GET", "/quiz/invalidID/review", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
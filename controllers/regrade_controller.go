@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
+	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type regradeRequest struct {
+	QuestionId string `json:"question_id"`
+	Topic      string `json:"topic"`
+	Reason     string `json:"reason"`
+	Notify     bool   `json:"notify"`
+}
+
+func RegradeQuestionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		question, ok := findQuestionFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body regradeRequest
+		if !bindOptionalJSON(context, &body) {
+			return
+		}
+
+		runRegrade(context, services.RegradeRequest{
+			Scope:     models.RegradeScopeQuestion,
+			Topic:     question.Topic,
+			Questions: []models.QuestionUnmarshal{question},
+			Reason:    body.Reason,
+			Notify:    body.Notify,
+		})
+	}
+}
+
+func RegradeHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var body regradeRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+
+		body.QuestionId = strings.TrimSpace(body.QuestionId)
+		body.Topic = strings.TrimSpace(body.Topic)
+		if (body.QuestionId == "") == (body.Topic == "") {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Provide either question_id or topic",
+			})
+			return
+		}
+
+		if body.QuestionId != "" {
+			questionIdParsed, err := primitive.ObjectIDFromHex(body.QuestionId)
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "Question ID in wrong format",
+				})
+				return
+			}
+			question, err := services.GetQuestionById(context, questionIdParsed)
+			if err != nil {
+				context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+					"error": "Question with given ID not found",
+				})
+				return
+			}
+
+			runRegrade(context, services.RegradeRequest{
+				Scope:     models.RegradeScopeQuestion,
+				Topic:     question.Topic,
+				Questions: []models.QuestionUnmarshal{question},
+				Reason:    body.Reason,
+				Notify:    body.Notify,
+			})
+			return
+		}
+
+		questions, err := services.GetQuestionsByTopic(context, body.Topic)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if len(questions) == 0 {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "No questions found with this topic",
+			})
+			return
+		}
+
+		runRegrade(context, services.RegradeRequest{
+			Scope:     models.RegradeScopeTopic,
+			Topic:     body.Topic,
+			Questions: questions,
+			Reason:    body.Reason,
+			Notify:    body.Notify,
+		})
+	}
+}
+
+func GetRegradeAuditsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		audits, err := services.GetRegradeAudits(context)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"regrades": audits,
+		})
+	}
+}
+
+func GetRegradeAuditHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		auditIdParsed, err := primitive.ObjectIDFromHex(context.Param("id"))
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Regrade ID is in the wrong format",
+			})
+			return
+		}
+
+		audit, changes, err := services.GetRegradeAudit(context, auditIdParsed)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Regrade with given ID not found",
+			})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"regrade": audit,
+			"changes": changes,
+		})
+	}
+}
+
+func runRegrade(context *gin.Context, request services.RegradeRequest) {
+	userAny, _ := context.Get("loggedInAccount")
+	user := userAny.(models.User)
+	request.RequestedBy = user.Username
+
+	audit, err := services.Regrade(context, request)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error":      "Server error. Regrade did not finish",
+			"regrade_id": audit.ID,
+		})
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"regrade": audit,
+	})
+}
+
+// bindOptionalJSON decodes the request body when there is one. It aborts the
+// request and returns false if the body is not valid JSON.
+func bindOptionalJSON(context *gin.Context, target interface{}) bool {
+	content, _ := io.ReadAll(context.Request.Body)
+	if len(content) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(content, target); err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": "JSON is invalid",
+		})
+		return false
+	}
+	return true
+}
@@ -1,27 +1,332 @@
 package controllers
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/models"
 	"github.com/zeekhoks/quiz-backend/services"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+type topicRequest struct {
+	Name        string              `json:"topic"`
+	Description string              `json:"description"`
+	ParentId    *primitive.ObjectID `json:"parent_id"`
+}
+
+// GetAllTopics lists topics a page at a time. Authors, reviewers and admins
+// see every topic with all its counts; students only see the topics of
+// assessments they can start, including those assigned to their classes.
 func GetAllTopics() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		DB := services.GetConnection()
-		questionsCollection := services.GetCollection(DB, "topics")
+		query := services.TopicQuery{
+			Search: strings.TrimSpace(ctx.Query("q")),
+			Cursor: ctx.Query("cursor"),
+			Limit:  services.DefaultTopicPageSize,
+		}
+		if sortBy := ctx.Query("sort"); sortBy != "" {
+			query.Sort, query.Descending = strings.CutPrefix(sortBy, "-")
+			if !services.IsTopicSort(query.Sort) {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "sort should be name, relevance, published_questions, attempts or created_at",
+				})
+				return
+			}
+		}
+		if value := ctx.Query("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > services.MaxTopicPageSize {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("limit should be between 1 and %d", services.MaxTopicPageSize),
+				})
+				return
+			}
+			query.Limit = limit
+		}
+
+		userAny, _ := ctx.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		topics, ok := visibleTopics(ctx, user)
+		if !ok {
+			return
+		}
+		if err := services.CountTopicSortKey(ctx, topics, query.Sort); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		page, err := services.SearchTopics(topics, query)
+		if err != nil {
+			if err == services.ErrInvalidTopicCursor {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": "cursor is invalid for this search",
+				})
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+		if err = services.CountTopics(ctx, topics, page.Topics); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Server error. Please try again later",
+			})
+			return
+		}
+
+		response := gin.H{
+			"topics": topicViews(user, page.Topics),
+			"total":  page.Total,
+		}
+		if page.NextCursor != "" {
+			response["next_cursor"] = page.NextCursor
+		}
+		ctx.JSON(http.StatusOK, response)
+	}
+}
 
-		cursor, err := questionsCollection.Find(ctx, bson.M{})
+func GetTopicHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(context.Param("id"))
 		if err != nil {
+			context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Topic ID is in the wrong format",
+			})
 			return
 		}
-		var topics []bson.M
-		if err = cursor.All(ctx, &topics); err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		topics, ok := visibleTopics(context, user)
+		if !ok {
 			return
 		}
+		for _, topic := range topics {
+			if topic.ID == id {
+				ancestors := services.TopicAncestors(topics, id)
+				subtopics := services.SubTopics(topics, id)
+				shown := append(append([]models.Topic{topic}, ancestors...), subtopics...)
+				if err = services.CountTopics(context, topics, shown); err != nil {
+					context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+						"error": "Server error. Please try again later",
+					})
+					return
+				}
+				context.JSON(http.StatusOK, gin.H{
+					"topic":     topicViews(user, shown[:1])[0],
+					"ancestors": topicViews(user, shown[1:1+len(ancestors)]),
+					"subtopics": topicViews(user, shown[1+len(ancestors):]),
+				})
+				return
+			}
+		}
+
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Topic with given ID not found",
+		})
+	}
+}
+
+// visibleTopics loads the topics a user may see, without their counts,
+// answering the request itself when that fails.
+func visibleTopics(context *gin.Context, user models.User) ([]models.Topic, bool) {
+	topics, err := services.GetTopics(context)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Server error. Please try again later",
+		})
+		return nil, false
+	}
+	if user.HasRole(models.RoleAuthor, models.RoleReviewer) {
+		return topics, true
+	}
+
+	classIds, err := services.MemberClassIds(context, user.Username)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Server error. Please try again later",
+		})
+		return nil, false
+	}
+	open, err := services.OpenTopicNames(context, time.Now(), classIds)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Server error. Please try again later",
+		})
+		return nil, false
+	}
+	visible := make([]models.Topic, 0, len(open))
+	for _, topic := range topics {
+		if open[topic.Name] {
+			visible = append(visible, topic)
+		}
+	}
+	return visible, true
+}
+
+// topicViews hides draft counts, attempts and authorship from students.
+func topicViews(user models.User, topics []models.Topic) []interface{} {
+	views := make([]interface{}, 0, len(topics))
+	for _, topic := range topics {
+		if user.HasRole(models.RoleAuthor, models.RoleReviewer) {
+			views = append(views, topic)
+			continue
+		}
+		view := gin.H{
+			"id":                       topic.ID,
+			"topic":                    topic.Name,
+			"published_question_count": topic.PublishedQuestionCount,
+		}
+		if topic.Description != "" {
+			view["description"] = topic.Description
+		}
+		if topic.ParentId != nil {
+			view["parent_id"] = topic.ParentId
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+func CreateTopicHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var body topicRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "topic should not be empty",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		now := time.Now()
+		topic, err := services.CreateTopic(context, models.Topic{
+			Name:        body.Name,
+			Description: strings.TrimSpace(body.Description),
+			ParentId:    body.ParentId,
+			CreatedBy:   user.Username,
+			CreatedAt:   &now,
+		})
+		if err != nil {
+			abortWithTopicError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusCreated, gin.H{
+			"topic": topic,
+		})
+	}
+}
+
+// UpdateTopicHandler renames, describes or moves a topic. A new name is
+// applied to every question, assessment and quiz of the topic.
+func UpdateTopicHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		current, ok := findTopicFromParam(context)
+		if !ok {
+			return
+		}
+
+		var body topicRequest
+		if err := context.ShouldBindJSON(&body); err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "JSON is invalid",
+			})
+			return
+		}
+		body.Name = strings.TrimSpace(body.Name)
+		if body.Name == "" {
+			context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "topic should not be empty",
+			})
+			return
+		}
+
+		userAny, _ := context.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		now := time.Now()
+		topic, err := services.UpdateTopic(context, current, models.Topic{
+			Name:        body.Name,
+			Description: strings.TrimSpace(body.Description),
+			ParentId:    body.ParentId,
+			UpdatedBy:   user.Username,
+			UpdatedAt:   &now,
+		})
+		if err != nil {
+			abortWithTopicError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{
+			"topic": topic,
+		})
+	}
+}
+
+// DeleteTopicHandler removes a topic that has no subtopics, questions,
+// assessments or quiz attempts.
+func DeleteTopicHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		topic, ok := findTopicFromParam(context)
+		if !ok {
+			return
+		}
+
+		if err := services.DeleteTopic(context, topic); err != nil {
+			abortWithTopicError(context, err)
+			return
+		}
+
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func findTopicFromParam(context *gin.Context) (models.Topic, bool) {
+	id, err := primitive.ObjectIDFromHex(context.Param("id"))
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Topic ID is in the wrong format",
+		})
+		return models.Topic{}, false
+	}
+
+	topic, err := services.GetTopicById(context, id)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "Topic with given ID not found",
+		})
+		return models.Topic{}, false
+	}
+	return topic, true
+}
 
-		ctx.JSON(http.StatusOK, topics)
+func abortWithTopicError(context *gin.Context, err error) {
+	if topicErr, ok := err.(*services.TopicError); ok {
+		context.AbortWithStatusJSON(topicErr.Status, gin.H{
+			"error": topicErr.Message,
+		})
+		return
 	}
+	context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+		"error": "Server error. Please try again later",
+	})
 }
@@ -4,11 +4,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
 	"github.com/zeekhoks/quiz-backend/services"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -35,11 +37,43 @@ func init() {
 func TestGetAllTopics(t *testing.T) {
 	// Set up the router
 	router := gin.Default()
-	router.GET("/topics", GetAllTopics())
+	router.GET("/topics", func(ctx *gin.Context) {
+		ctx.Set("loggedInAccount", models.User{Username: "jane_doe", IsAdmin: true})
+	}, GetAllTopics())
 
 	// Test case: Get all topics
 	req, _ := http.NewRequest("GET", "/topics", nil)
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Test case: Invalid sort, limit or cursor
+	for _, query := range []string{"sort=size", "limit=0", "limit=1000", "cursor=invalid"} {
+		req, _ = http.NewRequest("GET", "/topics?"+query, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestTopicHandlers(t *testing.T) {
+	router := gin.Default()
+	router.GET("/topics/:id", GetTopicHandler())
+	router.POST("/topics", CreateTopicHandler())
+	router.PUT("/topics/:id", UpdateTopicHandler())
+	router.DELETE("/topics/:id", DeleteTopicHandler())
+
+	// Test case: Invalid topic ID
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		req, _ := http.NewRequest(method, "/topics/invalid", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	}
+
+	// Test case: A topic needs a name
+	req, _ := http.NewRequest("POST", "/topics", strings.NewReader(`{"topic": " "}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
@@ -5,9 +5,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zeekhoks/quiz-backend/models"
 	"github.com/zeekhoks/quiz-backend/services"
+	"go.mongodb.org/mongo-driver/mongo"
 	"log"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -21,6 +24,14 @@ func CreateNewUser() gin.HandlerFunc {
 			return
 		}
 
+		// roles are granted by an admin, never at signup
+		user.Roles = nil
+		user.Locale = services.NormalizeLocale(user.Locale)
+		if user.Locale != "" && !services.IsLocale(user.Locale) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Locale should be a language tag such as hi or hi-IN"})
+			return
+		}
+
 		userExists, _ := services.UserExists(user.Username)
 		if userExists {
 			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "User already exists"})
@@ -53,8 +64,8 @@ func LoginHandler() gin.HandlerFunc {
 		}
 
 		claims := models.MyUserClaims{
-			user,
-			jwt.StandardClaims{
+			User: user,
+			StandardClaims: jwt.StandardClaims{
 				ExpiresAt: time.Now().Add(time.Minute * 45).Unix(),
 			},
 		}
@@ -76,3 +87,147 @@ func LoginHandler() gin.HandlerFunc {
 		}
 	}
 }
+
+func SetAccommodationHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		username := ctx.Param("username")
+
+		var body struct {
+			TimeMultiplier float64 `json:"time_multiplier"`
+			Note           string  `json:"note"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "JSON is invalid"})
+			return
+		}
+
+		if body.TimeMultiplier < 1 || body.TimeMultiplier > 4 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "time_multiplier should be between 1 and 4"})
+			return
+		}
+
+		userExists, err := services.UserExists(username)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error. Try again"})
+			return
+		}
+		if !userExists {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User with given username not found"})
+			return
+		}
+
+		val, _ := ctx.Get("loggedInAccount")
+		admin := val.(models.User)
+
+		accommodation := models.Accommodation{
+			Username:       username,
+			TimeMultiplier: body.TimeMultiplier,
+			Note:           body.Note,
+			ApprovedBy:     admin.Username,
+			UpdatedAt:      time.Now(),
+		}
+
+		if err := services.UpsertAccommodation(ctx, accommodation); err != nil {
+			log.Println("Failed to save accommodation", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Unable to save accommodation"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, accommodation)
+	}
+}
+
+func GetAccommodationHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accommodation, err := services.GetAccommodation(ctx, ctx.Param("username"))
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No accommodation found for this user"})
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error. Try again"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, accommodation)
+	}
+}
+
+func SetUserRolesHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body struct {
+			Roles []string `json:"roles"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "JSON is invalid"})
+			return
+		}
+
+		roles := make([]string, 0, len(body.Roles))
+		for _, role := range body.Roles {
+			role = strings.ToLower(strings.TrimSpace(role))
+			if role != models.RoleAuthor && role != models.RoleReviewer && role != models.RoleInstructor {
+				ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "roles should only contain author, reviewer or instructor"})
+				return
+			}
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+
+		found, err := services.SetUserRoles(ctx, ctx.Param("username"), roles)
+		if err != nil {
+			log.Println("Failed to update roles", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Unable to update roles"})
+			return
+		}
+		if !found {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User with given username not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"username": ctx.Param("username"),
+			"roles":    roles,
+		})
+	}
+}
+
+// SetLocaleHandler sets the language the logged-in user's quizzes are
+// generated in. An empty locale goes back to Accept-Language.
+func SetLocaleHandler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var body struct {
+			Locale string `json:"locale"`
+		}
+		if err := ctx.ShouldBindJSON(&body); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "JSON is invalid"})
+			return
+		}
+
+		locale := services.NormalizeLocale(body.Locale)
+		if locale != "" && !services.IsLocale(locale) {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Locale should be a language tag such as hi or hi-IN"})
+			return
+		}
+
+		userAny, _ := ctx.Get("loggedInAccount")
+		user := userAny.(models.User)
+
+		found, err := services.SetUserLocale(ctx, user.Username, locale)
+		if err != nil {
+			log.Println("Failed to update locale", err)
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Unable to update locale"})
+			return
+		}
+		if !found {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "User with given username not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"username": user.Username,
+			"locale":   locale,
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 func BasicAuth() gin.HandlerFunc {
@@ -66,6 +67,45 @@ func AdminCheck() gin.HandlerFunc {
 	}
 }
 
+func RoleCheck(roles ...string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+
+		val, _ := context.Get("loggedInAccount")
+
+		user, ok := val.(models.User)
+
+		if !ok {
+			context.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Internal server error. Try again",
+			})
+			return
+		}
+
+		if !user.HasRole(roles...) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "You don't have access to make this request",
+			})
+			return
+		}
+		context.Next()
+	}
+}
+
+// MediaAuth lets signed media URLs through without a token, since browsers
+// can't add an Authorization header to image or audio requests. Anything
+// else needs a logged-in user.
+func MediaAuth() gin.HandlerFunc {
+	extractUser := UserExtractor()
+	return func(context *gin.Context) {
+		query := context.Request.URL.Query()
+		if services.VerifyMediaSignature(context.Param("id"), query.Get("expires"), query.Get("signature"), time.Now()) {
+			context.Next()
+			return
+		}
+		extractUser(context)
+	}
+}
+
 func UserExtractor() gin.HandlerFunc {
 	return func(context *gin.Context) {
 		authorizationHeader := context.Request.Header.Get("Authorization")
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/zeekhoks/quiz-backend/services"
+	"net/http"
+	"strings"
+)
+
+// Localize translates the messages of failed requests into the language
+// asked for in Accept-Language. Successful responses, including streamed
+// exports, pass through untouched.
+func Localize() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		locale := services.MessageLocale(context.GetHeader("Accept-Language"))
+		if locale == services.DefaultLocale {
+			context.Next()
+			return
+		}
+
+		writer := &localizedWriter{ResponseWriter: context.Writer, locale: locale}
+		context.Writer = writer
+		context.Next()
+		writer.flush()
+		context.Writer = writer.ResponseWriter
+	}
+}
+
+// localizedWriter holds back the body of error responses so their messages
+// can be translated once the handler is done.
+type localizedWriter struct {
+	gin.ResponseWriter
+	locale string
+	body   bytes.Buffer
+}
+
+func (writer *localizedWriter) buffering() bool {
+	return writer.Status() >= http.StatusBadRequest && !writer.ResponseWriter.Written()
+}
+
+func (writer *localizedWriter) Write(data []byte) (int, error) {
+	if writer.buffering() {
+		return writer.body.Write(data)
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *localizedWriter) WriteString(s string) (int, error) {
+	if writer.buffering() {
+		return writer.body.WriteString(s)
+	}
+	return writer.ResponseWriter.WriteString(s)
+}
+
+func (writer *localizedWriter) flush() {
+	if writer.body.Len() == 0 {
+		return
+	}
+	content := writer.body.Bytes()
+	if strings.HasPrefix(writer.Header().Get("Content-Type"), "application/json") {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		var body interface{}
+		if decoder.Decode(&body) == nil {
+			if translated, err := json.Marshal(translateMessages(writer.locale, body, false)); err == nil {
+				content = translated
+				writer.Header().Set("Content-Language", writer.locale)
+			}
+		}
+	}
+	writer.body.Reset()
+	writer.ResponseWriter.Write(content)
+}
+
+// translateMessages translates the strings under "error", "errors" and
+// "details" anywhere in a response body.
+func translateMessages(locale string, value interface{}, message bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			value[key] = translateMessages(locale, field, key == "error" || key == "errors" || key == "details")
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = translateMessages(locale, item, message)
+		}
+	case string:
+		if message {
+			return services.TranslateMessage(locale, value)
+		}
+	}
+	return value
+}
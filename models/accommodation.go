@@ -0,0 +1,15 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Accommodation struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username       string             `json:"username" bson:"username"`
+	TimeMultiplier float64            `json:"time_multiplier" bson:"time_multiplier"`
+	Note           string             `json:"note,omitempty" bson:"note,omitempty"`
+	ApprovedBy     string             `json:"approved_by" bson:"approved_by"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+const (
+	IRTModelRasch = "rasch"
+	IRTModel2PL   = "2pl"
+)
+
+// IRTParameters are the calibrated item parameters of a question:
+// discrimination (a) and difficulty (b) on the logit scale.
+type IRTParameters struct {
+	Discrimination float64 `json:"discrimination" bson:"discrimination"`
+	Difficulty     float64 `json:"difficulty" bson:"difficulty"`
+}
+
+type AdaptiveConfig struct {
+	Enabled      bool    `json:"enabled" bson:"enabled"`
+	Model        string  `json:"model" bson:"model"`
+	TargetSE     float64 `json:"target_standard_error" bson:"target_standard_error"`
+	MinQuestions int     `json:"min_questions" bson:"min_questions"`
+	MaxQuestions int     `json:"max_questions" bson:"max_questions"`
+}
+
+type AbilityEstimate struct {
+	Theta         float64 `json:"theta" bson:"theta"`
+	StandardError float64 `json:"standard_error" bson:"standard_error"`
+}
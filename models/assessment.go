@@ -0,0 +1,52 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	AttemptScoringBest    = "best"
+	AttemptScoringLast    = "last"
+	AttemptScoringAverage = "average"
+)
+
+type Assessment struct {
+	ID               primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Name             string               `json:"name" bson:"name"`
+	Description      string               `json:"description" bson:"description"`
+	Topic            string               `json:"topic" bson:"topic"`
+	Blueprint        *Blueprint           `json:"blueprint,omitempty" bson:"blueprint,omitempty"`
+	NumQuestions     int                  `json:"num_questions" bson:"num_questions"`
+	TimeLimitMinutes int                  `json:"time_limit_minutes" bson:"time_limit_minutes"`
+	MaxAttempts      int                  `json:"max_attempts" bson:"max_attempts"`
+	CooldownMinutes  int                  `json:"cooldown_minutes" bson:"cooldown_minutes"`
+	AttemptScoring   string               `json:"attempt_scoring" bson:"attempt_scoring"`
+	OpensAt          *time.Time           `json:"opens_at,omitempty" bson:"opens_at,omitempty"`
+	ClosesAt         *time.Time           `json:"closes_at,omitempty" bson:"closes_at,omitempty"`
+	PassingScore     float64              `json:"passing_score" bson:"passing_score"`
+	Scoring          ScoringConfig        `json:"scoring" bson:"scoring"`
+	FeedbackMode     string               `json:"feedback_mode" bson:"feedback_mode"`
+	Adaptive         *AdaptiveConfig      `json:"adaptive,omitempty" bson:"adaptive,omitempty"`
+	ClassIds         []primitive.ObjectID `json:"class_ids,omitempty" bson:"class_ids,omitempty"`
+	CreatedBy        string               `json:"created_by" bson:"created_by"`
+	CreatedAt        time.Time            `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+// IsOpen reports whether attempts can be started at the given time.
+func (assessment *Assessment) IsOpen(now time.Time) bool {
+	if assessment.OpensAt != nil && now.Before(*assessment.OpensAt) {
+		return false
+	}
+	if assessment.ClosesAt != nil && now.After(*assessment.ClosesAt) {
+		return false
+	}
+	return true
+}
+
+// IsAdaptive reports whether questions are picked one at a time from the
+// student's ability estimate.
+func (assessment *Assessment) IsAdaptive() bool {
+	return assessment.Adaptive != nil && assessment.Adaptive.Enabled
+}
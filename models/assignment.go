@@ -0,0 +1,69 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"time"
+)
+
+// Assignment gives the students of a class an assessment to complete
+// between OpensAt and DueAt. For them it replaces the assessment's own
+// window.
+type Assignment struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ClassId      primitive.ObjectID `json:"class_id" bson:"class_id"`
+	AssessmentId primitive.ObjectID `json:"assessment_id" bson:"assessment_id"`
+	OpensAt      *time.Time         `json:"opens_at,omitempty" bson:"opens_at,omitempty"`
+	DueAt        time.Time          `json:"due_at" bson:"due_at"`
+	LatePolicy   LatePolicy         `json:"late_policy" bson:"late_policy"`
+	CreatedBy    string             `json:"created_by" bson:"created_by"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+// LatePolicy decides until when work is accepted after the due date and how
+// much of the score it loses. PenaltyPercent is taken off the score once, or
+// for every started period of PenaltyPeriodHours when that is set, up to
+// MaxPenaltyPercent.
+type LatePolicy struct {
+	AcceptUntil        *time.Time `json:"accept_until,omitempty" bson:"accept_until,omitempty"`
+	PenaltyPercent     float64    `json:"penalty_percent" bson:"penalty_percent"`
+	PenaltyPeriodHours int        `json:"penalty_period_hours" bson:"penalty_period_hours"`
+	MaxPenaltyPercent  float64    `json:"max_penalty_percent" bson:"max_penalty_percent"`
+}
+
+// HasOpened reports whether attempts can be started yet.
+func (assignment *Assignment) HasOpened(now time.Time) bool {
+	return assignment.OpensAt == nil || !now.Before(*assignment.OpensAt)
+}
+
+// Deadline is the last moment work is accepted, late work included.
+func (assignment *Assignment) Deadline() time.Time {
+	if assignment.LatePolicy.AcceptUntil != nil && assignment.LatePolicy.AcceptUntil.After(assignment.DueAt) {
+		return *assignment.LatePolicy.AcceptUntil
+	}
+	return assignment.DueAt
+}
+
+// IsOpen reports whether attempts can be started at the given time, on time
+// or late.
+func (assignment *Assignment) IsOpen(now time.Time) bool {
+	return assignment.HasOpened(now) && !now.After(assignment.Deadline())
+}
+
+// Penalty returns the percentage of the score lost by work handed in late
+// by the given duration.
+func (policy *LatePolicy) Penalty(late time.Duration) float64 {
+	if late <= 0 || policy.PenaltyPercent <= 0 {
+		return 0
+	}
+	penalty := policy.PenaltyPercent
+	if policy.PenaltyPeriodHours > 0 {
+		period := time.Duration(policy.PenaltyPeriodHours) * time.Hour
+		penalty *= math.Ceil(float64(late) / float64(period))
+	}
+	if policy.MaxPenaltyPercent > 0 {
+		penalty = math.Min(penalty, policy.MaxPenaltyPercent)
+	}
+	return math.Min(penalty, 100)
+}
@@ -0,0 +1,24 @@
+package models
+
+// Blueprint describes how many questions of which kind an assessment draws,
+// e.g. five easy and three hard questions tagged "chapter-2".
+type Blueprint struct {
+	Sections []BlueprintSection `json:"sections" bson:"sections"`
+}
+
+type BlueprintSection struct {
+	Name       string   `json:"name" bson:"name"`
+	Count      int      `json:"count" bson:"count"`
+	Topic      string   `json:"topic,omitempty" bson:"topic,omitempty"`
+	Tags       []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty" bson:"difficulty,omitempty"`
+}
+
+// TotalQuestions returns the number of questions the blueprint asks for.
+func (blueprint *Blueprint) TotalQuestions() int {
+	total := 0
+	for _, section := range blueprint.Sections {
+		total += section.Count
+	}
+	return total
+}
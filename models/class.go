@@ -0,0 +1,34 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"slices"
+	"time"
+)
+
+// Class groups students under one or more instructors. Assessments assigned
+// to classes are only offered to their members.
+type Class struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	Instructors []string           `json:"instructors" bson:"instructors"`
+	Students    []string           `json:"students" bson:"students"`
+	JoinCode    string             `json:"join_code,omitempty" bson:"join_code,omitempty"`
+	CreatedBy   string             `json:"created_by" bson:"created_by"`
+	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+func (class *Class) HasInstructor(username string) bool {
+	return slices.Contains(class.Instructors, username)
+}
+
+func (class *Class) HasStudent(username string) bool {
+	return slices.Contains(class.Students, username)
+}
+
+// CanManage reports whether a user may change the class and its roster.
+func (class *Class) CanManage(user User) bool {
+	return user.IsAdmin || class.HasInstructor(user.Username)
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	ItemFlagInsufficientData        = "insufficient_data"
+	ItemFlagTooEasy                 = "too_easy"
+	ItemFlagTooHard                 = "too_hard"
+	ItemFlagLowDiscrimination       = "low_discrimination"
+	ItemFlagNegativeDiscrimination  = "negative_discrimination"
+	ItemFlagNonFunctionalDistractor = "non_functional_distractor"
+)
+
+type ItemStatistics struct {
+	QuestionId               primitive.ObjectID `json:"question_id" bson:"question_id"`
+	Topic                    string             `json:"topic" bson:"topic"`
+	Question                 string             `json:"question" bson:"question"`
+	Revision                 int                `json:"revision" bson:"revision"`
+	Exposures                int                `json:"exposures" bson:"exposures"`
+	Responses                int                `json:"responses" bson:"responses"`
+	Omissions                int                `json:"omissions" bson:"omissions"`
+	PValue                   float64            `json:"p_value" bson:"p_value"`
+	PointBiserial            float64            `json:"point_biserial" bson:"point_biserial"`
+	OptionRates              map[string]float64 `json:"option_rates" bson:"option_rates"`
+	NonFunctionalDistractors []string           `json:"non_functional_distractors" bson:"non_functional_distractors"`
+	Flags                    []string           `json:"flags" bson:"flags"`
+	ComputedAt               time.Time          `json:"computed_at" bson:"computed_at"`
+}
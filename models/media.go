@@ -0,0 +1,28 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Media struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Filename    string             `json:"filename" bson:"filename"`
+	ContentType string             `json:"content_type" bson:"content_type"`
+	Size        int64              `json:"size" bson:"size"`
+	Checksum    string             `json:"checksum" bson:"checksum"`
+	Storage     string             `json:"-" bson:"storage"`
+	UploadedBy  string             `json:"uploaded_by" bson:"uploaded_by"`
+	UploadedAt  time.Time          `json:"uploaded_at" bson:"uploaded_at"`
+	URL         string             `json:"url,omitempty" bson:"-"`
+}
+
+// MediaReference attaches uploaded media to a question's stem or, when
+// Option is set, to one of its options. URL is signed when the question is
+// served and never stored.
+type MediaReference struct {
+	MediaId primitive.ObjectID `json:"media_id" bson:"media_id"`
+	Option  string             `json:"option,omitempty" bson:"option,omitempty"`
+	Alt     string             `json:"alt,omitempty" bson:"alt,omitempty"`
+	URL     string             `json:"url,omitempty" bson:"-"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const NotificationTypeRegrade = "regrade"
+
+type Notification struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username  string             `json:"username" bson:"username"`
+	Type      string             `json:"type" bson:"type"`
+	Message   string             `json:"message" bson:"message"`
+	QuizId    primitive.ObjectID `json:"quiz_id,omitempty" bson:"quiz_id,omitempty"`
+	Read      bool               `json:"read" bson:"read"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -1,19 +1,155 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	QuestionTypeSingleChoice   = "single_choice"
+	QuestionTypeMultipleChoice = "multiple_choice"
+)
+
+const (
+	QuestionStatusDraft     = "draft"
+	QuestionStatusInReview  = "in_review"
+	QuestionStatusPublished = "published"
+	QuestionStatusRejected  = "rejected"
+	QuestionStatusRetired   = "retired"
+)
+
+// Content formats of question, option and explanation text. Questions
+// without a format are plain text.
+const (
+	ContentFormatPlain        = "plain"
+	ContentFormatMarkdown     = "markdown"
+	ContentFormatMarkdownMath = "markdown_math"
+)
+
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
 
 type Question struct {
-	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	QuestionName  string             `json:"question" bson:"question"`
-	Options       []string           `json:"options" bson:"options"`
-	CorrectAnswer string             `json:"-" bson:"correct_answer"`
-	Distractors   []string           `json:"-" bson:"distractors"`
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Topic          string             `json:"topic" bson:"topic"`
+	QuestionName   string             `json:"question" bson:"question"`
+	Format         string             `json:"format,omitempty" bson:"format,omitempty"`
+	Type           string             `json:"type" bson:"type,omitempty"`
+	Points         float64            `json:"points" bson:"points,omitempty"`
+	Options        []string           `json:"options" bson:"options"`
+	CorrectAnswer  string             `json:"-" bson:"correct_answer"`
+	CorrectAnswers []string           `json:"-" bson:"correct_answers,omitempty"`
+	Distractors    []string           `json:"-" bson:"distractors"`
+	Explanation    string             `json:"-" bson:"explanation,omitempty"`
+	OptionFeedback map[string]string  `json:"-" bson:"option_feedback,omitempty"`
+	IRT            *IRTParameters     `json:"-" bson:"irt,omitempty"`
+	Tags           []string           `json:"-" bson:"tags,omitempty"`
+	Difficulty     string             `json:"-" bson:"difficulty,omitempty"`
+	Media          []MediaReference   `json:"media,omitempty" bson:"media,omitempty"`
+	Template       *QuestionTemplate  `json:"-" bson:"template,omitempty"`
+	Variables      map[string]float64 `json:"-" bson:"variables,omitempty"`
+	Translations   Translations       `json:"-" bson:"translations,omitempty"`
+	Locale         string             `json:"locale,omitempty" bson:"locale,omitempty"`
+	Revision       int                `json:"revision" bson:"revision"`
+	HTML           *RenderedContent   `json:"html,omitempty" bson:"-"`
 }
 
 type QuestionUnmarshal struct {
-	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	QuestionName  string             `json:"question" bson:"question"`
-	Options       []string           `json:"options" bson:"options"`
-	CorrectAnswer string             `json:"correct_answer" bson:"correct_answer"`
-	Distractors   []string           `json:"distractors" bson:"distractors"`
+	ID             primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Topic          string               `json:"topic" bson:"topic"`
+	QuestionName   string               `json:"question" bson:"question"`
+	Format         string               `json:"format,omitempty" bson:"format,omitempty"`
+	Type           string               `json:"type,omitempty" bson:"type,omitempty"`
+	Points         float64              `json:"points,omitempty" bson:"points,omitempty"`
+	Options        []string             `json:"options" bson:"options"`
+	CorrectAnswer  string               `json:"correct_answer" bson:"correct_answer"`
+	CorrectAnswers []string             `json:"correct_answers,omitempty" bson:"correct_answers,omitempty"`
+	Distractors    []string             `json:"distractors" bson:"distractors"`
+	Explanation    string               `json:"explanation,omitempty" bson:"explanation,omitempty"`
+	OptionFeedback map[string]string    `json:"option_feedback,omitempty" bson:"option_feedback,omitempty"`
+	IRT            *IRTParameters       `json:"irt,omitempty" bson:"irt,omitempty"`
+	Tags           []string             `json:"tags,omitempty" bson:"tags,omitempty"`
+	Difficulty     string               `json:"difficulty,omitempty" bson:"difficulty,omitempty"`
+	Media          []MediaReference     `json:"media,omitempty" bson:"media,omitempty"`
+	Template       *QuestionTemplate    `json:"template,omitempty" bson:"template,omitempty"`
+	Translations   Translations         `json:"translations,omitempty" bson:"translations,omitempty"`
+	Revision       int                  `json:"revision" bson:"revision"`
+	UpdatedBy      string               `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	UpdatedAt      *time.Time           `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	Status         string               `json:"status,omitempty" bson:"status,omitempty"`
+	CreatedBy      string               `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	ReviewLog      []ReviewEvent        `json:"review_log,omitempty" bson:"review_log,omitempty"`
+	DuplicateOf    []primitive.ObjectID `json:"duplicate_of,omitempty" bson:"duplicate_of,omitempty"`
+	HTML           *RenderedContent     `json:"html,omitempty" bson:"-"`
+}
+
+// RenderedContent is the sanitized HTML of a question's text, returned when
+// a client asks for it instead of rendering the source itself.
+type RenderedContent struct {
+	Question       string            `json:"question"`
+	Options        []string          `json:"options"`
+	Explanation    string            `json:"explanation,omitempty"`
+	OptionFeedback map[string]string `json:"option_feedback,omitempty"`
+}
+
+// Translations holds a question's text in other languages, keyed by a
+// lowercase locale such as "hi" or "hi-in".
+type Translations map[string]QuestionTranslation
+
+// QuestionTranslation is the text of a question in one language. Options are
+// translated by position, and feedback is keyed by the translated option.
+// Anything left empty is shown in the original language.
+type QuestionTranslation struct {
+	Question       string            `json:"question" bson:"question"`
+	Options        []string          `json:"options,omitempty" bson:"options,omitempty"`
+	Explanation    string            `json:"explanation,omitempty" bson:"explanation,omitempty"`
+	OptionFeedback map[string]string `json:"option_feedback,omitempty" bson:"option_feedback,omitempty"`
+}
+
+type ReviewEvent struct {
+	Action  string    `json:"action" bson:"action"`
+	From    string    `json:"from" bson:"from"`
+	To      string    `json:"to" bson:"to"`
+	By      string    `json:"by" bson:"by"`
+	Comment string    `json:"comment,omitempty" bson:"comment,omitempty"`
+	At      time.Time `json:"at" bson:"at"`
+}
+
+type QuestionRevision struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	QuestionId primitive.ObjectID `json:"question_id" bson:"question_id"`
+	Revision   int                `json:"revision" bson:"revision"`
+	Snapshot   QuestionUnmarshal  `json:"snapshot" bson:"snapshot"`
+	Changes    []string           `json:"changes" bson:"changes"`
+	Comment    string             `json:"comment,omitempty" bson:"comment,omitempty"`
+	ChangedBy  string             `json:"changed_by" bson:"changed_by"`
+	ChangedAt  time.Time          `json:"changed_at" bson:"changed_at"`
+}
+
+// IsMultipleChoice reports whether more than one option can be selected.
+func (question *Question) IsMultipleChoice() bool {
+	return question.Type == QuestionTypeMultipleChoice
+}
+
+// MaxPoints returns the weight of the question, defaulting to one point.
+func (question *Question) MaxPoints() float64 {
+	if question.Points <= 0 {
+		return 1
+	}
+	return question.Points
+}
+
+func IsDifficulty(level string) bool {
+	return level == DifficultyEasy || level == DifficultyMedium || level == DifficultyHard
+}
+
+func IsQuestionStatus(status string) bool {
+	switch status {
+	case QuestionStatusDraft, QuestionStatusInReview, QuestionStatusPublished, QuestionStatusRejected, QuestionStatusRetired:
+		return true
+	}
+	return false
 }
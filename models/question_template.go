@@ -0,0 +1,40 @@
+package models
+
+// QuestionTemplate makes a question algorithmic: every quiz draws its own
+// values for the variables, fills them into {name} placeholders in the text
+// and computes the answer and distractors from formulas, e.g. "a + b".
+type QuestionTemplate struct {
+	Variables   []TemplateVariable `json:"variables" bson:"variables"`
+	Answer      string             `json:"answer" bson:"answer"`
+	Distractors []string           `json:"distractors" bson:"distractors"`
+	Decimals    *int               `json:"decimals,omitempty" bson:"decimals,omitempty"`
+}
+
+// TemplateVariable is drawn from Min, Min+Step, ... up to Max. Step defaults
+// to one.
+type TemplateVariable struct {
+	Name string  `json:"name" bson:"name"`
+	Min  float64 `json:"min" bson:"min"`
+	Max  float64 `json:"max" bson:"max"`
+	Step float64 `json:"step,omitempty" bson:"step,omitempty"`
+}
+
+// DefaultTemplateDecimals is how many decimal places computed answers are
+// rounded to when a template does not say.
+const DefaultTemplateDecimals = 2
+
+// AnswerDecimals returns the number of decimal places answers are rounded to.
+func (template *QuestionTemplate) AnswerDecimals() int {
+	if template.Decimals == nil {
+		return DefaultTemplateDecimals
+	}
+	return *template.Decimals
+}
+
+// VariableStep returns the step between values, defaulting to one.
+func (variable *TemplateVariable) VariableStep() float64 {
+	if variable.Step <= 0 {
+		return 1
+	}
+	return variable.Step
+}
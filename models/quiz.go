@@ -5,13 +5,85 @@ import (
 	"time"
 )
 
+const (
+	FeedbackImmediate    = "immediate"
+	FeedbackOnCompletion = "on_completion"
+	FeedbackAfterClose   = "after_close"
+	FeedbackNever        = "never"
+)
+
 type Quiz struct {
-	Id            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	User          User               `json:"user" bson:"user"`
-	Topic         string             `json:"topic" bson:"topic"`
-	Questions     []Question         `json:"questions" bson:"questions"`
-	UserResponses []UserResponse     `json:"-" bson:"user_responses"`
-	Completed     bool               `json:"-" bson:"completed"`
-	StartTime     time.Time          `json:"start_time" bson:"start_time"`
-	EndTime       time.Time          `json:"end_time" bson:"end_time"`
+	Id               primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	User             User                `json:"user" bson:"user"`
+	Topic            string              `json:"topic" bson:"topic"`
+	AssessmentId     primitive.ObjectID  `json:"assessment_id,omitempty" bson:"assessment_id,omitempty"`
+	AssignmentId     *primitive.ObjectID `json:"assignment_id,omitempty" bson:"assignment_id,omitempty"`
+	DueAt            *time.Time          `json:"due_at,omitempty" bson:"due_at,omitempty"`
+	LatePolicy       *LatePolicy         `json:"late_policy,omitempty" bson:"late_policy,omitempty"`
+	Attempt          int                 `json:"attempt" bson:"attempt"`
+	PassingScore     float64             `json:"passing_score" bson:"passing_score"`
+	Scoring          ScoringConfig       `json:"scoring" bson:"scoring"`
+	Score            *QuizScore          `json:"-" bson:"score,omitempty"`
+	FeedbackMode     string              `json:"feedback_mode" bson:"feedback_mode"`
+	FeedbackAt       *time.Time          `json:"feedback_at,omitempty" bson:"feedback_at,omitempty"`
+	Adaptive         *AdaptiveConfig     `json:"adaptive,omitempty" bson:"adaptive,omitempty"`
+	Locale           string              `json:"locale,omitempty" bson:"locale,omitempty"`
+	Ability          *AbilityEstimate    `json:"-" bson:"ability,omitempty"`
+	Questions        []Question          `json:"questions" bson:"questions"`
+	UserResponses    []UserResponse      `json:"-" bson:"user_responses"`
+	Completed        bool                `json:"-" bson:"completed"`
+	CompletedAt      *time.Time          `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	StartTime        time.Time           `json:"start_time" bson:"start_time"`
+	EndTime          time.Time           `json:"end_time" bson:"end_time"`
+	TimeLimitSeconds int64               `json:"time_limit_seconds" bson:"time_limit_seconds"`
+	TimeMultiplier   float64             `json:"time_multiplier" bson:"time_multiplier"`
+	Paused           bool                `json:"paused" bson:"paused"`
+	Pauses           []QuizPause         `json:"pauses" bson:"pauses"`
+	PausedSeconds    int64               `json:"paused_seconds" bson:"paused_seconds"`
+	ElapsedSeconds   int64               `json:"elapsed_seconds" bson:"elapsed_seconds"`
+}
+
+type QuizPause struct {
+	PausedAt  time.Time  `json:"paused_at" bson:"paused_at"`
+	PausedBy  string     `json:"paused_by" bson:"paused_by"`
+	Reason    string     `json:"reason,omitempty" bson:"reason,omitempty"`
+	ResumedAt *time.Time `json:"resumed_at,omitempty" bson:"resumed_at,omitempty"`
+	ResumedBy string     `json:"resumed_by,omitempty" bson:"resumed_by,omitempty"`
+}
+
+// Elapsed returns the active time spent on the quiz, excluding any pauses.
+// While the quiz is paused the clock stays frozen at the start of the pause.
+func (quiz *Quiz) Elapsed(now time.Time) time.Duration {
+	if quiz.Paused && len(quiz.Pauses) > 0 {
+		now = quiz.Pauses[len(quiz.Pauses)-1].PausedAt
+	}
+	elapsed := now.Sub(quiz.StartTime) - time.Duration(quiz.PausedSeconds)*time.Second
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
+}
+
+// Remaining returns the time left before the quiz expires. Quizzes created
+// before time limits were stored fall back to their EndTime.
+func (quiz *Quiz) Remaining(now time.Time) time.Duration {
+	if quiz.TimeLimitSeconds == 0 {
+		return quiz.EndTime.Sub(now)
+	}
+	return time.Duration(quiz.TimeLimitSeconds)*time.Second - quiz.Elapsed(now)
+}
+
+// FeedbackVisible reports whether the student may see correct answers and
+// per-question results. Quizzes without a feedback mode behave as immediate.
+func (quiz *Quiz) FeedbackVisible(now time.Time) bool {
+	switch quiz.FeedbackMode {
+	case FeedbackNever:
+		return false
+	case FeedbackOnCompletion:
+		return quiz.Completed
+	case FeedbackAfterClose:
+		return quiz.Completed && (quiz.FeedbackAt == nil || !now.Before(*quiz.FeedbackAt))
+	default:
+		return true
+	}
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	RegradeScopeQuestion = "question"
+	RegradeScopeTopic    = "topic"
+)
+
+// RegradeAudit records a bulk regrade so score changes can be traced back to
+// the answer-key fix that caused them.
+type RegradeAudit struct {
+	ID                 primitive.ObjectID   `json:"id" bson:"_id,omitempty"`
+	Scope              string               `json:"scope" bson:"scope"`
+	Topic              string               `json:"topic,omitempty" bson:"topic,omitempty"`
+	QuestionIds        []primitive.ObjectID `json:"question_ids" bson:"question_ids"`
+	Reason             string               `json:"reason,omitempty" bson:"reason,omitempty"`
+	RequestedBy        string               `json:"requested_by" bson:"requested_by"`
+	Notify             bool                 `json:"notify" bson:"notify"`
+	StartedAt          time.Time            `json:"started_at" bson:"started_at"`
+	FinishedAt         *time.Time           `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	QuizzesUpdated     int                  `json:"quizzes_updated" bson:"quizzes_updated"`
+	ResponsesChanged   int                  `json:"responses_changed" bson:"responses_changed"`
+	ScoresChanged      int                  `json:"scores_changed" bson:"scores_changed"`
+	NotificationsSent  int                  `json:"notifications_sent" bson:"notifications_sent"`
+	SkippedQuestionIds []primitive.ObjectID `json:"skipped_question_ids,omitempty" bson:"skipped_question_ids,omitempty"`
+	Error              string               `json:"error,omitempty" bson:"error,omitempty"`
+}
+
+type RegradeChange struct {
+	ID               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	AuditId          primitive.ObjectID `json:"audit_id" bson:"audit_id"`
+	QuizId           primitive.ObjectID `json:"quiz_id" bson:"quiz_id"`
+	Username         string             `json:"username" bson:"username"`
+	ResponsesChanged int                `json:"responses_changed" bson:"responses_changed"`
+	ScoreBefore      *QuizScore         `json:"score_before,omitempty" bson:"score_before,omitempty"`
+	ScoreAfter       *QuizScore         `json:"score_after,omitempty" bson:"score_after,omitempty"`
+}
@@ -3,8 +3,12 @@ package models
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type UserResponse struct {
-	QuestionId    primitive.ObjectID `json:"question_id" bson:"question_id"`
-	Response      string             `json:"response" bson:"response"`
-	Result        string             `json:"result" bson:"result"`
-	CorrectAnswer string             `json:"correct_answer" bson:"correct_answer"`
+	QuestionId        primitive.ObjectID `json:"question_id" bson:"question_id"`
+	QuestionRevision  int                `json:"question_revision" bson:"question_revision"`
+	AnswerKeyRevision int                `json:"answer_key_revision" bson:"answer_key_revision"`
+	Response          string             `json:"response" bson:"response"`
+	Responses         []string           `json:"responses,omitempty" bson:"responses,omitempty"`
+	Result            string             `json:"result" bson:"result"`
+	Credit            float64            `json:"credit" bson:"credit"`
+	CorrectAnswer     string             `json:"correct_answer" bson:"correct_answer"`
 }
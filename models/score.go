@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	ScoringPolicyPercentage = "percentage"
+	ScoringPolicyWeighted   = "weighted"
+)
+
+type ScoringConfig struct {
+	Policy          string  `json:"policy" bson:"policy"`
+	PartialCredit   bool    `json:"partial_credit" bson:"partial_credit"`
+	NegativeMarking float64 `json:"negative_marking" bson:"negative_marking"`
+}
+
+type QuizScore struct {
+	Policy     string  `json:"policy" bson:"policy"`
+	Points     float64 `json:"points" bson:"points"`
+	MaxPoints  float64 `json:"max_points" bson:"max_points"`
+	Percentage float64 `json:"percentage" bson:"percentage"`
+	Passed     bool    `json:"passed" bson:"passed"`
+
+	// Late work keeps its points and percentage before the late penalty was
+	// taken off in RawPoints and RawPercentage.
+	Late          bool    `json:"late,omitempty" bson:"late,omitempty"`
+	LatePenalty   float64 `json:"late_penalty,omitempty" bson:"late_penalty,omitempty"`
+	RawPoints     float64 `json:"raw_points,omitempty" bson:"raw_points,omitempty"`
+	RawPercentage float64 `json:"raw_percentage,omitempty" bson:"raw_percentage,omitempty"`
+
+	Correct    int       `json:"correct" bson:"correct"`
+	Partial    int       `json:"partial" bson:"partial"`
+	Incorrect  int       `json:"incorrect" bson:"incorrect"`
+	Unanswered int       `json:"unanswered" bson:"unanswered"`
+	ComputedAt time.Time `json:"computed_at" bson:"computed_at"`
+}
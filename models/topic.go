@@ -0,0 +1,26 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Topic groups questions and assessments, which refer to it by name. A topic
+// may sit under a parent, e.g. "Algebra" under "Mathematics".
+type Topic struct {
+	ID          primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Name        string              `json:"topic" bson:"topic"`
+	Description string              `json:"description,omitempty" bson:"description,omitempty"`
+	ParentId    *primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	CreatedBy   string              `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	CreatedAt   *time.Time          `json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedBy   string              `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	UpdatedAt   *time.Time          `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+
+	// QuestionCount counts the questions of this topic that aren't retired,
+	// and TotalQuestionCount adds those of all its subtopics.
+	QuestionCount          int `json:"question_count" bson:"-"`
+	TotalQuestionCount     int `json:"total_question_count" bson:"-"`
+	PublishedQuestionCount int `json:"published_question_count" bson:"-"`
+	AttemptCount           int `json:"attempt_count" bson:"-"`
+}
@@ -2,6 +2,12 @@ package models
 
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
+const (
+	RoleAuthor     = "author"
+	RoleReviewer   = "reviewer"
+	RoleInstructor = "instructor"
+)
+
 type User struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty"`
 	FirstName string             `json:"first_name" bson:"first_name"`
@@ -9,4 +15,22 @@ type User struct {
 	Username  string             `json:"username" bson:"username"`
 	Password  string             `json:"-" bson:"password"`
 	IsAdmin   bool               `json:"is_admin" bson:"is_admin"`
+	Roles     []string           `json:"roles" bson:"roles,omitempty"`
+	Locale    string             `json:"locale,omitempty" bson:"locale,omitempty"`
+}
+
+// HasRole reports whether the user holds any of the given roles. Admins
+// hold every role.
+func (user *User) HasRole(roles ...string) bool {
+	if user.IsAdmin {
+		return true
+	}
+	for _, held := range user.Roles {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
 }
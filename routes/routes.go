@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/zeekhoks/quiz-backend/controllers"
 	"github.com/zeekhoks/quiz-backend/middleware"
+	"github.com/zeekhoks/quiz-backend/models"
 )
 
 func GetRouter() *gin.Engine {
@@ -15,19 +16,84 @@ func GetRouter() *gin.Engine {
 	config.AddAllowHeaders("Authorization")
 
 	router.Use(cors.New(config))
+	router.Use(middleware.Localize())
 
 	apiGroup := router.Group("/api")
 
 	apiGroup.POST("/user", controllers.CreateNewUser())
 	apiGroup.POST("/login", middleware.BasicAuth(), controllers.LoginHandler())
+	apiGroup.PUT("/user/locale", middleware.UserExtractor(), controllers.SetLocaleHandler())
 
-	apiGroup.POST("/questions", middleware.UserExtractor(), middleware.AdminCheck(), controllers.UploadQuestionHandler())
-	apiGroup.GET("/questions", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetDisplayQuestionsByTopicHandler())
+	apiGroup.POST("/questions", middleware.UserExtractor(), middleware.RoleCheck(models.RoleAuthor), controllers.UploadQuestionHandler())
+	apiGroup.GET("/questions", middleware.UserExtractor(), middleware.RoleCheck(models.RoleAuthor, models.RoleReviewer), controllers.GetDisplayQuestionsByTopicHandler())
+	apiGroup.GET("/questions/export", middleware.UserExtractor(), middleware.AdminCheck(), controllers.ExportQuestionsHandler())
+	apiGroup.GET("/questions/duplicates", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetDuplicateReportHandler())
+	apiGroup.GET("/questions/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetQuestionHandler())
+	apiGroup.PUT("/questions/:id", middleware.UserExtractor(), middleware.RoleCheck(models.RoleAuthor), controllers.UpdateQuestionHandler())
+	apiGroup.GET("/questions/:id/qti", middleware.UserExtractor(), middleware.AdminCheck(), controllers.ExportQuestionQTIHandler())
+	apiGroup.GET("/questions/:id/revisions", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetQuestionRevisionsHandler())
+	apiGroup.GET("/questions/:id/revisions/:revision", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetQuestionRevisionHandler())
+	apiGroup.POST("/questions/:id/submit", middleware.UserExtractor(), middleware.RoleCheck(models.RoleAuthor), controllers.SubmitQuestionHandler())
+	apiGroup.POST("/questions/:id/review", middleware.UserExtractor(), middleware.RoleCheck(models.RoleReviewer), controllers.ReviewQuestionHandler())
+	apiGroup.POST("/questions/:id/publish", middleware.UserExtractor(), middleware.AdminCheck(), controllers.PublishQuestionHandler())
+	apiGroup.POST("/questions/:id/retire", middleware.UserExtractor(), middleware.AdminCheck(), controllers.RetireQuestionHandler())
+	apiGroup.POST("/questions/:id/regrade", middleware.UserExtractor(), middleware.AdminCheck(), controllers.RegradeQuestionHandler())
+
+	apiGroup.POST("/media", middleware.UserExtractor(), middleware.RoleCheck(models.RoleAuthor), controllers.UploadMediaHandler())
+	apiGroup.GET("/media/:id", middleware.MediaAuth(), controllers.GetMediaHandler())
+	apiGroup.GET("/media/:id/info", middleware.UserExtractor(), middleware.RoleCheck(models.RoleAuthor, models.RoleReviewer), controllers.GetMediaInfoHandler())
+	apiGroup.DELETE("/media/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.DeleteMediaHandler())
 
 	apiGroup.GET("/topics", middleware.UserExtractor(), controllers.GetAllTopics())
+	apiGroup.POST("/topics", middleware.UserExtractor(), middleware.AdminCheck(), controllers.CreateTopicHandler())
+	apiGroup.GET("/topics/:id", middleware.UserExtractor(), controllers.GetTopicHandler())
+	apiGroup.PUT("/topics/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.UpdateTopicHandler())
+	apiGroup.DELETE("/topics/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.DeleteTopicHandler())
+	apiGroup.POST("/assessments", middleware.UserExtractor(), middleware.AdminCheck(), controllers.CreateAssessmentHandler())
+	apiGroup.GET("/assessments", middleware.UserExtractor(), controllers.GetAssessmentsHandler())
+	apiGroup.GET("/assessments/:id", middleware.UserExtractor(), controllers.GetAssessmentHandler())
+	apiGroup.PUT("/assessments/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.UpdateAssessmentHandler())
+	apiGroup.DELETE("/assessments/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.DeleteAssessmentHandler())
+	apiGroup.POST("/assessments/:id/attempts", middleware.UserExtractor(), controllers.GenerateQuizHandler())
+	apiGroup.GET("/assessments/:id/score", middleware.UserExtractor(), controllers.GetAssessmentScoreHandler())
+
 	apiGroup.POST("/quiz", middleware.UserExtractor(), controllers.GenerateQuizHandler())
 	apiGroup.POST("/quiz/:id/response", middleware.UserExtractor(), controllers.SubmitAnswerHandler())
 	apiGroup.GET("/quiz/:id/result", middleware.UserExtractor(), controllers.QuizResultHandler())
+	apiGroup.GET("/quiz/:id/review", middleware.UserExtractor(), controllers.QuizReviewHandler())
+	apiGroup.POST("/quiz/:id/pause", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.PauseQuizHandler())
+	apiGroup.POST("/quiz/:id/resume", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.ResumeQuizHandler())
+
+	apiGroup.POST("/classes", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.CreateClassHandler())
+	apiGroup.GET("/classes", middleware.UserExtractor(), controllers.GetClassesHandler())
+	apiGroup.POST("/classes/join", middleware.UserExtractor(), controllers.JoinClassHandler())
+	apiGroup.GET("/classes/:id", middleware.UserExtractor(), controllers.GetClassHandler())
+	apiGroup.PUT("/classes/:id", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.UpdateClassHandler())
+	apiGroup.DELETE("/classes/:id", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.DeleteClassHandler())
+	apiGroup.PUT("/classes/:id/instructors", middleware.UserExtractor(), middleware.AdminCheck(), controllers.SetClassInstructorsHandler())
+	apiGroup.POST("/classes/:id/students", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.EnrollStudentsHandler())
+	apiGroup.DELETE("/classes/:id/students/:username", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.UnenrollStudentHandler())
+	apiGroup.POST("/classes/:id/join-code", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.ResetJoinCodeHandler())
+	apiGroup.DELETE("/classes/:id/join-code", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.DisableJoinCodeHandler())
+	apiGroup.POST("/classes/:id/assignments", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.CreateAssignmentHandler())
+	apiGroup.GET("/classes/:id/assignments", middleware.UserExtractor(), controllers.GetAssignmentsHandler())
+	apiGroup.PUT("/classes/:id/assignments/:assignment_id", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.UpdateAssignmentHandler())
+	apiGroup.DELETE("/classes/:id/assignments/:assignment_id", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.DeleteAssignmentHandler())
+	apiGroup.GET("/classes/:id/assignments/:assignment_id/roster", middleware.UserExtractor(), middleware.RoleCheck(models.RoleInstructor), controllers.GetAssignmentRosterHandler())
+
+	apiGroup.GET("/users/:username/accommodation", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetAccommodationHandler())
+	apiGroup.PUT("/users/:username/accommodation", middleware.UserExtractor(), middleware.AdminCheck(), controllers.SetAccommodationHandler())
+	apiGroup.PUT("/users/:username/roles", middleware.UserExtractor(), middleware.AdminCheck(), controllers.SetUserRolesHandler())
+
+	apiGroup.POST("/admin/item-analysis", middleware.UserExtractor(), middleware.AdminCheck(), controllers.RunItemAnalysisHandler())
+	apiGroup.GET("/admin/item-analysis", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetItemAnalysisHandler())
+
+	apiGroup.POST("/admin/regrades", middleware.UserExtractor(), middleware.AdminCheck(), controllers.RegradeHandler())
+	apiGroup.GET("/admin/regrades", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetRegradeAuditsHandler())
+	apiGroup.GET("/admin/regrades/:id", middleware.UserExtractor(), middleware.AdminCheck(), controllers.GetRegradeAuditHandler())
+
+	apiGroup.GET("/notifications", middleware.UserExtractor(), controllers.GetNotificationsHandler())
+	apiGroup.POST("/notifications/:id/read", middleware.UserExtractor(), controllers.MarkNotificationReadHandler())
 
 	return router
 }
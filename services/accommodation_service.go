@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func GetAccommodation(ctx context.Context, username string) (models.Accommodation, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "accommodations")
+	var accommodation models.Accommodation
+	err := collection.FindOne(ctx, bson.M{"username": username}).Decode(&accommodation)
+	return accommodation, err
+}
+
+// GetTimeMultiplier returns the approved time multiplier for a user, or 1 when
+// the user has no accommodation on file.
+func GetTimeMultiplier(ctx context.Context, username string) (float64, error) {
+	accommodation, err := GetAccommodation(ctx, username)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 1, nil
+		}
+		return 1, err
+	}
+	if accommodation.TimeMultiplier <= 0 {
+		return 1, nil
+	}
+	return accommodation.TimeMultiplier, nil
+}
+
+func UpsertAccommodation(ctx context.Context, accommodation models.Accommodation) error {
+	client := GetConnection()
+	collection := GetCollection(client, "accommodations")
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"username": accommodation.Username},
+		bson.M{"$set": bson.M{
+			"username":        accommodation.Username,
+			"time_multiplier": accommodation.TimeMultiplier,
+			"note":            accommodation.Note,
+			"approved_by":     accommodation.ApprovedBy,
+			"updated_at":      accommodation.UpdatedAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
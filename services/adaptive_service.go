@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/rand"
+	"time"
+)
+
+// CalibratedQuestions returns the published questions of a topic that have item
+// parameters, leaving out the ones already asked.
+func CalibratedQuestions(ctx context.Context, topic string, exclude []primitive.ObjectID) ([]models.Question, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+
+	filter := bson.M{
+		"topic":  topic,
+		"irt":    bson.M{"$exists": true},
+		"status": PublishedStatus(),
+	}
+	if len(exclude) != 0 {
+		filter["_id"] = bson.M{"$nin": exclude}
+	}
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]models.Question, 0)
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// AdvanceAdaptiveQuiz re-estimates the student's ability and appends the most
+// informative remaining question to the quiz. It returns nil once the
+// stopping rule is met or the pool is exhausted.
+func AdvanceAdaptiveQuiz(ctx context.Context, quiz *models.Quiz) (*models.Question, error) {
+	estimate := EstimateAbility(AdaptiveResponses(*quiz))
+	quiz.Ability = &estimate
+
+	if ShouldStopAdaptive(*quiz.Adaptive, len(quiz.UserResponses), estimate) {
+		return nil, nil
+	}
+
+	asked := make([]primitive.ObjectID, 0, len(quiz.Questions))
+	for _, question := range quiz.Questions {
+		asked = append(asked, question.ID)
+	}
+
+	pool, err := CalibratedQuestions(ctx, quiz.Topic, asked)
+	if err != nil {
+		return nil, err
+	}
+
+	next := SelectNextItem(estimate.Theta, quiz.Adaptive.Model, pool)
+	if next < 0 {
+		return nil, nil
+	}
+
+	LocalizeQuestion(&pool[next], quiz.Locale)
+	if err = InstantiateQuestion(&pool[next], rand.New(rand.NewSource(time.Now().UnixNano()))); err != nil {
+		return nil, err
+	}
+	quiz.Questions = append(quiz.Questions, pool[next])
+	return &pool[next], nil
+}
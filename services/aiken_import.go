@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"regexp"
+	"strings"
+)
+
+const ImportFormatAiken = "aiken"
+
+var (
+	aikenOption = regexp.MustCompile(`^([A-Z])[.)]\s+(.*)$`)
+	aikenAnswer = regexp.MustCompile(`^ANSWER:\s*(.*)$`)
+)
+
+// decodeAikenQuestions reads Moodle's Aiken format: a question, lettered
+// options and an ANSWER line, with blank lines between questions. Rows in the
+// result are line numbers.
+func decodeAikenQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	result := ImportResult{}
+
+	var question *models.QuestionUnmarshal
+	var stem []string
+	start, answered := 0, false
+	problems := make([]string, 0)
+
+	finish := func() {
+		if question == nil {
+			return
+		}
+		if !answered {
+			problems = append(problems, "question has no ANSWER line")
+		}
+		if len(problems) == 0 {
+			problems = append(problems, ValidateQuestion(question)...)
+		}
+		if len(problems) != 0 {
+			result.Errors = append(result.Errors, ImportRowError{Row: start, Errors: problems})
+		}
+		result.Questions = append(result.Questions, *question)
+		question, stem, answered, problems = nil, nil, false, make([]string, 0)
+	}
+
+	lines := splitLines(content)
+	for i, line := range lines {
+		number := i + 1
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if question == nil {
+			question = &models.QuestionUnmarshal{Type: models.QuestionTypeSingleChoice}
+			start = number
+		}
+
+		if match := aikenAnswer.FindStringSubmatch(line); match != nil {
+			if len(question.Options) == 0 {
+				problems = append(problems, fmt.Sprintf("line %d: ANSWER comes before any options", number))
+			}
+			letter := strings.TrimSpace(match[1])
+			index := -1
+			if len(letter) == 1 {
+				index = int(letter[0]) - 'A'
+			}
+			if index < 0 || index >= len(question.Options) {
+				problems = append(problems, fmt.Sprintf("line %d: ANSWER %q does not match an option letter", number, letter))
+			} else {
+				question.CorrectAnswer = question.Options[index]
+				question.Distractors = defaultDistractors(*question)
+			}
+			answered = true
+			finish()
+			continue
+		}
+
+		if match := aikenOption.FindStringSubmatch(line); match != nil && len(stem) != 0 {
+			expected := string(rune('A' + len(question.Options)))
+			if match[1] != expected {
+				problems = append(problems, fmt.Sprintf("line %d: expected option %s but found %s", number, expected, match[1]))
+			}
+			question.Options = append(question.Options, strings.TrimSpace(match[2]))
+			continue
+		}
+
+		if len(question.Options) != 0 {
+			problems = append(problems, fmt.Sprintf("line %d: expected an option or ANSWER line", number))
+			continue
+		}
+		stem = append(stem, line)
+		question.QuestionName = strings.Join(stem, " ")
+	}
+	finish()
+
+	if len(result.Questions) == 0 {
+		return result, ErrEmptyImport
+	}
+	return result, nil
+}
+
+// splitLines splits text on any line ending and drops a UTF-8 byte order
+// mark.
+func splitLines(content []byte) []string {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n")
+}
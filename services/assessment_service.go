@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func GetAssessmentById(ctx context.Context, id primitive.ObjectID) (models.Assessment, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "assessments")
+	var assessment models.Assessment
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&assessment)
+	return assessment, err
+}
+
+// SampleQuestions picks up to size random published questions from the given
+// topic.
+// Questions uploaded before the topic was stored on each question are
+// matched through the text index instead.
+func SampleQuestions(ctx context.Context, topic string, size int) ([]models.Question, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+
+	match := bson.M{"topic": topic, "status": PublishedStatus()}
+	count, err := collection.CountDocuments(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		match = bson.M{"$text": bson.M{"$search": topic}, "status": PublishedStatus()}
+	}
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sample", Value: bson.M{"size": size}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]models.Question, 0)
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	AttemptErrorAssignmentNotOpen = "ASSIGNMENT_NOT_OPEN"
+	AttemptErrorAssignmentClosed  = "ASSIGNMENT_CLOSED"
+)
+
+// Where a student is with an assignment.
+const (
+	RosterNotStarted = "not_started"
+	RosterInProgress = "in_progress"
+	RosterCompleted  = "completed"
+)
+
+type RosterEntry struct {
+	Username    string     `json:"username"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Late        bool       `json:"late"`
+	Score       *float64   `json:"score,omitempty"`
+}
+
+// Roster lists every student of a class with their progress on an
+// assignment, and how many students are at each status.
+type Roster struct {
+	Students   []RosterEntry `json:"students"`
+	NotStarted int           `json:"not_started"`
+	InProgress int           `json:"in_progress"`
+	Completed  int           `json:"completed"`
+}
+
+func IsRosterStatus(value string) bool {
+	return value == RosterNotStarted || value == RosterInProgress || value == RosterCompleted
+}
+
+// ValidateAssignment checks the dates and late policy of an assignment.
+func ValidateAssignment(assignment models.Assignment) []string {
+	errorStrings := make([]string, 0)
+	if assignment.AssessmentId.IsZero() {
+		errorStrings = append(errorStrings, "assessment_id should not be empty")
+	}
+	if assignment.DueAt.IsZero() {
+		errorStrings = append(errorStrings, "due_at should not be empty")
+	} else if assignment.OpensAt != nil && !assignment.OpensAt.Before(assignment.DueAt) {
+		errorStrings = append(errorStrings, "opens_at should be before due_at")
+	}
+
+	policy := assignment.LatePolicy
+	if policy.AcceptUntil != nil && !assignment.DueAt.IsZero() && !policy.AcceptUntil.After(assignment.DueAt) {
+		errorStrings = append(errorStrings, "late_policy.accept_until should be after due_at")
+	}
+	if policy.PenaltyPercent < 0 || policy.PenaltyPercent > 100 {
+		errorStrings = append(errorStrings, "late_policy.penalty_percent should be between 0 and 100")
+	}
+	if policy.PenaltyPeriodHours < 0 {
+		errorStrings = append(errorStrings, "late_policy.penalty_period_hours should not be negative")
+	}
+	if policy.MaxPenaltyPercent < 0 || policy.MaxPenaltyPercent > 100 {
+		errorStrings = append(errorStrings, "late_policy.max_penalty_percent should be between 0 and 100")
+	}
+	return errorStrings
+}
+
+func GetAssignmentById(ctx context.Context, id primitive.ObjectID) (models.Assignment, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "assignments")
+	var assignment models.Assignment
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&assignment)
+	return assignment, err
+}
+
+// GetClassAssignments returns the assignments of a class by due date.
+func GetClassAssignments(ctx context.Context, classId primitive.ObjectID) ([]models.Assignment, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "assignments")
+	cursor, err := collection.Find(ctx, bson.M{"class_id": classId}, options.Find().SetSort(bson.D{{Key: "due_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	assignments := make([]models.Assignment, 0)
+	if err = cursor.All(ctx, &assignments); err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// CheckAssignable refuses assignments that would give a class more than the
+// assessment allows. An assessment limited to other classes can't be
+// assigned until an admin opens it to this one, and only admins can set a
+// window outside the assessment's own.
+func CheckAssignable(assignment models.Assignment, assessment models.Assessment, user models.User) error {
+	if len(assessment.ClassIds) != 0 && !slices.Contains(assessment.ClassIds, assignment.ClassId) {
+		return &ClassError{Message: "This assessment is not open to the class", Status: http.StatusForbidden}
+	}
+	if user.IsAdmin {
+		return nil
+	}
+	if assessment.OpensAt != nil && (assignment.OpensAt == nil || assignment.OpensAt.Before(*assessment.OpensAt)) {
+		return &ClassError{Message: "Assignment should not open before the assessment does", Status: http.StatusForbidden}
+	}
+	if assessment.ClosesAt != nil && assignment.Deadline().After(*assessment.ClosesAt) {
+		return &ClassError{Message: "Assignment should not accept work after the assessment closes", Status: http.StatusForbidden}
+	}
+	return nil
+}
+
+// CreateAssignment assigns an assessment to a class once.
+func CreateAssignment(ctx context.Context, assignment models.Assignment) (models.Assignment, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "assignments")
+	count, err := collection.CountDocuments(ctx, bson.M{"class_id": assignment.ClassId, "assessment_id": assignment.AssessmentId})
+	if err != nil {
+		return assignment, err
+	}
+	if count != 0 {
+		return assignment, &ClassError{Message: "This assessment is already assigned to the class", Status: http.StatusConflict}
+	}
+
+	res, err := collection.InsertOne(ctx, assignment)
+	if err != nil {
+		return assignment, err
+	}
+	assignment.ID = res.InsertedID.(primitive.ObjectID)
+	return assignment, nil
+}
+
+// UpdateAssignment saves new dates or a new late policy. Quizzes already
+// started keep the due date and policy they started with.
+func UpdateAssignment(ctx context.Context, assignment models.Assignment) error {
+	client := GetConnection()
+	collection := GetCollection(client, "assignments")
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": assignment.ID}, assignment)
+	return err
+}
+
+// DeleteAssignment removes an assignment nobody has started yet.
+func DeleteAssignment(ctx context.Context, assignment models.Assignment) error {
+	client := GetConnection()
+	count, err := GetCollection(client, "quizzes").CountDocuments(ctx, bson.M{"assignment_id": assignment.ID})
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		return &ClassError{Message: "Assignment already has attempts and cannot be deleted", Status: http.StatusConflict}
+	}
+	_, err = GetCollection(client, "assignments").DeleteOne(ctx, bson.M{"_id": assignment.ID})
+	return err
+}
+
+// FindAssignment returns the assignment that sets the window of a student's
+// attempts at an assessment, or nil when none of their classes has it.
+func FindAssignment(ctx context.Context, assessmentId primitive.ObjectID, username string, now time.Time) (*models.Assignment, error) {
+	client := GetConnection()
+	classIds, err := GetCollection(client, "classes").Distinct(ctx, "_id", bson.M{"students": username})
+	if err != nil {
+		return nil, err
+	}
+	if len(classIds) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := GetCollection(client, "assignments").Find(ctx, bson.M{
+		"assessment_id": assessmentId,
+		"class_id":      bson.M{"$in": classIds},
+	})
+	if err != nil {
+		return nil, err
+	}
+	assignments := make([]models.Assignment, 0)
+	if err = cursor.All(ctx, &assignments); err != nil {
+		return nil, err
+	}
+	return PickAssignment(assignments, now), nil
+}
+
+// PickAssignment chooses between the assignments of a student's classes for
+// the same assessment: one that can still be done on time, else one that
+// still takes late work, else the one that opens next, else the one that
+// was due last.
+func PickAssignment(assignments []models.Assignment, now time.Time) *models.Assignment {
+	if len(assignments) == 0 {
+		return nil
+	}
+	rank := func(assignment models.Assignment) int {
+		switch {
+		case assignment.IsOpen(now) && !now.After(assignment.DueAt):
+			return 0
+		case assignment.IsOpen(now):
+			return 1
+		case !assignment.HasOpened(now):
+			return 2
+		default:
+			return 3
+		}
+	}
+	sorted := slices.Clone(assignments)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if rank(a) == 2 {
+			return a.OpensAt.Before(*b.OpensAt)
+		}
+		return a.DueAt.After(b.DueAt)
+	})
+	return &sorted[0]
+}
+
+// CheckAssignmentWindow refuses attempts before an assignment opens and
+// after its last late deadline.
+func CheckAssignmentWindow(assignment models.Assignment, now time.Time) error {
+	if !assignment.HasOpened(now) {
+		return &AttemptError{
+			Code:    AttemptErrorAssignmentNotOpen,
+			Message: fmt.Sprintf("Assignment opens at %s", assignment.OpensAt.Format(time.RFC3339)),
+			Status:  http.StatusForbidden,
+		}
+	}
+	if deadline := assignment.Deadline(); !assignment.IsOpen(now) {
+		return &AttemptError{
+			Code:    AttemptErrorAssignmentClosed,
+			Message: fmt.Sprintf("Assignment closed at %s", deadline.Format(time.RFC3339)),
+			Status:  http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+// GetAssignmentRoster reports the progress of every student in a class on
+// one of its assignments.
+func GetAssignmentRoster(ctx context.Context, class models.Class, assignment models.Assignment, assessment models.Assessment, now time.Time) (Roster, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "quizzes")
+	cursor, err := collection.Find(ctx, bson.M{"assignment_id": assignment.ID}, options.Find().SetSort(bson.M{"start_time": 1}))
+	if err != nil {
+		return Roster{}, err
+	}
+	attempts := make([]models.Quiz, 0)
+	if err = cursor.All(ctx, &attempts); err != nil {
+		return Roster{}, err
+	}
+	return BuildRoster(class.Students, assignment, assessment, attempts, now), nil
+}
+
+// BuildRoster sorts the attempts of an assignment by student. A student is
+// in progress while their latest attempt runs and has completed the
+// assignment once any attempt has finished.
+func BuildRoster(students []string, assignment models.Assignment, assessment models.Assessment, attempts []models.Quiz, now time.Time) Roster {
+	byStudent := make(map[string][]models.Quiz)
+	for _, attempt := range attempts {
+		byStudent[attempt.User.Username] = append(byStudent[attempt.User.Username], attempt)
+	}
+
+	usernames := slices.Clone(students)
+	sort.Strings(usernames)
+
+	roster := Roster{Students: make([]RosterEntry, 0, len(usernames))}
+	for _, username := range usernames {
+		entry := RosterEntry{Username: username, Status: RosterNotStarted}
+		studentAttempts := byStudent[username]
+		entry.Attempts = len(studentAttempts)
+
+		onTime := false
+		for i, attempt := range studentAttempts {
+			if i == 0 {
+				startedAt := attempt.StartTime
+				entry.StartedAt = &startedAt
+			}
+			if !attempt.Completed && attempt.Remaining(now) > 0 {
+				continue
+			}
+			finishedAt := AttemptFinishedAt(attempt)
+			if entry.CompletedAt == nil {
+				entry.CompletedAt = &finishedAt
+			}
+			if !finishedAt.After(assignment.DueAt) {
+				onTime = true
+			}
+		}
+
+		if len(studentAttempts) != 0 {
+			last := studentAttempts[len(studentAttempts)-1]
+			switch {
+			case !last.Completed && last.Remaining(now) > 0:
+				entry.Status = RosterInProgress
+			case entry.CompletedAt != nil:
+				entry.Status = RosterCompleted
+			}
+		}
+		if entry.CompletedAt != nil {
+			entry.Late = !onTime
+			if score, ok := CountedScore(assessment, studentAttempts, now); ok {
+				entry.Score = &score
+			}
+		}
+
+		switch entry.Status {
+		case RosterNotStarted:
+			roster.NotStarted++
+		case RosterInProgress:
+			roster.InProgress++
+		case RosterCompleted:
+			roster.Completed++
+		}
+		roster.Students = append(roster.Students, entry)
+	}
+	return roster
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestLatePenalty(t *testing.T) {
+	policy := models.LatePolicy{PenaltyPercent: 10, PenaltyPeriodHours: 24, MaxPenaltyPercent: 25}
+
+	// Test case: Work on time or without a penalty loses nothing
+	assert.Equal(t, 0.0, policy.Penalty(0))
+	assert.Equal(t, 0.0, (&models.LatePolicy{}).Penalty(time.Hour))
+
+	// Test case: Every started period adds the penalty, up to the maximum
+	assert.Equal(t, 10.0, policy.Penalty(time.Minute))
+	assert.Equal(t, 20.0, policy.Penalty(25*time.Hour))
+	assert.Equal(t, 25.0, policy.Penalty(100*time.Hour))
+
+	// Test case: Without a period the penalty is taken once
+	policy = models.LatePolicy{PenaltyPercent: 15}
+	assert.Equal(t, 15.0, policy.Penalty(72*time.Hour))
+}
+
+func TestScoreQuizLatePenalty(t *testing.T) {
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	completedAt := due.Add(30 * time.Hour)
+	questions := []models.Question{{ID: primitive.NewObjectID()}, {ID: primitive.NewObjectID()}}
+	quiz := models.Quiz{
+		Questions:    questions,
+		PassingScore: 50,
+		DueAt:        &due,
+		LatePolicy:   &models.LatePolicy{PenaltyPercent: 20, PenaltyPeriodHours: 24},
+		CompletedAt:  &completedAt,
+		UserResponses: []models.UserResponse{
+			{QuestionId: questions[0].ID, Result: ResultRight, Credit: 1},
+			{QuestionId: questions[1].ID, Result: ResultRight, Credit: 1},
+		},
+	}
+
+	// Test case: Two started days late loses 40% of the score
+	score := ScoreQuiz(quiz, time.Now())
+	assert.True(t, score.Late)
+	assert.Equal(t, 40.0, score.LatePenalty)
+	assert.Equal(t, 100.0, score.RawPercentage)
+	assert.Equal(t, 60.0, score.Percentage)
+	assert.True(t, score.Passed)
+
+	// Test case: Points lose the same share as the percentage
+	assert.Equal(t, 2.0, score.RawPoints)
+	assert.InDelta(t, 1.2, score.Points, 1e-9)
+
+	// Test case: Passing is decided after the penalty
+	quiz.UserResponses = quiz.UserResponses[:1]
+	score = ScoreQuiz(quiz, time.Now())
+	assert.Equal(t, 30.0, score.Percentage)
+	assert.False(t, score.Passed)
+
+	// Test case: Work handed in on time keeps its score
+	onTime := due.Add(-time.Minute)
+	quiz.CompletedAt = &onTime
+	score = ScoreQuiz(quiz, time.Now())
+	assert.False(t, score.Late)
+	assert.Equal(t, 50.0, score.Percentage)
+	assert.Equal(t, 1.0, score.Points)
+}
+
+func TestValidateAssignment(t *testing.T) {
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	opens := due.Add(time.Hour)
+	acceptUntil := due.Add(-time.Hour)
+
+	// Test case: A valid assignment
+	assignment := models.Assignment{AssessmentId: primitive.NewObjectID(), DueAt: due}
+	assert.Empty(t, ValidateAssignment(assignment))
+
+	// Test case: Dates out of order and penalties out of range
+	assignment.OpensAt = &opens
+	assignment.LatePolicy = models.LatePolicy{AcceptUntil: &acceptUntil, PenaltyPercent: 120, PenaltyPeriodHours: -1, MaxPenaltyPercent: -5}
+	assert.Equal(t, []string{
+		"opens_at should be before due_at",
+		"late_policy.accept_until should be after due_at",
+		"late_policy.penalty_percent should be between 0 and 100",
+		"late_policy.penalty_period_hours should not be negative",
+		"late_policy.max_penalty_percent should be between 0 and 100",
+	}, ValidateAssignment(assignment))
+
+	// Test case: Assessment and due date are required
+	assert.Equal(t, []string{"assessment_id should not be empty", "due_at should not be empty"}, ValidateAssignment(models.Assignment{}))
+}
+
+func TestAssignmentWindow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-48*time.Hour), now.Add(48*time.Hour)
+	yesterday, tomorrow := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+
+	onTime := models.Assignment{ID: primitive.NewObjectID(), DueAt: tomorrow}
+	late := models.Assignment{ID: primitive.NewObjectID(), DueAt: yesterday, LatePolicy: models.LatePolicy{AcceptUntil: &tomorrow}}
+	upcoming := models.Assignment{ID: primitive.NewObjectID(), OpensAt: &tomorrow, DueAt: future}
+	closed := models.Assignment{ID: primitive.NewObjectID(), DueAt: past}
+
+	// Test case: Attempts start between opening and the last late deadline
+	assert.NoError(t, CheckAssignmentWindow(onTime, now))
+	assert.NoError(t, CheckAssignmentWindow(late, now))
+	err := CheckAssignmentWindow(upcoming, now)
+	assert.Equal(t, AttemptErrorAssignmentNotOpen, err.(*AttemptError).Code)
+	err = CheckAssignmentWindow(closed, now)
+	assert.Equal(t, AttemptErrorAssignmentClosed, err.(*AttemptError).Code)
+
+	// Test case: The closed message gives the last late deadline
+	err = CheckAssignmentWindow(models.Assignment{DueAt: past, LatePolicy: models.LatePolicy{AcceptUntil: &yesterday}}, now)
+	assert.Contains(t, err.Error(), yesterday.Format(time.RFC3339))
+
+	// Test case: On time beats late, late beats upcoming, upcoming beats closed
+	assert.Nil(t, PickAssignment(nil, now))
+	assert.Equal(t, onTime.ID, PickAssignment([]models.Assignment{closed, upcoming, late, onTime}, now).ID)
+	assert.Equal(t, late.ID, PickAssignment([]models.Assignment{closed, upcoming, late}, now).ID)
+	assert.Equal(t, upcoming.ID, PickAssignment([]models.Assignment{closed, upcoming}, now).ID)
+}
+
+func TestCheckAssignable(t *testing.T) {
+	opens := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	closes := opens.Add(14 * 24 * time.Hour)
+	classId := primitive.NewObjectID()
+	instructor := models.User{Username: "teacher"}
+	assessment := models.Assessment{OpensAt: &opens, ClosesAt: &closes}
+	assignment := models.Assignment{ClassId: classId, OpensAt: &opens, DueAt: closes.Add(-time.Hour)}
+
+	// Test case: An assignment within the assessment's window is allowed
+	assert.NoError(t, CheckAssignable(assignment, assessment, instructor))
+
+	// Test case: Assessments limited to other classes can't be assigned, not even by admins
+	limited := assessment
+	limited.ClassIds = []primitive.ObjectID{primitive.NewObjectID()}
+	assert.Error(t, CheckAssignable(assignment, limited, instructor))
+	assert.Error(t, CheckAssignable(assignment, limited, models.User{Username: "admin", IsAdmin: true}))
+	limited.ClassIds = append(limited.ClassIds, classId)
+	assert.NoError(t, CheckAssignable(assignment, limited, instructor))
+
+	// Test case: Instructors can't reopen an assessment that has closed
+	late := assignment
+	late.LatePolicy.AcceptUntil = &[]time.Time{closes.Add(time.Hour)}[0]
+	assert.Error(t, CheckAssignable(late, assessment, instructor))
+	early := assignment
+	early.OpensAt = nil
+	assert.Error(t, CheckAssignable(early, assessment, instructor))
+
+	// Test case: Admins can set any window
+	assert.NoError(t, CheckAssignable(late, assessment, models.User{Username: "admin", IsAdmin: true}))
+}
+
+func TestBuildRoster(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	assignment := models.Assignment{DueAt: now.Add(-time.Hour)}
+	finishedLate := now.Add(-30 * time.Minute)
+	finishedOnTime := now.Add(-2 * time.Hour)
+	attempts := []models.Quiz{
+		{User: models.User{Username: "asha"}, StartTime: now.Add(-3 * time.Hour), Completed: true, CompletedAt: &finishedOnTime, Score: &models.QuizScore{Percentage: 80}},
+		{User: models.User{Username: "ravi"}, StartTime: now.Add(-time.Hour), Completed: true, CompletedAt: &finishedLate, Score: &models.QuizScore{Percentage: 60}},
+		{User: models.User{Username: "meera"}, StartTime: now.Add(-5 * time.Minute), EndTime: now.Add(25 * time.Minute)},
+	}
+
+	roster := BuildRoster([]string{"ravi", "meera", "asha", "kiran"}, assignment, models.Assessment{}, attempts, now)
+
+	// Test case: Students are listed by name with their status
+	assert.Equal(t, 1, roster.NotStarted)
+	assert.Equal(t, 1, roster.InProgress)
+	assert.Equal(t, 2, roster.Completed)
+	assert.Equal(t, "asha", roster.Students[0].Username)
+	assert.Equal(t, RosterCompleted, roster.Students[0].Status)
+	assert.False(t, roster.Students[0].Late)
+	assert.Equal(t, 80.0, *roster.Students[0].Score)
+	assert.Equal(t, RosterNotStarted, roster.Students[1].Status)
+	assert.Nil(t, roster.Students[1].Score)
+	assert.Equal(t, RosterInProgress, roster.Students[2].Status)
+	assert.Equal(t, RosterCompleted, roster.Students[3].Status)
+	assert.True(t, roster.Students[3].Late)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"time"
+)
+
+const (
+	AttemptErrorNotOpen      = "ASSESSMENT_NOT_OPEN"
+	AttemptErrorLimitReached = "ATTEMPT_LIMIT_REACHED"
+	AttemptErrorCooldown     = "ATTEMPT_COOLDOWN_ACTIVE"
+	AttemptErrorInProgress   = "ATTEMPT_IN_PROGRESS"
+	AttemptErrorNotEnrolled  = "NOT_ENROLLED"
+)
+
+// AttemptError describes why a new attempt cannot be started. Code is a
+// stable identifier clients can switch on; Status is the HTTP status to use.
+type AttemptError struct {
+	Code       string
+	Message    string
+	Status     int
+	RetryAfter time.Duration
+}
+
+func (e *AttemptError) Error() string {
+	return e.Message
+}
+
+// EnsureAttemptIndex makes attempt numbers unique per user and assessment,
+// so two attempts started at the same time cannot both be saved.
+// CheckAttemptPolicy reads the previous attempts before the new one is
+// inserted; the index turns the losing insert into a duplicate key error.
+func EnsureAttemptIndex(ctx context.Context) error {
+	client := GetConnection()
+	collection := GetCollection(client, "quizzes")
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "assessment_id", Value: 1},
+			{Key: "user.username", Value: 1},
+			{Key: "attempt", Value: 1},
+		},
+		Options: options.Index().
+			SetUnique(true).
+			SetPartialFilterExpression(bson.M{"assessment_id": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+func GetAttempts(ctx context.Context, assessmentId primitive.ObjectID, username string) ([]models.Quiz, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "quizzes")
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"assessment_id": assessmentId,
+		"user.username": username,
+	}, options.Find().SetSort(bson.M{"start_time": 1}))
+	if err != nil {
+		return nil, err
+	}
+	attempts := make([]models.Quiz, 0)
+	if err = cursor.All(ctx, &attempts); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// CheckAttemptPolicy enforces the assessment window, class enrollment,
+// attempt limit and cooldown for a user. Students with an assignment for the
+// assessment get the assignment's window instead. It returns the previous
+// attempts so the caller can number the new one. Policy violations are
+// returned as *AttemptError.
+func CheckAttemptPolicy(ctx context.Context, assessment models.Assessment, user models.User, assignment *models.Assignment, now time.Time) ([]models.Quiz, error) {
+	if assignment != nil {
+		if err := CheckAssignmentWindow(*assignment, now); err != nil {
+			return nil, err
+		}
+	} else if !assessment.IsOpen(now) {
+		return nil, &AttemptError{
+			Code:    AttemptErrorNotOpen,
+			Message: "Assessment is not open for attempts",
+			Status:  http.StatusForbidden,
+		}
+	}
+
+	if assignment == nil && !user.IsAdmin && len(assessment.ClassIds) != 0 {
+		member, err := IsClassMember(ctx, user.Username, assessment.ClassIds)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, &AttemptError{
+				Code:    AttemptErrorNotEnrolled,
+				Message: "You are not enrolled in a class this assessment is assigned to",
+				Status:  http.StatusForbidden,
+			}
+		}
+	}
+
+	attempts, err := GetAttempts(ctx, assessment.ID, user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(attempts) == 0 {
+		return attempts, nil
+	}
+
+	last := attempts[len(attempts)-1]
+	if !last.Completed && last.Remaining(now) > 0 {
+		return nil, &AttemptError{
+			Code:    AttemptErrorInProgress,
+			Message: "An attempt for this assessment is already in progress",
+			Status:  http.StatusConflict,
+		}
+	}
+
+	if assessment.MaxAttempts > 0 && len(attempts) >= assessment.MaxAttempts {
+		return nil, &AttemptError{
+			Code:    AttemptErrorLimitReached,
+			Message: fmt.Sprintf("Maximum of %d attempts reached for this assessment", assessment.MaxAttempts),
+			Status:  http.StatusForbidden,
+		}
+	}
+
+	if assessment.CooldownMinutes > 0 {
+		nextAllowed := AttemptFinishedAt(last).Add(time.Duration(assessment.CooldownMinutes) * time.Minute)
+		if now.Before(nextAllowed) {
+			return nil, &AttemptError{
+				Code:       AttemptErrorCooldown,
+				Message:    fmt.Sprintf("Next attempt allowed after %s", nextAllowed.Format(time.RFC3339)),
+				Status:     http.StatusTooManyRequests,
+				RetryAfter: nextAllowed.Sub(now),
+			}
+		}
+	}
+
+	return attempts, nil
+}
+
+// AttemptFinishedAt returns when an attempt ended, falling back to its
+// EndTime for quizzes that expired without being submitted.
+func AttemptFinishedAt(quiz models.Quiz) time.Time {
+	if quiz.CompletedAt != nil {
+		return *quiz.CompletedAt
+	}
+	return quiz.EndTime
+}
+
+// QuizPercentage returns the stored score of a finished attempt, scoring it
+// on the fly for attempts that ended before scores were persisted.
+func QuizPercentage(quiz models.Quiz) float64 {
+	if quiz.Score != nil {
+		return quiz.Score.Percentage
+	}
+	return ScoreQuiz(quiz, time.Now()).Percentage
+}
+
+// CountedScore combines the percentages of finished attempts according to
+// the assessment's attempt scoring rule. It returns false when no attempt
+// has finished yet.
+func CountedScore(assessment models.Assessment, attempts []models.Quiz, now time.Time) (float64, bool) {
+	scores := make([]float64, 0, len(attempts))
+	for _, attempt := range attempts {
+		if !attempt.Completed && attempt.Remaining(now) > 0 {
+			continue
+		}
+		scores = append(scores, QuizPercentage(attempt))
+	}
+	if len(scores) == 0 {
+		return 0, false
+	}
+
+	switch assessment.AttemptScoring {
+	case models.AttemptScoringLast:
+		return scores[len(scores)-1], true
+	case models.AttemptScoringAverage:
+		total := 0.0
+		for _, score := range scores {
+			total += score
+		}
+		return total / float64(len(scores)), true
+	default:
+		best := scores[0]
+		for _, score := range scores[1:] {
+			if score > best {
+				best = score
+			}
+		}
+		return best, true
+	}
+}
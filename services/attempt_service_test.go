@@ -0,0 +1,47 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+	"time"
+)
+
+func finishedAttempt(correct, total int) models.Quiz {
+	quiz := models.Quiz{Completed: true}
+	for i := 0; i < total; i++ {
+		quiz.Questions = append(quiz.Questions, models.Question{})
+		result := "Wrong"
+		if i < correct {
+			result = "Right"
+		}
+		quiz.UserResponses = append(quiz.UserResponses, models.UserResponse{Result: result})
+	}
+	return quiz
+}
+
+func TestCountedScore(t *testing.T) {
+	now := time.Now()
+	attempts := []models.Quiz{
+		finishedAttempt(2, 4),
+		finishedAttempt(4, 4),
+		finishedAttempt(1, 4),
+		{StartTime: now, EndTime: now.Add(time.Hour), Questions: make([]models.Question, 4)},
+	}
+
+	score, ok := CountedScore(models.Assessment{AttemptScoring: models.AttemptScoringBest}, attempts, now)
+	assert.True(t, ok)
+	assert.Equal(t, 100.0, score)
+
+	score, ok = CountedScore(models.Assessment{AttemptScoring: models.AttemptScoringLast}, attempts, now)
+	assert.True(t, ok)
+	assert.Equal(t, 25.0, score)
+
+	score, ok = CountedScore(models.Assessment{AttemptScoring: models.AttemptScoringAverage}, attempts, now)
+	assert.True(t, ok)
+	assert.InDelta(t, 58.33, score, 0.01)
+
+	// Test case: no finished attempts
+	_, ok = CountedScore(models.Assessment{}, attempts[3:], now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Join codes leave out letters and digits that are easily mistaken for one
+// another, such as O and 0.
+const (
+	joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	joinCodeLength   = 8
+)
+
+// ClassError is a refused change to a class with the status to answer it
+// with.
+type ClassError struct {
+	Message string
+	Status  int
+}
+
+func (err *ClassError) Error() string {
+	return err.Message
+}
+
+// Enrollment reports what happened to each username added to a class.
+type Enrollment struct {
+	Enrolled        []string `json:"enrolled"`
+	AlreadyEnrolled []string `json:"already_enrolled"`
+	NotFound        []string `json:"not_found"`
+}
+
+func GetClassById(ctx context.Context, id primitive.ObjectID) (models.Class, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	var class models.Class
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&class)
+	return class, err
+}
+
+// GetClasses returns every class to admins, and the classes a user teaches
+// or is enrolled in to everyone else.
+func GetClasses(ctx context.Context, user models.User) ([]models.Class, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	filter := bson.M{}
+	if !user.IsAdmin {
+		filter = memberFilter(user.Username)
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	classes := make([]models.Class, 0)
+	if err = cursor.All(ctx, &classes); err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+func memberFilter(username string) bson.M {
+	return bson.M{"$or": bson.A{bson.M{"students": username}, bson.M{"instructors": username}}}
+}
+
+// MemberClassIds returns the classes a user teaches or is enrolled in.
+func MemberClassIds(ctx context.Context, username string) ([]primitive.ObjectID, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	ids, err := collection.Distinct(ctx, "_id", memberFilter(username))
+	if err != nil {
+		return nil, err
+	}
+	classIds := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if classId, ok := id.(primitive.ObjectID); ok {
+			classIds = append(classIds, classId)
+		}
+	}
+	return classIds, nil
+}
+
+// IsClassMember reports whether a user teaches or is enrolled in any of the
+// given classes.
+func IsClassMember(ctx context.Context, username string, classIds []primitive.ObjectID) (bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	filter := memberFilter(username)
+	filter["_id"] = bson.M{"$in": classIds}
+	count, err := collection.CountDocuments(ctx, filter)
+	return count != 0, err
+}
+
+// InstructsStudent reports whether instructor teaches a class the student is
+// enrolled in. When classIds is not empty only those classes count.
+func InstructsStudent(ctx context.Context, instructor, student string, classIds []primitive.ObjectID) (bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	filter := bson.M{"instructors": instructor, "students": student}
+	if len(classIds) != 0 {
+		filter["_id"] = bson.M{"$in": classIds}
+	}
+	count, err := collection.CountDocuments(ctx, filter)
+	return count != 0, err
+}
+
+// CanTakeAssessment reports whether members of the given classes are offered
+// an assessment. Assessments without classes are offered to everyone.
+func CanTakeAssessment(assessment models.Assessment, classIds []primitive.ObjectID) bool {
+	if len(assessment.ClassIds) == 0 {
+		return true
+	}
+	for _, id := range assessment.ClassIds {
+		if slices.Contains(classIds, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// StudentAssessmentFilter matches the open assessments offered to members of
+// the given classes.
+func StudentAssessmentFilter(now time.Time, classIds []primitive.ObjectID) bson.M {
+	filter := OpenAssessmentFilter(now)
+	filter["$and"] = append(filter["$and"].(bson.A), bson.M{"$or": bson.A{
+		bson.M{"class_ids": bson.M{"$exists": false}},
+		bson.M{"class_ids": bson.M{"$size": 0}},
+		bson.M{"class_ids": bson.M{"$in": classIds}},
+	}})
+	return filter
+}
+
+// CheckClassesExist refuses class IDs that don't belong to a class.
+func CheckClassesExist(ctx context.Context, classIds []primitive.ObjectID) error {
+	if len(classIds) == 0 {
+		return nil
+	}
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	ids, err := collection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": classIds}})
+	if err != nil {
+		return err
+	}
+	for _, classId := range classIds {
+		if !slices.Contains(ids, interface{}(classId)) {
+			return &ClassError{Message: fmt.Sprintf("Class %s not found", classId.Hex()), Status: http.StatusBadRequest}
+		}
+	}
+	return nil
+}
+
+func CreateClass(ctx context.Context, class models.Class) (models.Class, error) {
+	code, err := newJoinCode(ctx)
+	if err != nil {
+		return class, err
+	}
+	class.JoinCode = code
+	if class.Students == nil {
+		class.Students = make([]string, 0)
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	res, err := collection.InsertOne(ctx, class)
+	if err != nil {
+		return class, err
+	}
+	class.ID = res.InsertedID.(primitive.ObjectID)
+	return class, nil
+}
+
+func UpdateClass(ctx context.Context, class models.Class) error {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	_, err := collection.UpdateByID(ctx, class.ID, bson.M{"$set": bson.M{
+		"name":        class.Name,
+		"description": class.Description,
+		"updated_at":  class.UpdatedAt,
+	}})
+	return err
+}
+
+// DeleteClass removes a class that no assessment is assigned to.
+func DeleteClass(ctx context.Context, class models.Class) error {
+	client := GetConnection()
+	checks := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{"assessments", bson.M{"class_ids": class.ID}},
+		{"assignments", bson.M{"class_id": class.ID}},
+	}
+	for _, check := range checks {
+		count, err := GetCollection(client, check.collection).CountDocuments(ctx, check.filter)
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			return &ClassError{Message: "Class has assessments assigned to it", Status: http.StatusConflict}
+		}
+	}
+	_, err := GetCollection(client, "classes").DeleteOne(ctx, bson.M{"_id": class.ID})
+	return err
+}
+
+// SetClassInstructors replaces the instructors of a class. Everyone listed
+// must hold the instructor role.
+func SetClassInstructors(ctx context.Context, class models.Class, usernames []string) ([]string, error) {
+	usernames = uniqueUsernames(usernames)
+	if len(usernames) == 0 {
+		return nil, &ClassError{Message: "A class needs at least one instructor", Status: http.StatusBadRequest}
+	}
+	users, err := findUsers(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+	for _, username := range usernames {
+		user, ok := users[username]
+		if !ok {
+			return nil, &ClassError{Message: fmt.Sprintf("User %q not found", username), Status: http.StatusBadRequest}
+		}
+		if !user.HasRole(models.RoleInstructor) {
+			return nil, &ClassError{Message: fmt.Sprintf("User %q is not an instructor", username), Status: http.StatusBadRequest}
+		}
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	_, err = collection.UpdateByID(ctx, class.ID, bson.M{"$set": bson.M{"instructors": usernames, "updated_at": time.Now()}})
+	return usernames, err
+}
+
+// EnrollStudents adds existing users to a class. Usernames without an
+// account and students already in the class are reported, not refused.
+func EnrollStudents(ctx context.Context, class models.Class, usernames []string) (Enrollment, error) {
+	usernames = uniqueUsernames(usernames)
+	users, err := findUsers(ctx, usernames)
+	if err != nil {
+		return Enrollment{}, err
+	}
+	enrollment := PlanEnrollment(class, usernames, users)
+	if len(enrollment.Enrolled) == 0 {
+		return enrollment, nil
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	_, err = collection.UpdateByID(ctx, class.ID, bson.M{
+		"$addToSet": bson.M{"students": bson.M{"$each": enrollment.Enrolled}},
+		"$set":      bson.M{"updated_at": time.Now()},
+	})
+	return enrollment, err
+}
+
+// PlanEnrollment sorts usernames into those to enroll, those already in the
+// class and those without an account.
+func PlanEnrollment(class models.Class, usernames []string, users map[string]models.User) Enrollment {
+	enrollment := Enrollment{
+		Enrolled:        make([]string, 0),
+		AlreadyEnrolled: make([]string, 0),
+		NotFound:        make([]string, 0),
+	}
+	for _, username := range uniqueUsernames(usernames) {
+		switch _, ok := users[username]; {
+		case !ok:
+			enrollment.NotFound = append(enrollment.NotFound, username)
+		case class.HasStudent(username):
+			enrollment.AlreadyEnrolled = append(enrollment.AlreadyEnrolled, username)
+		default:
+			enrollment.Enrolled = append(enrollment.Enrolled, username)
+		}
+	}
+	return enrollment
+}
+
+// UnenrollStudent removes a student from a class. Their past quizzes are
+// kept.
+func UnenrollStudent(ctx context.Context, class models.Class, username string) (bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": class.ID, "students": username}, bson.M{
+		"$pull": bson.M{"students": username},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount != 0, nil
+}
+
+// ResetJoinCode gives a class a new join code. The old one stops working.
+func ResetJoinCode(ctx context.Context, class models.Class) (string, error) {
+	code, err := newJoinCode(ctx)
+	if err != nil {
+		return "", err
+	}
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	_, err = collection.UpdateByID(ctx, class.ID, bson.M{"$set": bson.M{"join_code": code, "updated_at": time.Now()}})
+	return code, err
+}
+
+// DisableJoinCode stops students from joining a class by themselves.
+func DisableJoinCode(ctx context.Context, class models.Class) error {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	_, err := collection.UpdateByID(ctx, class.ID, bson.M{
+		"$unset": bson.M{"join_code": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	})
+	return err
+}
+
+// JoinClass enrolls a user in the class with the given join code. Joining a
+// class twice does nothing.
+func JoinClass(ctx context.Context, code, username string) (models.Class, error) {
+	code = NormalizeJoinCode(code)
+	if code == "" {
+		return models.Class{}, &ClassError{Message: "code should not be empty", Status: http.StatusBadRequest}
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	var class models.Class
+	err := collection.FindOneAndUpdate(ctx, bson.M{"join_code": code}, bson.M{"$addToSet": bson.M{"students": username}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&class)
+	if err == mongo.ErrNoDocuments {
+		return class, &ClassError{Message: "No class has this join code", Status: http.StatusNotFound}
+	}
+	return class, err
+}
+
+func NormalizeJoinCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// newJoinCode draws join codes until it finds one no class is using.
+func newJoinCode(ctx context.Context) (string, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "classes")
+	for tries := 0; tries < 5; tries++ {
+		code, err := randomJoinCode()
+		if err != nil {
+			return "", err
+		}
+		count, err := collection.CountDocuments(ctx, bson.M{"join_code": code})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("no free join code found")
+}
+
+func randomJoinCode() (string, error) {
+	random := make([]byte, joinCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	code := make([]byte, joinCodeLength)
+	for i, b := range random {
+		code[i] = joinCodeAlphabet[int(b)%len(joinCodeAlphabet)]
+	}
+	return string(code), nil
+}
+
+// ParseRosterCSV reads usernames from a CSV file. A header row with a
+// username column picks that column; otherwise the first column is used.
+func ParseRosterCSV(reader io.Reader) ([]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	rows, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	column := 0
+	if len(rows) != 0 {
+		for i, cell := range rows[0] {
+			if strings.EqualFold(strings.TrimSpace(cell), "username") {
+				column = i
+				rows = rows[1:]
+				break
+			}
+		}
+	}
+
+	usernames := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if column < len(row) {
+			usernames = append(usernames, row[column])
+		}
+	}
+	return uniqueUsernames(usernames), nil
+}
+
+// uniqueUsernames trims usernames and drops empty and repeated ones.
+func uniqueUsernames(usernames []string) []string {
+	unique := make([]string, 0, len(usernames))
+	for _, username := range usernames {
+		username = strings.TrimSpace(username)
+		if username != "" && !slices.Contains(unique, username) {
+			unique = append(unique, username)
+		}
+	}
+	return unique
+}
+
+func findUsers(ctx context.Context, usernames []string) (map[string]models.User, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "users")
+	cursor, err := collection.Find(ctx, bson.M{"username": bson.M{"$in": usernames}},
+		options.Find().SetProjection(bson.M{"password": 0}))
+	if err != nil {
+		return nil, err
+	}
+	found := make([]models.User, 0)
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	users := make(map[string]models.User, len(found))
+	for _, user := range found {
+		users[user.Username] = user
+	}
+	return users, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"testing"
+)
+
+func TestParseRosterCSV(t *testing.T) {
+	// Test case: The username column is picked from the header
+	usernames, err := ParseRosterCSV(strings.NewReader("name,username\nJohn Doe,john_doe\nJane Roe, jane_roe\nJohn Doe,john_doe\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"john_doe", "jane_roe"}, usernames)
+
+	// Test case: Without a header the first column is used
+	usernames, err = ParseRosterCSV(strings.NewReader("john_doe\n\njane_roe,Jane\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"john_doe", "jane_roe"}, usernames)
+
+	// Test case: A broken file is refused
+	_, err = ParseRosterCSV(strings.NewReader("\"john_doe\n"))
+	assert.Error(t, err)
+}
+
+func TestPlanEnrollment(t *testing.T) {
+	class := models.Class{Students: []string{"john_doe"}}
+	users := map[string]models.User{
+		"john_doe": {Username: "john_doe"},
+		"jane_roe": {Username: "jane_roe"},
+	}
+
+	// Test case: New, enrolled and unknown usernames are reported apart
+	enrollment := PlanEnrollment(class, []string{"jane_roe", " john_doe", "nobody", "jane_roe"}, users)
+	assert.Equal(t, []string{"jane_roe"}, enrollment.Enrolled)
+	assert.Equal(t, []string{"john_doe"}, enrollment.AlreadyEnrolled)
+	assert.Equal(t, []string{"nobody"}, enrollment.NotFound)
+}
+
+func TestCanTakeAssessment(t *testing.T) {
+	physics, chemistry := primitive.NewObjectID(), primitive.NewObjectID()
+
+	// Test case: Assessments without classes are offered to everyone
+	assert.True(t, CanTakeAssessment(models.Assessment{}, nil))
+
+	// Test case: Assigned assessments need a member of one of their classes
+	assessment := models.Assessment{ClassIds: []primitive.ObjectID{physics}}
+	assert.True(t, CanTakeAssessment(assessment, []primitive.ObjectID{chemistry, physics}))
+	assert.False(t, CanTakeAssessment(assessment, []primitive.ObjectID{chemistry}))
+	assert.False(t, CanTakeAssessment(assessment, nil))
+}
+
+func TestJoinCodes(t *testing.T) {
+	// Test case: Codes use the unambiguous alphabet
+	code, err := randomJoinCode()
+	assert.NoError(t, err)
+	assert.Len(t, code, joinCodeLength)
+	for _, r := range code {
+		assert.Contains(t, joinCodeAlphabet, string(r))
+	}
+
+	// Test case: Codes are matched regardless of case, spaces and dashes
+	assert.Equal(t, "ABCD2345", NormalizeJoinCode(" abcd-2345 "))
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"github.com/zeekhoks/quiz-backend/models"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// latexBlockedCommands can load content or change the page when a formula is
+// rendered in the browser, so formulas using them are rejected.
+var latexBlockedCommands = []string{
+	"def", "href", "htmlClass", "htmlData", "htmlId", "htmlStyle", "include",
+	"includegraphics", "input", "newcommand", "renewcommand", "url",
+}
+
+var (
+	latexCommand    = regexp.MustCompile(`\\([a-zA-Z]+)`)
+	linkSchemes     = []string{"http", "https", "mailto"}
+	linkScheme      = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*):`)
+	paragraphSingle = regexp.MustCompile(`^<p>((?s:.*))</p>\n?$`)
+)
+
+var (
+	markdown     = newMarkdown(false)
+	markdownMath = newMarkdown(true)
+
+	// contentPolicy is applied to all rendered HTML, on top of the markdown
+	// renderer leaving out raw HTML.
+	contentPolicy = newContentPolicy()
+)
+
+func newMarkdown(withMath bool) goldmark.Markdown {
+	options := []goldmark.Option{goldmark.WithExtensions(extension.Table, extension.Strikethrough)}
+	if withMath {
+		options = append(options,
+			goldmark.WithParserOptions(parser.WithInlineParsers(util.Prioritized(mathParser{}, 500))),
+			goldmark.WithRendererOptions(renderer.WithNodeRenderers(util.Prioritized(mathRenderer{}, 500))),
+		)
+	}
+	return goldmark.New(options...)
+}
+
+func newContentPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[a-zA-Z0-9+#-]+$`)).OnElements("code")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^math (inline|display)$`)).OnElements("span")
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	return policy
+}
+
+func IsContentFormat(format string) bool {
+	return format == models.ContentFormatPlain || format == models.ContentFormatMarkdown || format == models.ContentFormatMarkdownMath
+}
+
+func markdownFor(format string) goldmark.Markdown {
+	if format == models.ContentFormatMarkdownMath {
+		return markdownMath
+	}
+	return markdown
+}
+
+// SanitizeContent removes raw HTML from markdown text and returns the
+// problems that can't be fixed by removing something, such as links with
+// a javascript: URL or formulas using blocked LaTeX commands. Plain text is
+// returned as it is; it is always escaped when rendered.
+func SanitizeContent(format string, content string) (string, []string) {
+	if format != models.ContentFormatMarkdown && format != models.ContentFormatMarkdownMath {
+		return content, nil
+	}
+
+	// Removing one tag can join the text around it into a new one, so
+	// repeat until nothing is left to remove.
+	source := []byte(content)
+	for {
+		document := markdownFor(format).Parser().Parse(text.NewReader(source))
+		segments := rawHTMLSegments(document)
+		if len(segments) == 0 {
+			return string(source), contentProblems(document, source)
+		}
+		cleaned := removeSegments(source, segments)
+		if len(cleaned) == len(source) {
+			return string(source), contentProblems(document, source)
+		}
+		source = cleaned
+	}
+}
+
+func rawHTMLSegments(document ast.Node) []text.Segment {
+	segments := make([]text.Segment, 0)
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *ast.RawHTML:
+			for i := 0; i < node.Segments.Len(); i++ {
+				segments = append(segments, node.Segments.At(i))
+			}
+		case *ast.HTMLBlock:
+			for i := 0; i < node.Lines().Len(); i++ {
+				segments = append(segments, node.Lines().At(i))
+			}
+			if node.HasClosure() {
+				segments = append(segments, node.ClosureLine)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return segments
+}
+
+func removeSegments(source []byte, segments []text.Segment) []byte {
+	slices.SortFunc(segments, func(a, b text.Segment) int {
+		return a.Start - b.Start
+	})
+	cleaned := make([]byte, 0, len(source))
+	position := 0
+	for _, segment := range segments {
+		if segment.Start < position {
+			segment.Start = position
+		}
+		if segment.Stop <= segment.Start {
+			continue
+		}
+		cleaned = append(cleaned, source[position:segment.Start]...)
+		position = segment.Stop
+	}
+	return append(cleaned, source[position:]...)
+}
+
+func contentProblems(document ast.Node, source []byte) []string {
+	problems := make([]string, 0)
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *ast.Link:
+			problems = append(problems, checkLink(string(node.Destination))...)
+		case *ast.Image:
+			problems = append(problems, checkLink(string(node.Destination))...)
+		case *ast.AutoLink:
+			problems = append(problems, checkLink(string(node.URL(source)))...)
+		case *mathNode:
+			problems = append(problems, CheckLatex(string(node.Literal))...)
+		}
+		return ast.WalkContinue, nil
+	})
+	return problems
+}
+
+func checkLink(destination string) []string {
+	match := linkScheme.FindStringSubmatch(strings.TrimSpace(destination))
+	if match == nil || slices.Contains(linkSchemes, strings.ToLower(match[1])) {
+		return nil
+	}
+	return []string{fmt.Sprintf("link %q should use http, https or mailto", destination)}
+}
+
+// CheckLatex returns the problems of a formula: unbalanced braces and
+// commands the renderer must not run.
+func CheckLatex(formula string) []string {
+	problems := make([]string, 0)
+	depth := 0
+	for i := 0; i < len(formula) && depth >= 0; i++ {
+		switch formula[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	if depth != 0 {
+		problems = append(problems, fmt.Sprintf("formula %q has unbalanced braces", formula))
+	}
+	for _, match := range latexCommand.FindAllStringSubmatch(formula, -1) {
+		if slices.Contains(latexBlockedCommands, match[1]) {
+			problems = append(problems, fmt.Sprintf("formula %q uses \\%s, which is not allowed", formula, match[1]))
+		}
+	}
+	return problems
+}
+
+// RenderContent returns text as sanitized HTML. Inline content, like an
+// option, is not wrapped in a paragraph.
+func RenderContent(format string, content string, inline bool) string {
+	if content == "" {
+		return ""
+	}
+	if format != models.ContentFormatMarkdown && format != models.ContentFormatMarkdownMath {
+		escaped := strings.ReplaceAll(html.EscapeString(content), "\n", "<br>")
+		if inline {
+			return escaped
+		}
+		return "<p>" + escaped + "</p>"
+	}
+
+	var buffer bytes.Buffer
+	if err := markdownFor(format).Convert([]byte(content), &buffer); err != nil {
+		return html.EscapeString(content)
+	}
+	rendered := contentPolicy.SanitizeBytes(buffer.Bytes())
+	if inline {
+		if match := paragraphSingle.FindSubmatch(rendered); match != nil && !bytes.Contains(match[1], []byte("<p>")) {
+			rendered = match[1]
+		}
+	}
+	return strings.TrimSpace(string(rendered))
+}
+
+// RenderQuestion fills in the HTML of the text a student sees.
+func RenderQuestion(question *models.Question) {
+	question.HTML = &models.RenderedContent{
+		Question: RenderContent(question.Format, question.QuestionName, false),
+		Options:  renderOptions(question.Format, question.Options),
+	}
+}
+
+func RenderQuestions(questions []models.Question) {
+	for i := range questions {
+		RenderQuestion(&questions[i])
+	}
+}
+
+// RenderQuestionDetail fills in the HTML of a question including its
+// explanation and option feedback, for authors and reviewers.
+func RenderQuestionDetail(question *models.QuestionUnmarshal) {
+	question.HTML = &models.RenderedContent{
+		Question:    RenderContent(question.Format, question.QuestionName, false),
+		Options:     renderOptions(question.Format, question.Options),
+		Explanation: RenderContent(question.Format, question.Explanation, false),
+	}
+	if len(question.OptionFeedback) != 0 {
+		question.HTML.OptionFeedback = make(map[string]string)
+		for option, feedback := range question.OptionFeedback {
+			question.HTML.OptionFeedback[option] = RenderContent(question.Format, feedback, false)
+		}
+	}
+}
+
+func renderOptions(format string, options []string) []string {
+	rendered := make([]string, len(options))
+	for i, option := range options {
+		rendered[i] = RenderContent(format, option, true)
+	}
+	return rendered
+}
+
+var kindMath = ast.NewNodeKind("Math")
+
+// mathNode is a LaTeX formula between $ (inline) or $$ (display)
+// delimiters. Its content is kept as written so that markdown emphasis and
+// escapes don't change the formula.
+type mathNode struct {
+	ast.BaseInline
+	Display bool
+	Literal []byte
+}
+
+func (node *mathNode) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (node *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(node, source, level, map[string]string{"Literal": string(node.Literal)}, nil)
+}
+
+type mathParser struct{}
+
+func (mathParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	delimiter := 1
+	if len(line) > 1 && line[1] == '$' {
+		delimiter = 2
+	}
+	// Like pandoc, "$ 5 and $ 10" is not a formula: inline math can't start
+	// with a space.
+	if delimiter == 1 && (len(line) < 2 || unicode.IsSpace(rune(line[1]))) {
+		return nil
+	}
+
+	savedLine, savedSegment := block.Position()
+	block.Advance(delimiter)
+	literal := make([]byte, 0)
+	for {
+		line, _ = block.PeekLine()
+		if line == nil {
+			break
+		}
+		if end := mathCloser(line, delimiter); end >= 0 {
+			literal = append(literal, line[:end]...)
+			block.Advance(end + delimiter)
+			return &mathNode{Display: delimiter == 2, Literal: literal}
+		}
+		// Inline formulas end on the line they start on.
+		if delimiter == 1 {
+			break
+		}
+		literal = append(literal, line...)
+		block.AdvanceLine()
+	}
+	block.SetPosition(savedLine, savedSegment)
+	return nil
+}
+
+// mathCloser returns where the closing delimiter starts in line, or -1.
+func mathCloser(line []byte, delimiter int) int {
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case delimiter == 2 && line[i] == '$' && i+1 < len(line) && line[i+1] == '$':
+			return i
+		case delimiter == 1 && line[i] == '$' && i > 0 && !unicode.IsSpace(rune(line[i-1])) &&
+			(i+1 == len(line) || !unicode.IsDigit(rune(line[i+1]))):
+			return i
+		}
+	}
+	return -1
+}
+
+// mathRenderer writes formulas with the \( \) and \[ \] delimiters that
+// KaTeX and MathJax look for, escaped like any other text.
+type mathRenderer struct{}
+
+func (mathRenderer) RegisterFuncs(registerer renderer.NodeRendererFuncRegisterer) {
+	registerer.Register(kindMath, renderMath)
+}
+
+func renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	math := node.(*mathNode)
+	formula := html.EscapeString(string(math.Literal))
+	if math.Display {
+		fmt.Fprintf(w, `<span class="math display">\[%s\]</span>`, formula)
+	} else {
+		fmt.Fprintf(w, `<span class="math inline">\(%s\)</span>`, formula)
+	}
+	return ast.WalkSkipChildren, nil
+}
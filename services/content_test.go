@@ -0,0 +1,89 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+)
+
+func TestSanitizeContent(t *testing.T) {
+	// Test case: Raw HTML is removed from markdown, code is kept as written
+	sanitized, problems := SanitizeContent(models.ContentFormatMarkdown, "Run <script>alert(1)</script> `<b>x</b>`")
+	assert.Empty(t, problems)
+	assert.Equal(t, "Run alert(1) `<b>x</b>`", sanitized)
+
+	// Test case: Removing a tag can't leave a new one behind
+	sanitized, _ = SanitizeContent(models.ContentFormatMarkdown, "<<b>script>alert(1)<</b>/script>")
+	assert.NotContains(t, sanitized, "<script>")
+
+	// Test case: HTML blocks are removed
+	sanitized, _ = SanitizeContent(models.ContentFormatMarkdown, "Intro\n\n<div onclick=\"x()\">\nhi\n</div>\n\nEnd")
+	assert.NotContains(t, sanitized, "onclick")
+	assert.Contains(t, sanitized, "End")
+
+	// Test case: Links with unsafe schemes are reported
+	_, problems = SanitizeContent(models.ContentFormatMarkdown, "[click](javascript:alert(1)) and [ok](https://example.com)")
+	assert.Equal(t, []string{`link "javascript:alert(1)" should use http, https or mailto`}, problems)
+
+	// Test case: Plain text is left alone
+	sanitized, problems = SanitizeContent("", "Is 1 < 2 <b>?")
+	assert.Empty(t, problems)
+	assert.Equal(t, "Is 1 < 2 <b>?", sanitized)
+
+	// Test case: Formulas are checked, but only in markdown_math
+	_, problems = SanitizeContent(models.ContentFormatMarkdownMath, `Solve $\href{javascript:x}{y}$ and $\frac{1}{2$`)
+	assert.Len(t, problems, 2)
+	_, problems = SanitizeContent(models.ContentFormatMarkdown, `Costs $\href{x}{y}$`)
+	assert.Empty(t, problems)
+}
+
+func TestRenderContent(t *testing.T) {
+	// Test case: Plain text is escaped
+	assert.Equal(t, "<p>1 &lt; 2</p>", RenderContent("", "1 < 2", false))
+	assert.Equal(t, "a<br>b", RenderContent("", "a\nb", true))
+
+	// Test case: Markdown is rendered and inline content is unwrapped
+	assert.Equal(t, "<p><strong>bold</strong> and <code>x &lt; y</code></p>", RenderContent(models.ContentFormatMarkdown, "**bold** and `x < y`", false))
+	assert.Equal(t, "<em>option</em>", RenderContent(models.ContentFormatMarkdown, "*option*", true))
+
+	// Test case: Code blocks keep their language class
+	assert.Contains(t, RenderContent(models.ContentFormatMarkdown, "```go\nfmt.Println(1)\n```", false), `<code class="language-go">`)
+
+	// Test case: Raw HTML and unsafe links never reach the output
+	rendered := RenderContent(models.ContentFormatMarkdown, "<img src=x onerror=alert(1)> [x](javascript:alert(1))", false)
+	assert.NotContains(t, rendered, "onerror")
+	assert.NotContains(t, rendered, "javascript:")
+
+	// Test case: Formulas are kept as written and escaped
+	assert.Equal(t, `<p>Area is <span class="math inline">\(\pi r^2 &lt; a_*b_*\)</span></p>`,
+		RenderContent(models.ContentFormatMarkdownMath, `Area is $\pi r^2 < a_*b_*$`, false))
+	assert.Equal(t, `<p><span class="math display">\[x =
+y\]</span></p>`, RenderContent(models.ContentFormatMarkdownMath, "$$x =\ny$$", false))
+
+	// Test case: Amounts of money are not formulas
+	assert.Equal(t, "<p>$5 and $10</p>", RenderContent(models.ContentFormatMarkdownMath, "$5 and $10", false))
+}
+
+func TestValidateQuestionContent(t *testing.T) {
+	// Test case: Answers still match options after sanitizing
+	question := models.QuestionUnmarshal{
+		QuestionName:  "Pick <i>one</i>",
+		Format:        "Markdown",
+		Options:       []string{"**a**<br>", "b"},
+		CorrectAnswer: "**a**<br>",
+		Distractors:   []string{"b"},
+	}
+	assert.Empty(t, ValidateQuestion(&question))
+	assert.Equal(t, models.ContentFormatMarkdown, question.Format)
+	assert.Equal(t, "Pick one", question.QuestionName)
+	assert.Equal(t, "**a**", question.CorrectAnswer)
+
+	// Test case: Unknown format
+	question.Format = "html"
+	assert.Contains(t, ValidateQuestion(&question), "format should be plain, markdown or markdown_math")
+
+	// Test case: Plain is stored as no format
+	question.Format = "plain"
+	assert.Empty(t, ValidateQuestion(&question))
+	assert.Equal(t, "", question.Format)
+}
@@ -33,7 +33,11 @@ func GetConnection() *mongo.Client {
 	return DB
 }
 
+func GetDatabase(client *mongo.Client) *mongo.Database {
+	return client.Database("Pearson")
+}
+
 func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	collection := client.Database("Pearson").Collection(collectionName)
+	collection := GetDatabase(client).Collection(collectionName)
 	return collection
 }
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// What an upload does with questions that duplicate one in the bank or an
+// earlier one in the same file.
+const (
+	DuplicateSkip  = "skip"
+	DuplicateMerge = "merge"
+	DuplicateFlag  = "flag"
+)
+
+// What happened to each duplicate, as reported back to the uploader.
+const (
+	DuplicateSkipped = "skipped"
+	DuplicateMerged  = "merged"
+	DuplicateFlagged = "flagged"
+)
+
+// DefaultDuplicateThreshold is the shingle similarity from which two
+// questions count as near-duplicates.
+const DefaultDuplicateThreshold = 0.7
+
+// shingleSize is the number of words in a shingle. Shorter texts are
+// compared by their words.
+const shingleSize = 2
+
+func IsDuplicatePolicy(name string) bool {
+	return name == DuplicateSkip || name == DuplicateMerge || name == DuplicateFlag
+}
+
+// DuplicateMatch reports an uploaded question that duplicates another one
+// and what the upload did with it.
+type DuplicateMatch struct {
+	Index       int                `json:"index"`
+	Question    string             `json:"question"`
+	DuplicateOf primitive.ObjectID `json:"duplicate_of"`
+	Existing    string             `json:"existing_question"`
+	InUpload    bool               `json:"in_upload"`
+	Exact       bool               `json:"exact"`
+	Similarity  float64            `json:"similarity"`
+	Action      string             `json:"action"`
+}
+
+// DuplicatePair is one entry of the duplicate report of the bank.
+type DuplicatePair struct {
+	First      DuplicateQuestion `json:"first"`
+	Second     DuplicateQuestion `json:"second"`
+	Exact      bool              `json:"exact"`
+	Similarity float64           `json:"similarity"`
+}
+
+type DuplicateQuestion struct {
+	ID       primitive.ObjectID `json:"id"`
+	Topic    string             `json:"topic"`
+	Question string             `json:"question"`
+	Status   string             `json:"status"`
+}
+
+// NormalizeQuestionText lowercases text and reduces it to its words and
+// operators, so that punctuation, spacing and case don't hide a duplicate
+// while "2 + 3" and "2 * 3" stay apart.
+func NormalizeQuestionText(text string) string {
+	tokens := make([]string, 0)
+	var word strings.Builder
+	flush := func() {
+		if word.Len() != 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case unicode.IsSymbol(r) || strings.ContainsRune(questionOperators, r):
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return strings.Join(tokens, " ")
+}
+
+// questionOperators are the punctuation characters that change what a
+// question asks. Symbols such as + and = are always kept.
+const questionOperators = "*/%-()[]{}"
+
+// QuestionFingerprint is equal for questions with the same normalized stem
+// and options, in any order. Template questions also need the same answer
+// and distractor formulas.
+func QuestionFingerprint(question models.QuestionUnmarshal) string {
+	options := make([]string, 0, len(question.Options))
+	for _, option := range question.Options {
+		options = append(options, NormalizeQuestionText(option))
+	}
+	sort.Strings(options)
+	fingerprint := NormalizeQuestionText(question.QuestionName) + "\x00" + strings.Join(options, "\x00")
+	if template := question.Template; template != nil {
+		distractors := make([]string, 0, len(template.Distractors))
+		for _, distractor := range template.Distractors {
+			distractors = append(distractors, NormalizeQuestionText(distractor))
+		}
+		sort.Strings(distractors)
+		fingerprint += "\x00\x00" + NormalizeQuestionText(template.Answer) + "\x00" + strings.Join(distractors, "\x00")
+	}
+	return fingerprint
+}
+
+func questionShingles(question models.QuestionUnmarshal) map[string]bool {
+	options := slices.Clone(question.Options)
+	if template := question.Template; template != nil {
+		options = append(options, template.Answer)
+		options = append(options, template.Distractors...)
+	}
+	sort.Strings(options)
+	words := strings.Fields(NormalizeQuestionText(question.QuestionName + " " + strings.Join(options, " ")))
+
+	shingles := make(map[string]bool)
+	if len(words) < shingleSize {
+		for _, word := range words {
+			shingles[word] = true
+		}
+		return shingles
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		shingles[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+	return shingles
+}
+
+// duplicateIndex finds the most similar earlier question without comparing
+// every pair: only questions sharing a shingle are candidates.
+type duplicateIndex struct {
+	questions    []models.QuestionUnmarshal
+	shingles     []map[string]bool
+	fingerprints map[string]int
+	postings     map[string][]int
+}
+
+func newDuplicateIndex() *duplicateIndex {
+	return &duplicateIndex{
+		fingerprints: make(map[string]int),
+		postings:     make(map[string][]int),
+	}
+}
+
+func (index *duplicateIndex) add(question models.QuestionUnmarshal) {
+	position := len(index.questions)
+	shingles := questionShingles(question)
+	index.questions = append(index.questions, question)
+	index.shingles = append(index.shingles, shingles)
+	fingerprint := QuestionFingerprint(question)
+	if _, ok := index.fingerprints[fingerprint]; !ok {
+		index.fingerprints[fingerprint] = position
+	}
+	for shingle := range shingles {
+		index.postings[shingle] = append(index.postings[shingle], position)
+	}
+}
+
+// match returns the position of the question most similar to question, if
+// it reaches the threshold. Exact matches win over near-duplicates.
+func (index *duplicateIndex) match(question models.QuestionUnmarshal, threshold float64) (int, float64, bool, bool) {
+	if position, ok := index.fingerprints[QuestionFingerprint(question)]; ok {
+		return position, 1, true, true
+	}
+
+	shingles := questionShingles(question)
+	shared := make(map[int]int)
+	for shingle := range shingles {
+		for _, position := range index.postings[shingle] {
+			shared[position]++
+		}
+	}
+
+	best, bestSimilarity := -1, 0.0
+	for position, count := range shared {
+		similarity := float64(count) / float64(len(shingles)+len(index.shingles[position])-count)
+		if similarity > bestSimilarity || similarity == bestSimilarity && position < best {
+			best, bestSimilarity = position, similarity
+		}
+	}
+	if best < 0 || bestSimilarity < threshold {
+		return -1, 0, false, false
+	}
+	return best, bestSimilarity, false, true
+}
+
+// FindDuplicates compares each uploaded question with the existing ones and
+// with the uploaded questions before it that were not duplicates
+// themselves. Matches against an earlier upload are marked InUpload and
+// point at that question's ID.
+func FindDuplicates(existing, uploaded []models.QuestionUnmarshal, threshold float64) []DuplicateMatch {
+	index := newDuplicateIndex()
+	for _, question := range existing {
+		index.add(question)
+	}
+
+	matches := make([]DuplicateMatch, 0)
+	for i, question := range uploaded {
+		position, similarity, exact, ok := index.match(question, threshold)
+		if !ok {
+			index.add(question)
+			continue
+		}
+		original := index.questions[position]
+		matches = append(matches, DuplicateMatch{
+			Index:       i,
+			Question:    question.QuestionName,
+			DuplicateOf: original.ID,
+			Existing:    original.QuestionName,
+			InUpload:    position >= len(existing),
+			Exact:       exact,
+			Similarity:  similarity,
+		})
+	}
+	return matches
+}
+
+// ResolveDuplicates applies the duplicate policy of an upload and returns
+// the uploaded questions that should still be inserted. Skipped and merged
+// duplicates are dropped; flagged ones are kept with DuplicateOf set. Merges
+// into questions already in the bank are saved as new revisions.
+func ResolveDuplicates(ctx context.Context, policy string, existing, uploaded []models.QuestionUnmarshal, matches []DuplicateMatch, changedBy string) ([]models.QuestionUnmarshal, error) {
+	existingById := make(map[primitive.ObjectID]models.QuestionUnmarshal, len(existing))
+	for _, question := range existing {
+		existingById[question.ID] = question
+	}
+	uploadedById := make(map[primitive.ObjectID]int, len(uploaded))
+	for i, question := range uploaded {
+		uploadedById[question.ID] = i
+	}
+
+	dropped := make(map[int]bool)
+	merges := make(map[primitive.ObjectID]models.QuestionUnmarshal)
+	for i := range matches {
+		match := &matches[i]
+		duplicate := uploaded[match.Index]
+		switch policy {
+		case DuplicateFlag:
+			uploaded[match.Index].DuplicateOf = append(duplicate.DuplicateOf, match.DuplicateOf)
+			match.Action = DuplicateFlagged
+		case DuplicateMerge:
+			dropped[match.Index] = true
+			match.Action = DuplicateMerged
+			if j, ok := uploadedById[match.DuplicateOf]; ok {
+				uploaded[j] = MergeDuplicate(uploaded[j], duplicate)
+				continue
+			}
+			original, ok := merges[match.DuplicateOf]
+			if !ok {
+				original = existingById[match.DuplicateOf]
+			}
+			merges[match.DuplicateOf] = MergeDuplicate(original, duplicate)
+		default:
+			dropped[match.Index] = true
+			match.Action = DuplicateSkipped
+		}
+	}
+
+	for id, merged := range merges {
+		if _, _, err := UpdateQuestion(ctx, existingById[id], merged, "Merged a duplicate from an upload", changedBy); err != nil {
+			return nil, err
+		}
+	}
+
+	kept := make([]models.QuestionUnmarshal, 0, len(uploaded)-len(dropped))
+	for i, question := range uploaded {
+		if !dropped[i] {
+			kept = append(kept, question)
+		}
+	}
+	return kept, nil
+}
+
+// MergeDuplicate folds an uploaded duplicate into the question it repeats.
+// The stem, options and answer key of the original are kept. Tags are
+// combined and details or translations the original lacks are taken from
+// the upload.
+func MergeDuplicate(original, duplicate models.QuestionUnmarshal) models.QuestionUnmarshal {
+	merged := original
+	if tags := NormalizeTags(append(slices.Clone(original.Tags), duplicate.Tags...)); len(tags) != len(original.Tags) {
+		merged.Tags = tags
+	}
+	if merged.Explanation == "" {
+		merged.Explanation = duplicate.Explanation
+	}
+	if merged.Difficulty == "" {
+		merged.Difficulty = duplicate.Difficulty
+	}
+	if merged.Points == 0 {
+		merged.Points = duplicate.Points
+	}
+	merged.OptionFeedback = maps.Clone(original.OptionFeedback)
+	for option, feedback := range duplicate.OptionFeedback {
+		if _, ok := merged.OptionFeedback[option]; ok || !slices.Contains(merged.Options, option) {
+			continue
+		}
+		if merged.OptionFeedback == nil {
+			merged.OptionFeedback = make(map[string]string)
+		}
+		merged.OptionFeedback[option] = feedback
+	}
+	// Options are translated by position, so translations only carry over
+	// when the options are in the same order.
+	if slices.Equal(original.Options, duplicate.Options) {
+		added := make(models.Translations)
+		for locale, translation := range duplicate.Translations {
+			if _, ok := original.Translations[locale]; !ok {
+				added[locale] = translation
+			}
+		}
+		if len(added) != 0 {
+			merged.Translations = maps.Clone(original.Translations)
+			if merged.Translations == nil {
+				merged.Translations = make(models.Translations)
+			}
+			maps.Copy(merged.Translations, added)
+		}
+	}
+	return merged
+}
+
+// GetDuplicateCandidates returns the questions of a topic that uploads are
+// checked against. Like DuplicateReport, it leaves out retired questions so
+// a corrected copy of one can be uploaded.
+func GetDuplicateCandidates(ctx context.Context, topic string) ([]models.QuestionUnmarshal, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	cursor, err := collection.Find(ctx, bson.M{
+		"topic":  topic,
+		"status": bson.M{"$ne": models.QuestionStatusRetired},
+	})
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]models.QuestionUnmarshal, 0)
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// DuplicateReport scans the questions matching filter for pairs that are
+// exact or near-duplicates, most similar first. Retired questions are left
+// out.
+func DuplicateReport(ctx context.Context, filter bson.M, threshold float64) ([]DuplicatePair, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	filter["status"] = bson.M{"$ne": models.QuestionStatusRetired}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]models.QuestionUnmarshal, 0)
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return findDuplicatePairs(questions, threshold), nil
+}
+
+func findDuplicatePairs(questions []models.QuestionUnmarshal, threshold float64) []DuplicatePair {
+	shingles := make([]map[string]bool, len(questions))
+	fingerprints := make(map[string][]int)
+	postings := make(map[string][]int)
+	for i, question := range questions {
+		shingles[i] = questionShingles(question)
+		fingerprint := QuestionFingerprint(question)
+		fingerprints[fingerprint] = append(fingerprints[fingerprint], i)
+		for shingle := range shingles[i] {
+			postings[shingle] = append(postings[shingle], i)
+		}
+	}
+
+	pairs := make([]DuplicatePair, 0)
+	for i, question := range questions {
+		exact := make(map[int]bool)
+		for _, j := range fingerprints[QuestionFingerprint(question)] {
+			exact[j] = true
+		}
+		shared := make(map[int]int)
+		for shingle := range shingles[i] {
+			for _, j := range postings[shingle] {
+				shared[j]++
+			}
+		}
+		for j := range exact {
+			if _, ok := shared[j]; !ok {
+				shared[j] = 0
+			}
+		}
+
+		for j, count := range shared {
+			if j <= i {
+				continue
+			}
+			similarity := 1.0
+			if !exact[j] {
+				similarity = float64(count) / float64(len(shingles[i])+len(shingles[j])-count)
+			}
+			if similarity < threshold {
+				continue
+			}
+			pairs = append(pairs, DuplicatePair{
+				First:      duplicateQuestion(questions[i]),
+				Second:     duplicateQuestion(questions[j]),
+				Exact:      exact[j],
+				Similarity: similarity,
+			})
+		}
+	}
+
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a].Similarity != pairs[b].Similarity {
+			return pairs[a].Similarity > pairs[b].Similarity
+		}
+		if pairs[a].First.ID != pairs[b].First.ID {
+			return pairs[a].First.ID.Hex() < pairs[b].First.ID.Hex()
+		}
+		return pairs[a].Second.ID.Hex() < pairs[b].Second.ID.Hex()
+	})
+	return pairs
+}
+
+func duplicateQuestion(question models.QuestionUnmarshal) DuplicateQuestion {
+	return DuplicateQuestion{
+		ID:       question.ID,
+		Topic:    question.Topic,
+		Question: question.QuestionName,
+		Status:   QuestionStatus(question),
+	}
+}
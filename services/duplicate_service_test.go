@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func duplicateQuestionFixture(stem string, options ...string) models.QuestionUnmarshal {
+	return models.QuestionUnmarshal{ID: primitive.NewObjectID(), QuestionName: stem, Options: options, CorrectAnswer: options[0]}
+}
+
+func TestQuestionFingerprint(t *testing.T) {
+	// Test case: Case, punctuation, spacing and option order don't matter
+	a := duplicateQuestionFixture("Which river is the holiest in India?", "Ganga", "Yamuna")
+	b := duplicateQuestionFixture("which  river is the HOLIEST in India", "yamuna", "ganga.")
+	assert.Equal(t, QuestionFingerprint(a), QuestionFingerprint(b))
+
+	// Test case: Different options are a different question
+	c := duplicateQuestionFixture("Which river is the holiest in India?", "Ganga", "Kaveri")
+	assert.NotEqual(t, QuestionFingerprint(a), QuestionFingerprint(c))
+}
+
+func TestQuestionFingerprintKeepsOperators(t *testing.T) {
+	// Test case: Questions that differ only in an operator are different
+	sum := duplicateQuestionFixture("What is 2 + 3?", "5", "6", "1", "9")
+	product := duplicateQuestionFixture("What is 2 * 3?", "5", "6", "1", "9")
+	assert.NotEqual(t, QuestionFingerprint(sum), QuestionFingerprint(product))
+	assert.Empty(t, FindDuplicates([]models.QuestionUnmarshal{sum}, []models.QuestionUnmarshal{product}, DefaultDuplicateThreshold))
+
+	// Test case: Template questions are compared by their formulas
+	template := func(stem, answer string, distractors ...string) models.QuestionUnmarshal {
+		return models.QuestionUnmarshal{ID: primitive.NewObjectID(), QuestionName: stem, Template: &models.QuestionTemplate{
+			Variables:   []models.TemplateVariable{{Name: "a", Min: 1, Max: 9}, {Name: "b", Min: 1, Max: 9}},
+			Answer:      answer,
+			Distractors: distractors,
+		}}
+	}
+	sumTemplate := template("What is {a} + {b}?", "a + b", "a - b", "a + b + 1")
+	productTemplate := template("What is {a} * {b}?", "a * b", "a * b + 1", "a + b")
+	assert.NotEqual(t, QuestionFingerprint(sumTemplate), QuestionFingerprint(productTemplate))
+	assert.Empty(t, FindDuplicates([]models.QuestionUnmarshal{sumTemplate}, []models.QuestionUnmarshal{productTemplate}, DefaultDuplicateThreshold))
+
+	// Test case: The same template with reordered distractors is an exact duplicate
+	reordered := template("What is {a} + {b}", "a+b", "a + b + 1", "a - b")
+	matches := FindDuplicates([]models.QuestionUnmarshal{sumTemplate}, []models.QuestionUnmarshal{reordered}, DefaultDuplicateThreshold)
+	if assert.Len(t, matches, 1) {
+		assert.True(t, matches[0].Exact)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	existing := []models.QuestionUnmarshal{
+		duplicateQuestionFixture("Which river is considered the holiest river in India?", "Ganga", "Yamuna", "Godavari", "Narmada"),
+		duplicateQuestionFixture("What is the capital of France?", "Paris", "Lyon"),
+	}
+	uploaded := []models.QuestionUnmarshal{
+		duplicateQuestionFixture("What is the capital of France", "Lyon", "Paris"),
+		duplicateQuestionFixture("Which river is considered to be the holiest river in India?", "Ganga", "Yamuna", "Godavari", "Narmada"),
+		duplicateQuestionFixture("What is the capital of Spain?", "Madrid", "Seville"),
+		duplicateQuestionFixture("What is the capital of Spain?", "Madrid", "Seville"),
+	}
+
+	matches := FindDuplicates(existing, uploaded, DefaultDuplicateThreshold)
+	assert.Len(t, matches, 3)
+
+	// Test case: Exact match on normalized text
+	assert.Equal(t, 0, matches[0].Index)
+	assert.Equal(t, existing[1].ID, matches[0].DuplicateOf)
+	assert.True(t, matches[0].Exact)
+
+	// Test case: Near-duplicate by shingles
+	assert.Equal(t, 1, matches[1].Index)
+	assert.Equal(t, existing[0].ID, matches[1].DuplicateOf)
+	assert.False(t, matches[1].Exact)
+	assert.Greater(t, matches[1].Similarity, DefaultDuplicateThreshold)
+
+	// Test case: Duplicates within the upload
+	assert.Equal(t, 3, matches[2].Index)
+	assert.Equal(t, uploaded[2].ID, matches[2].DuplicateOf)
+	assert.True(t, matches[2].InUpload)
+
+	// Test case: A stricter threshold only keeps exact matches
+	assert.Len(t, FindDuplicates(existing, uploaded, 0.99), 2)
+}
+
+func TestResolveDuplicates(t *testing.T) {
+	uploaded := []models.QuestionUnmarshal{
+		duplicateQuestionFixture("What is the capital of Spain?", "Madrid", "Seville"),
+		duplicateQuestionFixture("What is the capital of Spain?", "Madrid", "Seville"),
+	}
+	uploaded[1].Tags = []string{"europe"}
+	uploaded[1].Explanation = "Madrid has been the capital since 1561."
+
+	// Test case: Skipped duplicates are not inserted
+	matches := FindDuplicates(nil, uploaded, DefaultDuplicateThreshold)
+	kept, err := ResolveDuplicates(context.Background(), DuplicateSkip, nil, append([]models.QuestionUnmarshal{}, uploaded...), matches, "author")
+	assert.NoError(t, err)
+	assert.Len(t, kept, 1)
+	assert.Equal(t, DuplicateSkipped, matches[0].Action)
+
+	// Test case: Flagged duplicates are inserted and point at the original
+	matches = FindDuplicates(nil, uploaded, DefaultDuplicateThreshold)
+	kept, _ = ResolveDuplicates(context.Background(), DuplicateFlag, nil, append([]models.QuestionUnmarshal{}, uploaded...), matches, "author")
+	assert.Len(t, kept, 2)
+	assert.Equal(t, []primitive.ObjectID{uploaded[0].ID}, kept[1].DuplicateOf)
+	assert.Equal(t, DuplicateFlagged, matches[0].Action)
+
+	// Test case: Merged duplicates add what the original lacks
+	matches = FindDuplicates(nil, uploaded, DefaultDuplicateThreshold)
+	kept, _ = ResolveDuplicates(context.Background(), DuplicateMerge, nil, append([]models.QuestionUnmarshal{}, uploaded...), matches, "author")
+	assert.Len(t, kept, 1)
+	assert.Equal(t, []string{"europe"}, kept[0].Tags)
+	assert.Equal(t, uploaded[1].Explanation, kept[0].Explanation)
+}
+
+func TestMergeDuplicate(t *testing.T) {
+	original := duplicateQuestionFixture("Pick one", "a", "b")
+	original.Tags = []string{"x"}
+	original.OptionFeedback = map[string]string{"b": "No"}
+	duplicate := duplicateQuestionFixture("Pick one", "a", "b")
+	duplicate.Tags = []string{"x", "y"}
+	duplicate.CorrectAnswer = "b"
+	duplicate.OptionFeedback = map[string]string{"b": "Wrong", "c": "Unknown option"}
+
+	merged := MergeDuplicate(original, duplicate)
+
+	// Test case: The answer key and existing feedback are kept
+	assert.Equal(t, "a", merged.CorrectAnswer)
+	assert.Equal(t, map[string]string{"b": "No"}, merged.OptionFeedback)
+	assert.Equal(t, []string{"x", "y"}, merged.Tags)
+
+	// Test case: Nothing to add leaves the original unchanged
+	assert.Empty(t, DiffQuestions(original, MergeDuplicate(original, original)))
+}
+
+func TestFindDuplicatePairs(t *testing.T) {
+	questions := []models.QuestionUnmarshal{
+		duplicateQuestionFixture("What is the capital of France?", "Paris", "Lyon"),
+		duplicateQuestionFixture("Which planet is known as the red planet?", "Mars", "Venus"),
+		duplicateQuestionFixture("what is the capital of france", "Lyon", "Paris"),
+		duplicateQuestionFixture("Which planet is known as the red planet?", "Mars", "Venus", "Jupiter"),
+	}
+
+	pairs := findDuplicatePairs(questions, DefaultDuplicateThreshold)
+	assert.Len(t, pairs, 2)
+
+	// Test case: Exact pairs come first
+	assert.True(t, pairs[0].Exact)
+	assert.ElementsMatch(t, []primitive.ObjectID{questions[0].ID, questions[2].ID}, []primitive.ObjectID{pairs[0].First.ID, pairs[0].Second.ID})
+	assert.False(t, pairs[1].Exact)
+	assert.Less(t, pairs[1].Similarity, 1.0)
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Formula is a parsed arithmetic expression over template variables.
+// Formulas support numbers, variables, + - * / % ^, parentheses, the
+// constants pi and e and the functions in formulaFunctions.
+type Formula struct {
+	source string
+	root   formulaNode
+}
+
+type formulaNode interface {
+	eval(values map[string]float64) (float64, error)
+}
+
+var ErrDivisionByZero = errors.New("division by zero")
+
+type formulaFunction struct {
+	arguments int
+	apply     func(args []float64) (float64, error)
+}
+
+func unaryFunction(apply func(float64) float64) formulaFunction {
+	return formulaFunction{arguments: 1, apply: func(args []float64) (float64, error) {
+		return apply(args[0]), nil
+	}}
+}
+
+var formulaFunctions = map[string]formulaFunction{
+	"abs":   unaryFunction(math.Abs),
+	"ceil":  unaryFunction(math.Ceil),
+	"cos":   unaryFunction(math.Cos),
+	"exp":   unaryFunction(math.Exp),
+	"floor": unaryFunction(math.Floor),
+	"ln":    unaryFunction(math.Log),
+	"log":   unaryFunction(math.Log10),
+	"round": unaryFunction(math.Round),
+	"sin":   unaryFunction(math.Sin),
+	"sqrt":  unaryFunction(math.Sqrt),
+	"tan":   unaryFunction(math.Tan),
+	"max": {arguments: 2, apply: func(args []float64) (float64, error) {
+		return math.Max(args[0], args[1]), nil
+	}},
+	"min": {arguments: 2, apply: func(args []float64) (float64, error) {
+		return math.Min(args[0], args[1]), nil
+	}},
+	"pow": {arguments: 2, apply: func(args []float64) (float64, error) {
+		return math.Pow(args[0], args[1]), nil
+	}},
+}
+
+var formulaConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// ParseFormula parses source, accepting only the given variable names.
+func ParseFormula(source string, variables []string) (*Formula, error) {
+	parser := &formulaParser{input: source, variables: variables}
+	parser.next()
+	root, err := parser.expression()
+	if err != nil {
+		return nil, err
+	}
+	if parser.token != "" {
+		return nil, fmt.Errorf("unexpected %q at position %d", parser.token, parser.start+1)
+	}
+	return &Formula{source: source, root: root}, nil
+}
+
+// Evaluate computes the formula. Results that are not finite numbers, such
+// as the square root of a negative number, are errors.
+func (formula *Formula) Evaluate(values map[string]float64) (float64, error) {
+	value, err := formula.root.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("result is not a number")
+	}
+	return value, nil
+}
+
+func (formula *Formula) String() string {
+	return formula.source
+}
+
+type formulaNumber float64
+
+func (node formulaNumber) eval(values map[string]float64) (float64, error) {
+	return float64(node), nil
+}
+
+type formulaVariable string
+
+func (node formulaVariable) eval(values map[string]float64) (float64, error) {
+	value, ok := values[string(node)]
+	if !ok {
+		return 0, fmt.Errorf("variable %q has no value", string(node))
+	}
+	return value, nil
+}
+
+type formulaUnary struct {
+	operand formulaNode
+}
+
+func (node formulaUnary) eval(values map[string]float64) (float64, error) {
+	value, err := node.operand.eval(values)
+	return -value, err
+}
+
+type formulaBinary struct {
+	operator    byte
+	left, right formulaNode
+}
+
+func (node formulaBinary) eval(values map[string]float64) (float64, error) {
+	left, err := node.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := node.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	switch node.operator {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return left / right, nil
+	case '%':
+		if right == 0 {
+			return 0, ErrDivisionByZero
+		}
+		return math.Mod(left, right), nil
+	default:
+		return math.Pow(left, right), nil
+	}
+}
+
+type formulaCall struct {
+	function  formulaFunction
+	arguments []formulaNode
+}
+
+func (node formulaCall) eval(values map[string]float64) (float64, error) {
+	args := make([]float64, len(node.arguments))
+	for i, argument := range node.arguments {
+		value, err := argument.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = value
+	}
+	return node.function.apply(args)
+}
+
+// formulaParser is a recursive descent parser. Powers bind tighter than
+// unary minus and are right associative, so -2^2 is -4 and 2^3^2 is 512.
+type formulaParser struct {
+	input     string
+	variables []string
+	position  int
+	start     int
+	token     string
+}
+
+func (parser *formulaParser) next() {
+	for parser.position < len(parser.input) && unicode.IsSpace(rune(parser.input[parser.position])) {
+		parser.position++
+	}
+	parser.start = parser.position
+	if parser.position >= len(parser.input) {
+		parser.token = ""
+		return
+	}
+
+	c := parser.input[parser.position]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for parser.position < len(parser.input) && (isDigit(parser.input[parser.position]) || parser.input[parser.position] == '.') {
+			parser.position++
+		}
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for parser.position < len(parser.input) && (parser.input[parser.position] == '_' ||
+			unicode.IsLetter(rune(parser.input[parser.position])) || isDigit(parser.input[parser.position])) {
+			parser.position++
+		}
+	default:
+		parser.position++
+	}
+	parser.token = parser.input[parser.start:parser.position]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (parser *formulaParser) expression() (formulaNode, error) {
+	left, err := parser.term()
+	if err != nil {
+		return nil, err
+	}
+	for parser.token == "+" || parser.token == "-" {
+		operator := parser.token[0]
+		parser.next()
+		right, err := parser.term()
+		if err != nil {
+			return nil, err
+		}
+		left = formulaBinary{operator: operator, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (parser *formulaParser) term() (formulaNode, error) {
+	left, err := parser.unary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.token == "*" || parser.token == "/" || parser.token == "%" {
+		operator := parser.token[0]
+		parser.next()
+		right, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = formulaBinary{operator: operator, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (parser *formulaParser) unary() (formulaNode, error) {
+	switch parser.token {
+	case "-":
+		parser.next()
+		operand, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		return formulaUnary{operand: operand}, nil
+	case "+":
+		parser.next()
+		return parser.unary()
+	}
+	return parser.power()
+}
+
+func (parser *formulaParser) power() (formulaNode, error) {
+	base, err := parser.primary()
+	if err != nil {
+		return nil, err
+	}
+	if parser.token != "^" {
+		return base, nil
+	}
+	parser.next()
+	exponent, err := parser.unary()
+	if err != nil {
+		return nil, err
+	}
+	return formulaBinary{operator: '^', left: base, right: exponent}, nil
+}
+
+func (parser *formulaParser) primary() (formulaNode, error) {
+	token, start := parser.token, parser.start
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of formula")
+	case token == "(":
+		parser.next()
+		node, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		if parser.token != ")" {
+			return nil, fmt.Errorf("missing ) at position %d", parser.start+1)
+		}
+		parser.next()
+		return node, nil
+	case isDigit(token[0]) || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", token)
+		}
+		parser.next()
+		return formulaNumber(value), nil
+	case token[0] == '_' || unicode.IsLetter(rune(token[0])):
+		parser.next()
+		if parser.token == "(" {
+			return parser.call(token, start)
+		}
+		for _, variable := range parser.variables {
+			if variable == token {
+				return formulaVariable(token), nil
+			}
+		}
+		if value, ok := formulaConstants[strings.ToLower(token)]; ok {
+			return formulaNumber(value), nil
+		}
+		return nil, fmt.Errorf("unknown variable %q", token)
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", token, start+1)
+}
+
+func (parser *formulaParser) call(name string, start int) (formulaNode, error) {
+	function, ok := formulaFunctions[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	parser.next()
+
+	arguments := make([]formulaNode, 0, function.arguments)
+	for parser.token != ")" {
+		if len(arguments) != 0 {
+			if parser.token != "," {
+				return nil, fmt.Errorf("missing ) at position %d", parser.start+1)
+			}
+			parser.next()
+		}
+		argument, err := parser.expression()
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+	}
+	parser.next()
+
+	if len(arguments) != function.arguments {
+		return nil, fmt.Errorf("%s at position %d takes %d argument(s), not %d", name, start+1, function.arguments, len(arguments))
+	}
+	return formulaCall{function: function, arguments: arguments}, nil
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const ImportFormatGIFT = "gift"
+
+var (
+	giftTitle        = regexp.MustCompile(`^::((?:[^:\\]|\\.)*)::`)
+	giftTextFormat   = regexp.MustCompile(`^\[(html|moodle|plain|markdown)\]`)
+	giftWeight       = regexp.MustCompile(`^%(-?[0-9.]+)%`)
+	giftTrueFalse    = regexp.MustCompile(`^(?i)(T|TRUE|F|FALSE)$`)
+	giftMissingBlank = "_____"
+)
+
+// giftQuestion is one question of a GIFT file, before its answers are read.
+type giftQuestion struct {
+	line int
+	text string
+}
+
+// decodeGIFTQuestions reads the parts of Moodle's GIFT format that map onto
+// single and multiple choice questions, including true/false. Other GIFT
+// question types are skipped with a warning. Rows in the result are the line
+// numbers the questions start on.
+func decodeGIFTQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	result := ImportResult{}
+
+	blocks := make([]giftQuestion, 0)
+	var current []string
+	start, depth := 0, 0
+	for i, line := range splitLines(content) {
+		trimmed := strings.TrimSpace(line)
+		if depth == 0 && strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if trimmed == "" && depth == 0 {
+			if len(current) != 0 {
+				blocks = append(blocks, giftQuestion{line: start, text: strings.Join(current, "\n")})
+				current = nil
+			}
+			continue
+		}
+		if len(current) == 0 {
+			start = i + 1
+		}
+		current = append(current, line)
+		depth += giftBraceDepth(line)
+	}
+	if len(current) != 0 {
+		blocks = append(blocks, giftQuestion{line: start, text: strings.Join(current, "\n")})
+	}
+
+	for _, block := range blocks {
+		if strings.HasPrefix(strings.TrimSpace(block.text), "$CATEGORY:") {
+			continue
+		}
+		question, warning, errs := parseGIFTQuestion(block)
+		if warning != "" {
+			result.Warnings = append(result.Warnings, ImportWarning{Row: block.line, Message: warning})
+		}
+		if question == nil {
+			if len(errs) != 0 {
+				result.Errors = append(result.Errors, ImportRowError{Row: block.line, Errors: errs})
+				result.Unparsed++
+			}
+			continue
+		}
+		if len(errs) == 0 {
+			errs = ValidateQuestion(question)
+		}
+		if len(errs) != 0 {
+			result.Errors = append(result.Errors, ImportRowError{Row: block.line, Errors: errs})
+		}
+		result.Questions = append(result.Questions, *question)
+	}
+
+	// skipped questions are still reported in the warnings
+	if result.Total() == 0 {
+		return result, ErrEmptyImport
+	}
+	return result, nil
+}
+
+// giftBraceDepth returns how much a line changes the answer brace nesting,
+// ignoring escaped braces.
+func giftBraceDepth(line string) int {
+	depth := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// giftIndex returns the position of the first unescaped occurrence of any
+// of the characters in chars, or -1.
+func giftIndex(text string, chars string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte(chars, text[i]) >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// giftLine returns the line number of offset within a block.
+func giftLine(block giftQuestion, offset int) int {
+	return block.line + strings.Count(block.text[:offset], "\n")
+}
+
+func giftUnescape(text string) string {
+	var builder strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			if text[i] == 'n' {
+				builder.WriteByte('\n')
+			} else {
+				builder.WriteByte(text[i])
+			}
+			continue
+		}
+		builder.WriteByte(text[i])
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+func parseGIFTQuestion(block giftQuestion) (*models.QuestionUnmarshal, string, []string) {
+	text := strings.TrimSpace(block.text)
+	offset := strings.Index(block.text, text)
+
+	title := ""
+	if match := giftTitle.FindStringSubmatch(text); match != nil {
+		title = giftUnescape(match[1])
+		text = text[len(match[0]):]
+		offset += len(match[0])
+	}
+	trimmed := strings.TrimLeft(text, " \t\n")
+	offset += len(text) - len(trimmed)
+	format := ""
+	if match := giftTextFormat.FindStringSubmatch(trimmed); match != nil && match[1] == "markdown" {
+		format = models.ContentFormatMarkdown
+	}
+	text = giftTextFormat.ReplaceAllString(trimmed, "")
+	offset += len(trimmed) - len(text)
+
+	open := giftIndex(text, "{")
+	if open < 0 {
+		return nil, "description items without answers are skipped", nil
+	}
+	closing := giftIndex(text[open+1:], "}")
+	if closing < 0 {
+		return nil, "", []string{fmt.Sprintf("line %d: answers are missing a closing }", giftLine(block, offset+open))}
+	}
+	closing += open + 1
+	answers := text[open+1 : closing]
+	answersLine := giftLine(block, offset+open)
+
+	before := giftUnescape(text[:open])
+	after := giftUnescape(text[closing+1:])
+	stem := before
+	if after != "" {
+		stem = strings.TrimSpace(before + " " + giftMissingBlank + " " + after)
+	}
+	if stem == "" {
+		stem = title
+	}
+
+	question := &models.QuestionUnmarshal{
+		QuestionName: stem,
+		Format:       format,
+		Type:         models.QuestionTypeSingleChoice,
+	}
+
+	if general := strings.Index(answers, "####"); general >= 0 {
+		question.Explanation = giftUnescape(answers[general+4:])
+		answers = answers[:general]
+	}
+	answersOffset := offset + open + 1 + len(answers) - len(strings.TrimLeft(answers, " \t\n"))
+	answers = strings.TrimSpace(answers)
+
+	switch {
+	case answers == "":
+		return nil, fmt.Sprintf("line %d: essay questions are not supported", answersLine), nil
+	case strings.HasPrefix(answers, "#"):
+		return nil, fmt.Sprintf("line %d: numerical questions are not supported", answersLine), nil
+	}
+
+	if answer, feedback := giftSplitRaw(answers); giftTrueFalse.MatchString(strings.TrimSpace(answer)) {
+		correct := strings.ToUpper(strings.TrimSpace(answer))[:1] == "T"
+		question.Options = []string{"True", "False"}
+		question.CorrectAnswer, question.Distractors = "False", []string{"True"}
+		if correct {
+			question.CorrectAnswer, question.Distractors = "True", []string{"False"}
+		}
+		// True/false feedback is the feedback for a wrong answer, then
+		// optionally a second # and the feedback for the right one.
+		wrongFeedback, rightFeedback := giftSplitFeedback(feedback)
+		for option, text := range map[string]string{question.Distractors[0]: wrongFeedback, question.CorrectAnswer: rightFeedback} {
+			if text = strings.TrimSpace(giftUnescape(text)); text != "" {
+				if question.OptionFeedback == nil {
+					question.OptionFeedback = make(map[string]string)
+				}
+				question.OptionFeedback[option] = text
+			}
+		}
+		return question, "", nil
+	}
+
+	type giftAnswer struct {
+		text     string
+		feedback string
+		correct  bool
+	}
+	parsed := make([]giftAnswer, 0)
+	errs := make([]string, 0)
+	for position := 0; position < len(answers); {
+		line := giftLine(block, answersOffset+position)
+		marker := answers[position]
+		if marker != '=' && marker != '~' {
+			return nil, "", []string{fmt.Sprintf("line %d: expected an answer starting with = or ~", line)}
+		}
+		end := len(answers)
+		if next := giftIndex(answers[position+1:], "=~"); next >= 0 {
+			end = position + 1 + next
+		}
+		raw := answers[position+1 : end]
+		position = end
+		for position < len(answers) && strings.IndexByte(" \t\n", answers[position]) >= 0 {
+			position++
+		}
+
+		answer := giftAnswer{correct: marker == '='}
+		raw = strings.TrimSpace(raw)
+		if match := giftWeight.FindStringSubmatch(raw); match != nil {
+			weight, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("line %d: answer weight %q is not a number", line, match[1]))
+			}
+			answer.correct = weight > 0
+			raw = raw[len(match[0]):]
+		}
+		if strings.Contains(raw, "->") {
+			return nil, fmt.Sprintf("line %d: matching questions are not supported", line), nil
+		}
+		text, feedback := giftSplitFeedback(raw)
+		answer.text, answer.feedback = giftUnescape(text), feedback
+		parsed = append(parsed, answer)
+	}
+
+	wrong := 0
+	for _, answer := range parsed {
+		if !answer.correct {
+			wrong++
+		}
+	}
+	if wrong == 0 {
+		return nil, fmt.Sprintf("line %d: short answer questions are not supported", answersLine), nil
+	}
+
+	for _, answer := range parsed {
+		question.Options = append(question.Options, answer.text)
+		if answer.correct {
+			question.CorrectAnswers = append(question.CorrectAnswers, answer.text)
+		} else {
+			question.Distractors = append(question.Distractors, answer.text)
+		}
+		if answer.feedback != "" {
+			if question.OptionFeedback == nil {
+				question.OptionFeedback = make(map[string]string)
+			}
+			question.OptionFeedback[answer.text] = answer.feedback
+		}
+	}
+	if len(question.CorrectAnswers) == 0 {
+		errs = append(errs, fmt.Sprintf("line %d: no answer is marked correct", answersLine))
+	}
+	if len(question.CorrectAnswers) == 1 {
+		question.CorrectAnswer = question.CorrectAnswers[0]
+		question.CorrectAnswers = nil
+	} else if len(question.CorrectAnswers) > 1 {
+		question.Type = models.QuestionTypeMultipleChoice
+	}
+	return question, "", errs
+}
+
+func giftSplitFeedback(raw string) (string, string) {
+	text, feedback := giftSplitRaw(raw)
+	return text, giftUnescape(feedback)
+}
+
+// giftSplitRaw splits raw at its first unescaped # and leaves both halves
+// escaped.
+func giftSplitRaw(raw string) (string, string) {
+	hash := giftIndex(raw, "#")
+	if hash < 0 {
+		return raw, ""
+	}
+	return raw[:hash], raw[hash+1:]
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"github.com/zeekhoks/quiz-backend/models"
+	"math"
+)
+
+// ItemResponse is a dichotomously scored answer to a calibrated question.
+type ItemResponse struct {
+	Parameters models.IRTParameters
+	Correct    bool
+}
+
+const (
+	thetaMin  = -4.0
+	thetaMax  = 4.0
+	thetaStep = 0.05
+)
+
+// itemParameters returns the parameters to use for a question under the
+// given model. The Rasch model fixes every discrimination at one.
+func itemParameters(model string, parameters models.IRTParameters) models.IRTParameters {
+	if model == models.IRTModelRasch || parameters.Discrimination <= 0 {
+		parameters.Discrimination = 1
+	}
+	return parameters
+}
+
+// ProbabilityCorrect is the two-parameter logistic item response function.
+func ProbabilityCorrect(theta float64, parameters models.IRTParameters) float64 {
+	return 1 / (1 + math.Exp(-parameters.Discrimination*(theta-parameters.Difficulty)))
+}
+
+// ItemInformation is the Fisher information an item provides at theta.
+func ItemInformation(theta float64, parameters models.IRTParameters) float64 {
+	p := ProbabilityCorrect(theta, parameters)
+	return parameters.Discrimination * parameters.Discrimination * p * (1 - p)
+}
+
+// EstimateAbility computes the expected a posteriori ability estimate with a
+// standard normal prior. Unlike maximum likelihood it stays finite when every
+// answer so far is right or wrong. The standard error is the posterior
+// standard deviation.
+func EstimateAbility(responses []ItemResponse) models.AbilityEstimate {
+	var weightSum, thetaSum, thetaSquaredSum float64
+
+	for theta := thetaMin; theta <= thetaMax+thetaStep/2; theta += thetaStep {
+		logLikelihood := -theta * theta / 2
+		for _, response := range responses {
+			p := ProbabilityCorrect(theta, response.Parameters)
+			if response.Correct {
+				logLikelihood += math.Log(p)
+			} else {
+				logLikelihood += math.Log(1 - p)
+			}
+		}
+		weight := math.Exp(logLikelihood)
+		weightSum += weight
+		thetaSum += weight * theta
+		thetaSquaredSum += weight * theta * theta
+	}
+
+	mean := thetaSum / weightSum
+	variance := thetaSquaredSum/weightSum - mean*mean
+	return models.AbilityEstimate{
+		Theta:         mean,
+		StandardError: math.Sqrt(math.Max(variance, 0)),
+	}
+}
+
+// SelectNextItem returns the index of the candidate with the most information
+// at the current ability estimate, or -1 when there are no candidates.
+func SelectNextItem(theta float64, model string, candidates []models.Question) int {
+	best, bestInformation := -1, -1.0
+	for i, candidate := range candidates {
+		if candidate.IRT == nil {
+			continue
+		}
+		information := ItemInformation(theta, itemParameters(model, *candidate.IRT))
+		if information > bestInformation {
+			best, bestInformation = i, information
+		}
+	}
+	return best
+}
+
+// ShouldStopAdaptive applies the stopping rule of an adaptive quiz after the
+// given number of answered questions.
+func ShouldStopAdaptive(config models.AdaptiveConfig, answered int, estimate models.AbilityEstimate) bool {
+	if config.MaxQuestions > 0 && answered >= config.MaxQuestions {
+		return true
+	}
+	if answered < config.MinQuestions {
+		return false
+	}
+	return config.TargetSE > 0 && estimate.StandardError <= config.TargetSE
+}
+
+// AdaptiveResponses pairs the graded answers of a quiz with the parameters
+// of the questions they belong to.
+func AdaptiveResponses(quiz models.Quiz) []ItemResponse {
+	model := models.IRTModel2PL
+	if quiz.Adaptive != nil {
+		model = quiz.Adaptive.Model
+	}
+
+	parameters := make(map[string]models.IRTParameters)
+	for _, question := range quiz.Questions {
+		if question.IRT != nil {
+			parameters[question.ID.Hex()] = itemParameters(model, *question.IRT)
+		}
+	}
+
+	responses := make([]ItemResponse, 0, len(quiz.UserResponses))
+	for _, response := range quiz.UserResponses {
+		params, ok := parameters[response.QuestionId.Hex()]
+		if !ok {
+			continue
+		}
+		responses = append(responses, ItemResponse{
+			Parameters: params,
+			Correct:    response.Result == ResultRight,
+		})
+	}
+	return responses
+}
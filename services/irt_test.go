@@ -0,0 +1,61 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+)
+
+func TestProbabilityCorrect(t *testing.T) {
+	item := models.IRTParameters{Discrimination: 1.5, Difficulty: 0.5}
+	assert.InDelta(t, 0.5, ProbabilityCorrect(0.5, item), 1e-9)
+	assert.Greater(t, ProbabilityCorrect(2, item), ProbabilityCorrect(0, item))
+	assert.InDelta(t, 1.5*1.5*0.25, ItemInformation(0.5, item), 1e-9)
+}
+
+func TestEstimateAbility(t *testing.T) {
+	// Test case: no responses returns the standard normal prior
+	prior := EstimateAbility(nil)
+	assert.InDelta(t, 0, prior.Theta, 1e-6)
+	assert.InDelta(t, 1, prior.StandardError, 0.01)
+
+	items := []models.IRTParameters{
+		{Discrimination: 1, Difficulty: -1},
+		{Discrimination: 1, Difficulty: 0},
+		{Discrimination: 1, Difficulty: 1},
+	}
+
+	allRight := make([]ItemResponse, 0)
+	allWrong := make([]ItemResponse, 0)
+	for _, item := range items {
+		allRight = append(allRight, ItemResponse{Parameters: item, Correct: true})
+		allWrong = append(allWrong, ItemResponse{Parameters: item, Correct: false})
+	}
+
+	high := EstimateAbility(allRight)
+	low := EstimateAbility(allWrong)
+	assert.Greater(t, high.Theta, 0.5)
+	assert.Less(t, low.Theta, -0.5)
+	assert.InDelta(t, high.Theta, -low.Theta, 1e-6)
+	assert.Less(t, high.StandardError, prior.StandardError)
+}
+
+func TestSelectNextItem(t *testing.T) {
+	candidates := []models.Question{
+		{IRT: &models.IRTParameters{Discrimination: 1, Difficulty: -2}},
+		{},
+		{IRT: &models.IRTParameters{Discrimination: 1, Difficulty: 1.1}},
+		{IRT: &models.IRTParameters{Discrimination: 1, Difficulty: 3}},
+	}
+	assert.Equal(t, 2, SelectNextItem(1, models.IRTModelRasch, candidates))
+	assert.Equal(t, 0, SelectNextItem(-2, models.IRTModelRasch, candidates))
+	assert.Equal(t, -1, SelectNextItem(0, models.IRTModelRasch, candidates[1:2]))
+}
+
+func TestShouldStopAdaptive(t *testing.T) {
+	config := models.AdaptiveConfig{TargetSE: 0.4, MinQuestions: 3, MaxQuestions: 10}
+	assert.False(t, ShouldStopAdaptive(config, 2, models.AbilityEstimate{StandardError: 0.2}))
+	assert.True(t, ShouldStopAdaptive(config, 3, models.AbilityEstimate{StandardError: 0.3}))
+	assert.False(t, ShouldStopAdaptive(config, 5, models.AbilityEstimate{StandardError: 0.5}))
+	assert.True(t, ShouldStopAdaptive(config, 10, models.AbilityEstimate{StandardError: 0.5}))
+}
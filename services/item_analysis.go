@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	itemAnalysisMinExposures      = 20
+	itemAnalysisEasyThreshold     = 0.9
+	itemAnalysisHardThreshold     = 0.2
+	itemAnalysisLowDiscrimination = 0.2
+	itemAnalysisMinOptionRate     = 0.05
+)
+
+type itemAccumulator struct {
+	question  models.Question
+	exposures int
+	responses int
+
+	// choices of the latest revision counted by option position, which is
+	// the same in every language a question is shown in. An edit may change
+	// the options, so earlier revisions only count toward the item score.
+	options         map[int]int
+	optionResponses int
+
+	// running sums for the correlation between the item score (x) and the
+	// rest score of the attempt (y)
+	sumX, sumY, sumXX, sumYY, sumXY float64
+}
+
+// ItemAnalyzer accumulates per-question statistics over completed quizzes
+// one attempt at a time, so the whole collection never has to be in memory.
+type ItemAnalyzer struct {
+	items map[primitive.ObjectID]*itemAccumulator
+}
+
+func NewItemAnalyzer() *ItemAnalyzer {
+	return &ItemAnalyzer{items: make(map[primitive.ObjectID]*itemAccumulator)}
+}
+
+func itemScore(response models.UserResponse) float64 {
+	switch response.Result {
+	case ResultRight:
+		return 1
+	case ResultPartial:
+		return response.Credit
+	default:
+		return 0
+	}
+}
+
+func (analyzer *ItemAnalyzer) Add(quiz models.Quiz) {
+	responses := make(map[primitive.ObjectID]models.UserResponse)
+	total := 0.0
+	for _, response := range quiz.UserResponses {
+		responses[response.QuestionId] = response
+		total += itemScore(response)
+	}
+
+	for _, question := range quiz.Questions {
+		if question.Topic == "" {
+			question.Topic = quiz.Topic
+		}
+		item, ok := analyzer.items[question.ID]
+		switch {
+		case !ok:
+			item = &itemAccumulator{question: question, options: make(map[int]int)}
+			analyzer.items[question.ID] = item
+		case question.Revision > item.question.Revision:
+			item.question = question
+			item.options = make(map[int]int)
+			item.optionResponses = 0
+		case question.Revision == item.question.Revision && item.question.Locale != "" && question.Locale == "":
+			// report options in the original language once a snapshot has it
+			item.question = question
+		}
+		item.exposures++
+
+		x := 0.0
+		if response, answered := responses[question.ID]; answered {
+			item.responses++
+			x = itemScore(response)
+			choices := response.Responses
+			if len(choices) == 0 {
+				choices = []string{response.Response}
+			}
+			// every quiz draws its own options for a template question, so
+			// they can't be counted together
+			if question.Template == nil && question.Revision == item.question.Revision {
+				item.optionResponses++
+				for _, choice := range choices {
+					if position := optionPosition(question.Options, choice); position >= 0 {
+						item.options[position]++
+					}
+				}
+			}
+		}
+
+		y := total - x
+		item.sumX += x
+		item.sumY += y
+		item.sumXX += x * x
+		item.sumYY += y * y
+		item.sumXY += x * y
+	}
+}
+
+// Results returns the statistics of every question seen, ordered by topic
+// and question text.
+func (analyzer *ItemAnalyzer) Results(now time.Time) []models.ItemStatistics {
+	results := make([]models.ItemStatistics, 0, len(analyzer.items))
+	for id, item := range analyzer.items {
+		stats := models.ItemStatistics{
+			QuestionId:               id,
+			Topic:                    item.question.Topic,
+			Question:                 item.question.QuestionName,
+			Revision:                 item.question.Revision,
+			Exposures:                item.exposures,
+			Responses:                item.responses,
+			Omissions:                item.exposures - item.responses,
+			OptionRates:              make(map[string]float64),
+			NonFunctionalDistractors: make([]string, 0),
+			Flags:                    make([]string, 0),
+			ComputedAt:               now,
+		}
+
+		n := float64(item.exposures)
+		stats.PValue = item.sumX / n
+		covariance := item.sumXY/n - (item.sumX/n)*(item.sumY/n)
+		varianceX := item.sumXX/n - (item.sumX/n)*(item.sumX/n)
+		varianceY := item.sumYY/n - (item.sumY/n)*(item.sumY/n)
+		if varianceX > 0 && varianceY > 0 {
+			stats.PointBiserial = covariance / math.Sqrt(varianceX*varianceY)
+		}
+
+		if item.question.Template == nil {
+			analyzeOptions(item, &stats)
+		}
+
+		stats.Flags = itemFlags(stats)
+		results = append(results, stats)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Topic != results[j].Topic {
+			return results[i].Topic < results[j].Topic
+		}
+		return results[i].Question < results[j].Question
+	})
+	return results
+}
+
+// analyzeOptions reports how often each option was chosen and which
+// distractors hardly anyone picks.
+func analyzeOptions(item *itemAccumulator, stats *models.ItemStatistics) {
+	correct := make(map[string]bool)
+	correct[strings.ToLower(item.question.CorrectAnswer)] = true
+	for _, answer := range item.question.CorrectAnswers {
+		correct[strings.ToLower(answer)] = true
+	}
+	for i, option := range item.question.Options {
+		rate := 0.0
+		if item.optionResponses > 0 {
+			rate = float64(item.options[i]) / float64(item.optionResponses)
+		}
+		stats.OptionRates[option] = rate
+		if !correct[strings.ToLower(option)] && rate < itemAnalysisMinOptionRate {
+			stats.NonFunctionalDistractors = append(stats.NonFunctionalDistractors, option)
+		}
+	}
+}
+
+// optionPosition finds a recorded choice among the options of a snapshot,
+// ignoring case and surrounding space like grading does.
+func optionPosition(options []string, choice string) int {
+	choice = strings.TrimSpace(choice)
+	for i, option := range options {
+		if strings.EqualFold(strings.TrimSpace(option), choice) {
+			return i
+		}
+	}
+	return -1
+}
+
+func itemFlags(stats models.ItemStatistics) []string {
+	flags := make([]string, 0)
+	if stats.Exposures < itemAnalysisMinExposures {
+		return append(flags, models.ItemFlagInsufficientData)
+	}
+	if stats.PValue > itemAnalysisEasyThreshold {
+		flags = append(flags, models.ItemFlagTooEasy)
+	}
+	if stats.PValue < itemAnalysisHardThreshold {
+		flags = append(flags, models.ItemFlagTooHard)
+	}
+	if stats.PointBiserial < 0 {
+		flags = append(flags, models.ItemFlagNegativeDiscrimination)
+	} else if stats.PointBiserial < itemAnalysisLowDiscrimination {
+		flags = append(flags, models.ItemFlagLowDiscrimination)
+	}
+	if len(stats.NonFunctionalDistractors) != 0 {
+		flags = append(flags, models.ItemFlagNonFunctionalDistractor)
+	}
+	return flags
+}
+
+// RunItemAnalysis recomputes the statistics of every question from completed
+// quizzes and replaces the stored figures.
+func RunItemAnalysis(ctx context.Context) ([]models.ItemStatistics, error) {
+	client := GetConnection()
+	quizCollection := GetCollection(client, "quizzes")
+	statsCollection := GetCollection(client, "item_statistics")
+
+	cursor, err := quizCollection.Find(ctx, bson.M{"completed": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	analyzer := NewItemAnalyzer()
+	for cursor.Next(ctx) {
+		var quiz models.Quiz
+		if err = cursor.Decode(&quiz); err != nil {
+			return nil, err
+		}
+		analyzer.Add(quiz)
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	results := analyzer.Results(time.Now())
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(results))
+	for _, stats := range results {
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"question_id": stats.QuestionId}).
+			SetReplacement(stats).
+			SetUpsert(true))
+	}
+	if _, err = statsCollection.BulkWrite(ctx, writes); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func GetItemStatistics(ctx context.Context, topic string, flaggedOnly bool) ([]models.ItemStatistics, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "item_statistics")
+
+	filter := bson.M{}
+	if topic != "" {
+		filter["topic"] = topic
+	}
+	if flaggedOnly {
+		filter["flags.0"] = bson.M{"$exists": true}
+		filter["flags"] = bson.M{"$ne": models.ItemFlagInsufficientData}
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "topic", Value: 1}, {Key: "question", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]models.ItemStatistics, 0)
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// StartItemAnalysisJob recomputes item statistics in the background at the
+// given interval.
+func StartItemAnalysisJob(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			results, err := RunItemAnalysis(context.Background())
+			if err != nil {
+				log.Println("Item analysis failed", err)
+				continue
+			}
+			log.Println("Item analysis updated statistics for", len(results), "questions")
+		}
+	}()
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestItemAnalyzer(t *testing.T) {
+	easy := models.Question{
+		ID:            primitive.NewObjectID(),
+		Topic:         "india",
+		QuestionName:  "What is the capital of India?",
+		Options:       []string{"Mumbai", "Kolkata", "New Delhi", "Bangalore"},
+		CorrectAnswer: "New Delhi",
+	}
+	discriminating := models.Question{
+		ID:            primitive.NewObjectID(),
+		Topic:         "india",
+		QuestionName:  "Which river is considered the holiest in India?",
+		Options:       []string{"Yamuna", "Godavari", "Ganga", "Narmada"},
+		CorrectAnswer: "Ganga",
+	}
+	filler := models.Question{ID: primitive.NewObjectID(), Topic: "india", Options: []string{"a", "b"}, CorrectAnswer: "a"}
+
+	analyzer := NewItemAnalyzer()
+	for i := 0; i < 40; i++ {
+		strong := i%2 == 0
+		quiz := models.Quiz{Topic: "india", Questions: []models.Question{easy, discriminating, filler}}
+
+		quiz.UserResponses = append(quiz.UserResponses, models.UserResponse{QuestionId: easy.ID, Response: "new delhi", Result: ResultRight})
+		if strong {
+			quiz.UserResponses = append(quiz.UserResponses,
+				models.UserResponse{QuestionId: discriminating.ID, Response: "ganga", Result: ResultRight},
+				models.UserResponse{QuestionId: filler.ID, Response: "a", Result: ResultRight},
+			)
+		} else {
+			quiz.UserResponses = append(quiz.UserResponses,
+				models.UserResponse{QuestionId: discriminating.ID, Response: "yamuna", Result: ResultWrong},
+			)
+		}
+		analyzer.Add(quiz)
+	}
+
+	results := analyzer.Results(time.Now())
+	assert.Len(t, results, 3)
+
+	byId := make(map[primitive.ObjectID]models.ItemStatistics)
+	for _, stats := range results {
+		byId[stats.QuestionId] = stats
+	}
+
+	easyStats := byId[easy.ID]
+	assert.Equal(t, 40, easyStats.Exposures)
+	assert.Equal(t, 1.0, easyStats.PValue)
+	assert.Equal(t, 0.0, easyStats.PointBiserial)
+	assert.Contains(t, easyStats.Flags, models.ItemFlagTooEasy)
+	assert.ElementsMatch(t, []string{"Mumbai", "Kolkata", "Bangalore"}, easyStats.NonFunctionalDistractors)
+
+	hardStats := byId[discriminating.ID]
+	assert.Equal(t, 0.5, hardStats.PValue)
+	assert.InDelta(t, 1.0, hardStats.PointBiserial, 1e-9)
+	assert.Equal(t, 0.5, hardStats.OptionRates["Yamuna"])
+	assert.NotContains(t, hardStats.Flags, models.ItemFlagLowDiscrimination)
+
+	fillerStats := byId[filler.ID]
+	assert.Equal(t, 20, fillerStats.Omissions)
+}
+
+func TestItemAnalyzerTemplates(t *testing.T) {
+	template := &models.QuestionTemplate{
+		Variables:   []models.TemplateVariable{{Name: "a", Min: 1, Max: 9}},
+		Answer:      "a * 2",
+		Distractors: []string{"a + 2", "a * 3"},
+	}
+	id := primitive.NewObjectID()
+
+	// Test case: Every quiz has its own options, so distractors aren't judged
+	analyzer := NewItemAnalyzer()
+	for i := 1; i <= 30; i++ {
+		answer := strconv.Itoa(i * 2)
+		question := models.Question{
+			ID:            id,
+			QuestionName:  "What is " + strconv.Itoa(i) + " times 2?",
+			Options:       []string{answer, strconv.Itoa(i + 2), strconv.Itoa(i * 3)},
+			CorrectAnswer: answer,
+			Template:      template,
+		}
+		response := models.UserResponse{QuestionId: id, Response: strconv.Itoa(i + 2), Result: ResultWrong}
+		if i%3 != 0 {
+			response = models.UserResponse{QuestionId: id, Response: answer, Result: ResultRight}
+		}
+		analyzer.Add(models.Quiz{Topic: "arithmetic", Questions: []models.Question{question}, UserResponses: []models.UserResponse{response}})
+	}
+
+	results := analyzer.Results(time.Now())
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, 30, results[0].Exposures)
+		assert.InDelta(t, 2.0/3, results[0].PValue, 1e-9)
+		assert.Empty(t, results[0].OptionRates)
+		assert.Empty(t, results[0].NonFunctionalDistractors)
+		assert.NotContains(t, results[0].Flags, models.ItemFlagNonFunctionalDistractor)
+	}
+}
+
+func TestItemAnalyzerTranslations(t *testing.T) {
+	question := models.Question{
+		ID:            primitive.NewObjectID(),
+		Topic:         "india",
+		QuestionName:  "Which river is considered the holiest in India?",
+		Options:       []string{"Yamuna", "Ganga", "Narmada"},
+		CorrectAnswer: "Ganga",
+	}
+	translated := question
+	translated.QuestionName = "भारत में सबसे पवित्र नदी कौन सी मानी जाती है?"
+	translated.Options = []string{"यमुना", "गंगा", "नर्मदा"}
+	translated.CorrectAnswer = "गंगा"
+	translated.Locale = "hi"
+
+	// Test case: Responses in a translation count for the original option
+	analyzer := NewItemAnalyzer()
+	for i := 0; i < 20; i++ {
+		snapshot, answer, distractor := question, "ganga", "yamuna"
+		if i%2 == 0 {
+			snapshot, answer, distractor = translated, "गंगा", "यमुना"
+		}
+		response := models.UserResponse{QuestionId: question.ID, Response: answer, Result: ResultRight}
+		if i%4 < 2 {
+			response = models.UserResponse{QuestionId: question.ID, Response: distractor, Result: ResultWrong}
+		}
+		analyzer.Add(models.Quiz{Topic: "india", Questions: []models.Question{snapshot}, UserResponses: []models.UserResponse{response}})
+	}
+
+	results := analyzer.Results(time.Now())
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, question.QuestionName, results[0].Question)
+		assert.Equal(t, map[string]float64{"Yamuna": 0.5, "Ganga": 0.5, "Narmada": 0}, results[0].OptionRates)
+		assert.Equal(t, []string{"Narmada"}, results[0].NonFunctionalDistractors)
+	}
+}
+
+func TestItemAnalyzerRevisions(t *testing.T) {
+	original := models.Question{
+		ID:            primitive.NewObjectID(),
+		Topic:         "india",
+		QuestionName:  "Which river is considered the holiest in India?",
+		Options:       []string{"Yamuna", "Ganga", "Narmada"},
+		CorrectAnswer: "Ganga",
+		Revision:      1,
+	}
+	edited := original
+	edited.Options = []string{"Ganga", "Godavari", "Kaveri"}
+	edited.Revision = 2
+
+	// Test case: Option rates come from the latest revision only, whatever
+	// order the quizzes are read in
+	analyzer := NewItemAnalyzer()
+	for i := 0; i < 20; i++ {
+		snapshot, response := edited, models.UserResponse{QuestionId: original.ID, Response: "ganga", Result: ResultRight}
+		if i%2 == 0 {
+			snapshot, response = original, models.UserResponse{QuestionId: original.ID, Response: "yamuna", Result: ResultWrong}
+		}
+		analyzer.Add(models.Quiz{Topic: "india", Questions: []models.Question{snapshot}, UserResponses: []models.UserResponse{response}})
+	}
+
+	results := analyzer.Results(time.Now())
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, 20, results[0].Exposures)
+		assert.Equal(t, 2, results[0].Revision)
+		assert.Equal(t, map[string]float64{"Ganga": 1, "Godavari": 0, "Kaveri": 0}, results[0].OptionRates)
+		assert.Equal(t, []string{"Godavari", "Kaveri"}, results[0].NonFunctionalDistractors)
+	}
+}
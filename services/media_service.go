@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMediaMaxBytes = 10 << 20
+
+	// MediaURLLifetime is how long a signed media URL stays valid at least.
+	// Expiry is rounded so the same URL is handed out for a while, which
+	// lets browsers cache the content.
+	MediaURLLifetime = 24 * time.Hour
+)
+
+// mediaContentTypes maps sniffed content types onto the types media is
+// stored and served with. Anything not listed is rejected; SVG in particular
+// can carry scripts.
+var mediaContentTypes = map[string]string{
+	"image/png":       "image/png",
+	"image/jpeg":      "image/jpeg",
+	"image/gif":       "image/gif",
+	"image/webp":      "image/webp",
+	"audio/mpeg":      "audio/mpeg",
+	"application/ogg": "audio/ogg",
+	"audio/wave":      "audio/wav",
+	"video/mp4":       "video/mp4",
+}
+
+// MediaError is a rejected upload with the status to answer it with.
+type MediaError struct {
+	Message string
+	Status  int
+}
+
+func (err *MediaError) Error() string {
+	return err.Message
+}
+
+func mediaMaxBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MEDIA_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return DefaultMediaMaxBytes
+}
+
+// DetectMediaType returns the content type media is stored with, based on
+// its content rather than what the client claims.
+func DetectMediaType(content []byte) (string, bool) {
+	sniffed := strings.TrimSpace(strings.Split(http.DetectContentType(content), ";")[0])
+	contentType, ok := mediaContentTypes[sniffed]
+	return contentType, ok
+}
+
+// SaveMedia validates an upload, stores its content and records it.
+func SaveMedia(ctx context.Context, filename string, content io.Reader, uploadedBy string) (models.Media, error) {
+	maxBytes := mediaMaxBytes()
+	data, err := io.ReadAll(io.LimitReader(content, maxBytes+1))
+	if err != nil {
+		return models.Media{}, err
+	}
+	if len(data) == 0 {
+		return models.Media{}, &MediaError{Message: "File is empty", Status: http.StatusBadRequest}
+	}
+	if int64(len(data)) > maxBytes {
+		return models.Media{}, &MediaError{
+			Message: fmt.Sprintf("File is larger than %d bytes", maxBytes),
+			Status:  http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	contentType, ok := DetectMediaType(data)
+	if !ok {
+		return models.Media{}, &MediaError{
+			Message: "Unsupported file type. Upload a PNG, JPEG, GIF or WebP image, MP3, OGG or WAV audio, or MP4 video",
+			Status:  http.StatusUnsupportedMediaType,
+		}
+	}
+
+	checksum := sha256.Sum256(data)
+	media := models.Media{
+		ID:          primitive.NewObjectID(),
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Checksum:    hex.EncodeToString(checksum[:]),
+		Storage:     DefaultMediaStorage(),
+		UploadedBy:  uploadedBy,
+		UploadedAt:  time.Now(),
+	}
+
+	storage, ok := GetMediaStorage(media.Storage)
+	if !ok {
+		return models.Media{}, fmt.Errorf("unknown media storage %q", media.Storage)
+	}
+	if err = storage.Save(ctx, media.ID.Hex(), bytes.NewReader(data)); err != nil {
+		return models.Media{}, err
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "media")
+	if _, err = collection.InsertOne(ctx, media); err != nil {
+		storage.Delete(ctx, media.ID.Hex())
+		return models.Media{}, err
+	}
+	return media, nil
+}
+
+func GetMediaById(ctx context.Context, id primitive.ObjectID) (models.Media, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "media")
+	var media models.Media
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&media)
+	return media, err
+}
+
+// OpenMedia returns the stored content of media.
+func OpenMedia(ctx context.Context, media models.Media) (io.ReadCloser, error) {
+	storage, ok := GetMediaStorage(media.Storage)
+	if !ok {
+		return nil, fmt.Errorf("unknown media storage %q", media.Storage)
+	}
+	return storage.Open(ctx, media.ID.Hex())
+}
+
+// MissingMedia returns the referenced media IDs that were never uploaded.
+func MissingMedia(ctx context.Context, questions []models.QuestionUnmarshal) ([]string, error) {
+	ids := make([]primitive.ObjectID, 0)
+	for _, question := range questions {
+		for _, reference := range question.Media {
+			ids = append(ids, reference.MediaId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "media")
+	found, err := collection.Distinct(ctx, "_id", bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[primitive.ObjectID]bool)
+	for _, id := range found {
+		if objectId, ok := id.(primitive.ObjectID); ok {
+			existing[objectId] = true
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if !existing[id] {
+			missing = append(missing, id.Hex())
+			existing[id] = true
+		}
+	}
+	return missing, nil
+}
+
+// MediaInUse reports whether a question, or the snapshot of a question in a
+// past quiz, still references the media.
+func MediaInUse(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	client := GetConnection()
+	count, err := GetCollection(client, "questions").CountDocuments(ctx, bson.M{"media.media_id": id})
+	if err != nil || count != 0 {
+		return count != 0, err
+	}
+	count, err = GetCollection(client, "quizzes").CountDocuments(ctx, bson.M{"questions.media.media_id": id})
+	return count != 0, err
+}
+
+func DeleteMedia(ctx context.Context, media models.Media) error {
+	storage, ok := GetMediaStorage(media.Storage)
+	if !ok {
+		return fmt.Errorf("unknown media storage %q", media.Storage)
+	}
+	if err := storage.Delete(ctx, media.ID.Hex()); err != nil {
+		return err
+	}
+	client := GetConnection()
+	collection := GetCollection(client, "media")
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": media.ID})
+	return err
+}
+
+func mediaSignature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SIGNING_KEY")))
+	mac.Write([]byte(id + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// MediaURLExpiry rounds the expiry of URLs signed at now up to the end of
+// the next lifetime window.
+func MediaURLExpiry(now time.Time) time.Time {
+	window := int64(MediaURLLifetime / time.Second)
+	return time.Unix((now.Unix()/window+2)*window, 0)
+}
+
+// SignMediaURL returns a URL that serves the media without an Authorization
+// header until it expires.
+func SignMediaURL(id primitive.ObjectID, now time.Time) string {
+	expires := MediaURLExpiry(now).Unix()
+	return fmt.Sprintf("/api/media/%s?expires=%d&signature=%s", id.Hex(), expires, mediaSignature(id.Hex(), expires))
+}
+
+// VerifyMediaSignature checks the expires and signature query values of a
+// signed media URL.
+func VerifyMediaSignature(id, expires, signature string, now time.Time) bool {
+	if expires == "" || signature == "" {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(mediaSignature(id, expiresAt)))
+}
+
+// SignMediaReferences fills in the URLs of media references before they are
+// sent to a client.
+func SignMediaReferences(references []models.MediaReference, now time.Time) {
+	for i := range references {
+		references[i].URL = SignMediaURL(references[i].MediaId, now)
+	}
+}
+
+// SignQuestionMedia signs the media URLs of every question in a quiz or
+// question list.
+func SignQuestionMedia(questions []models.Question, now time.Time) {
+	for i := range questions {
+		SignMediaReferences(questions[i].Media, now)
+	}
+}
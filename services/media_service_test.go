@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectMediaType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	contentType, ok := DetectMediaType(png)
+	assert.True(t, ok)
+	assert.Equal(t, "image/png", contentType)
+
+	// Test case: the content decides the type, and scripts are rejected
+	_, ok = DetectMediaType([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"><script>alert(1)</script></svg>"))
+	assert.False(t, ok)
+	_, ok = DetectMediaType([]byte("<html><body>hello</body></html>"))
+	assert.False(t, ok)
+}
+
+func TestSignMediaURL(t *testing.T) {
+	t.Setenv("SIGNING_KEY", "test-key")
+	id := primitive.NewObjectID()
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	signed, err := url.Parse(SignMediaURL(id, now))
+	assert.NoError(t, err)
+	assert.Equal(t, "/api/media/"+id.Hex(), signed.Path)
+	expires, signature := signed.Query().Get("expires"), signed.Query().Get("signature")
+	assert.True(t, VerifyMediaSignature(id.Hex(), expires, signature, now))
+
+	// Test case: URLs stay the same within a window so they can be cached
+	assert.Equal(t, SignMediaURL(id, now), SignMediaURL(id, now.Add(time.Hour)))
+	assert.True(t, MediaURLExpiry(now).Sub(now) >= MediaURLLifetime)
+
+	// Test case: expired, tampered or foreign signatures are rejected
+	assert.False(t, VerifyMediaSignature(id.Hex(), expires, signature, now.Add(3*MediaURLLifetime)))
+	assert.False(t, VerifyMediaSignature(primitive.NewObjectID().Hex(), expires, signature, now))
+	assert.False(t, VerifyMediaSignature(id.Hex(), expires+"0", signature, now))
+	assert.False(t, VerifyMediaSignature(id.Hex(), "", "", now))
+}
+
+func TestLocalMediaStorage(t *testing.T) {
+	storage := LocalMediaStorage{Root: t.TempDir()}
+	ctx := context.Background()
+
+	assert.NoError(t, storage.Save(ctx, "abc", bytes.NewReader([]byte("content"))))
+	reader, err := storage.Open(ctx, "abc")
+	assert.NoError(t, err)
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "content", string(content))
+
+	assert.NoError(t, storage.Delete(ctx, "abc"))
+	_, err = storage.Open(ctx, "abc")
+	assert.Equal(t, ErrMediaNotStored, err)
+
+	// Test case: keys can't escape the storage root
+	assert.Error(t, storage.Save(ctx, "../abc", strings.NewReader("content")))
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	MediaStorageGridFS = "gridfs"
+	MediaStorageLocal  = "local"
+)
+
+// ErrMediaNotStored is returned when a storage backend has no content for a
+// key.
+var ErrMediaNotStored = errors.New("media content not found")
+
+// MediaStorage keeps the content of uploaded media. Keys are media IDs.
+type MediaStorage interface {
+	Save(ctx context.Context, key string, content io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalMediaStorage keeps media as files under Root.
+type LocalMediaStorage struct {
+	Root string
+}
+
+func (storage LocalMediaStorage) path(key string) (string, error) {
+	if key == "" || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid media key %q", key)
+	}
+	return filepath.Join(storage.Root, key), nil
+}
+
+func (storage LocalMediaStorage) Save(ctx context.Context, key string, content io.Reader) error {
+	path, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(storage.Root, 0o750); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see partial content
+	file, err := os.CreateTemp(storage.Root, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (storage LocalMediaStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := storage.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMediaNotStored
+	}
+	return file, err
+}
+
+func (storage LocalMediaStorage) Delete(ctx context.Context, key string) error {
+	path, err := storage.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GridFSMediaStorage keeps media in a GridFS bucket of the application
+// database.
+type GridFSMediaStorage struct {
+	Bucket string
+}
+
+func (storage GridFSMediaStorage) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(GetDatabase(GetConnection()), options.GridFSBucket().SetName(storage.Bucket))
+}
+
+func (storage GridFSMediaStorage) fileId(key string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(key)
+	if err != nil {
+		return id, fmt.Errorf("invalid media key %q", key)
+	}
+	return id, nil
+}
+
+func (storage GridFSMediaStorage) Save(ctx context.Context, key string, content io.Reader) error {
+	id, err := storage.fileId(key)
+	if err != nil {
+		return err
+	}
+	bucket, err := storage.bucket()
+	if err != nil {
+		return err
+	}
+	return bucket.UploadFromStreamWithID(id, key, content)
+}
+
+func (storage GridFSMediaStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	id, err := storage.fileId(key)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := storage.bucket()
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStream(id)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrMediaNotStored
+	}
+	return stream, err
+}
+
+func (storage GridFSMediaStorage) Delete(ctx context.Context, key string) error {
+	id, err := storage.fileId(key)
+	if err != nil {
+		return err
+	}
+	bucket, err := storage.bucket()
+	if err != nil {
+		return err
+	}
+	if err = bucket.Delete(id); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+var (
+	mediaStorages     map[string]MediaStorage
+	mediaStoragesOnce sync.Once
+)
+
+// GetMediaStorage returns the backend registered under name. Media keeps the
+// name of the backend it was saved to, so changing MEDIA_STORAGE does not
+// strand existing uploads.
+func GetMediaStorage(name string) (MediaStorage, bool) {
+	mediaStoragesOnce.Do(func() {
+		root := os.Getenv("MEDIA_DIR")
+		if root == "" {
+			root = "media"
+		}
+		mediaStorages = map[string]MediaStorage{
+			MediaStorageGridFS: GridFSMediaStorage{Bucket: "media"},
+			MediaStorageLocal:  LocalMediaStorage{Root: root},
+		}
+	})
+	storage, ok := mediaStorages[name]
+	return storage, ok
+}
+
+// DefaultMediaStorage is the backend new uploads go to, chosen with the
+// MEDIA_STORAGE environment variable.
+func DefaultMediaStorage() string {
+	if name := os.Getenv("MEDIA_STORAGE"); name != "" {
+		return name
+	}
+	return MediaStorageGridFS
+}
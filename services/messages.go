@@ -0,0 +1,162 @@
+package services
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/text/language"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Message catalogs map the English API messages to another language, one
+// file per locale. Messages built with fmt verbs are matched by pattern and
+// their values are carried over into the translation, which may reorder
+// them with explicit indexes such as %[2]d.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+type messageCatalog struct {
+	messages map[string]string
+	patterns []messagePattern
+}
+
+type messagePattern struct {
+	match       *regexp.Regexp
+	translation string
+}
+
+var (
+	messageVerb     = regexp.MustCompile(`%(\[\d+\])?[dqsv]`)
+	messageCatalogs = loadMessageCatalogs()
+	messageLocales  = catalogLocales(messageCatalogs)
+	messageMatcher  = newMessageMatcher(messageLocales)
+)
+
+func loadMessageCatalogs() map[string]*messageCatalog {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]*messageCatalog, len(files))
+	for _, file := range files {
+		content, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := make(map[string]string)
+		if err = json.Unmarshal(content, &messages); err != nil {
+			panic(fmt.Sprintf("message catalog %s: %v", file.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = newMessageCatalog(messages)
+	}
+	return catalogs
+}
+
+func newMessageCatalog(messages map[string]string) *messageCatalog {
+	catalog := &messageCatalog{messages: make(map[string]string, len(messages))}
+	keys := make([]string, 0, len(messages))
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	// Longer patterns are tried first so the most specific one wins.
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		translation := messages[key]
+		if !messageVerb.MatchString(key) {
+			catalog.messages[key] = translation
+			continue
+		}
+		var expression strings.Builder
+		expression.WriteString("^")
+		last := 0
+		for _, verb := range messageVerb.FindAllStringIndex(key, -1) {
+			expression.WriteString(regexp.QuoteMeta(key[last:verb[0]]))
+			switch key[verb[1]-1] {
+			case 'd':
+				expression.WriteString(`(-?\d+)`)
+			case 'q':
+				expression.WriteString(`("(?:[^"\\]|\\.)*")`)
+			default:
+				expression.WriteString(`(.+?)`)
+			}
+			last = verb[1]
+		}
+		expression.WriteString(regexp.QuoteMeta(key[last:]))
+		expression.WriteString("$")
+		catalog.patterns = append(catalog.patterns, messagePattern{
+			match:       regexp.MustCompile(expression.String()),
+			translation: messageVerb.ReplaceAllString(translation, "%${1}s"),
+		})
+	}
+	return catalog
+}
+
+func catalogLocales(catalogs map[string]*messageCatalog) []string {
+	locales := []string{DefaultLocale}
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+func newMessageMatcher(locales []string) language.Matcher {
+	tags := make([]language.Tag, 0, len(locales))
+	for _, locale := range locales {
+		tags = append(tags, language.MustParse(locale))
+	}
+	return language.NewMatcher(tags)
+}
+
+// MessageLocales lists the languages API messages can be returned in.
+func MessageLocales() []string {
+	return messageLocales
+}
+
+// MessageLocale picks the language of API messages for an Accept-Language
+// header, falling back to English.
+func MessageLocale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, index, confidence := messageMatcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return messageLocales[index]
+}
+
+// TranslateMessage returns an API message in the given language. Messages
+// without a translation are returned in English.
+func TranslateMessage(locale, message string) string {
+	catalog, ok := messageCatalogs[locale]
+	if !ok {
+		return message
+	}
+	if translation, ok := catalog.messages[message]; ok {
+		return translation
+	}
+	for _, pattern := range catalog.patterns {
+		values := pattern.match.FindStringSubmatch(message)
+		if values == nil {
+			continue
+		}
+		args := make([]interface{}, 0, len(values)-1)
+		for _, value := range values[1:] {
+			args = append(args, value)
+		}
+		return fmt.Sprintf(pattern.translation, args...)
+	}
+	return message
+}
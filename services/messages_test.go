@@ -0,0 +1,29 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMessageLocale(t *testing.T) {
+	// Test case: Regional variants and weights pick a catalog
+	assert.Equal(t, "hi", MessageLocale("hi-IN,en;q=0.8"))
+	assert.Equal(t, "hi", MessageLocale("fr, hi;q=0.5"))
+
+	// Test case: Unknown or missing languages fall back to English
+	assert.Equal(t, DefaultLocale, MessageLocale("fr"))
+	assert.Equal(t, DefaultLocale, MessageLocale(""))
+}
+
+func TestTranslateMessage(t *testing.T) {
+	// Test case: Fixed messages
+	assert.Equal(t, "JSON अमान्य है", TranslateMessage("hi", "JSON is invalid"))
+
+	// Test case: Values are carried over, in the translation's order
+	assert.Equal(t, "प्रारूप \"pdf\" समर्थित नहीं है", TranslateMessage("hi", `Unsupported format "pdf"`))
+	assert.Equal(t, "5 में से 2 प्रश्न अमान्य हैं। कुछ भी अपलोड नहीं किया गया", TranslateMessage("hi", "2 of 5 questions are invalid. Nothing was uploaded"))
+
+	// Test case: Untranslated messages and languages stay in English
+	assert.Equal(t, "Something new", TranslateMessage("hi", "Something new"))
+	assert.Equal(t, "JSON is invalid", TranslateMessage("fr", "JSON is invalid"))
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func CreateNotifications(ctx context.Context, notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	documents := make([]interface{}, 0, len(notifications))
+	for _, notification := range notifications {
+		documents = append(documents, notification)
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "notifications")
+	_, err := collection.InsertMany(ctx, documents)
+	return err
+}
+
+func GetNotifications(ctx context.Context, username string, unreadOnly bool) ([]models.Notification, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "notifications")
+
+	filter := bson.M{"username": username}
+	if unreadOnly {
+		filter["read"] = false
+	}
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(100))
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]models.Notification, 0)
+	if err = cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead returns false when the notification does not exist or
+// belongs to someone else.
+func MarkNotificationRead(ctx context.Context, id primitive.ObjectID, username string) (bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "notifications")
+	res, err := collection.UpdateOne(ctx, bson.M{"_id": id, "username": username}, bson.M{"$set": bson.M{"read": true}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount != 0, nil
+}
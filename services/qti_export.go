@@ -0,0 +1,290 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var qtiNamespaces = map[string]string{
+	QTIVersion21: "http://www.imsglobal.org/xsd/imsqti_v2p1",
+	QTIVersion30: "http://www.imsglobal.org/xsd/imsqtiasi_v3p0",
+}
+
+var qtiMatchCorrectTemplates = map[string]string{
+	QTIVersion21: "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct",
+	QTIVersion30: "https://purl.imsglobal.org/spec/qti/v3p0/rptemplates/match_correct.xml",
+}
+
+// ErrQTITemplate is returned for template questions. Their options are only
+// computed when a quiz draws its values, so QTI has nothing to hold them.
+var ErrQTITemplate = errors.New("template questions can't be exported to QTI")
+
+func IsQTIVersion(version string) bool {
+	_, ok := qtiNamespaces[version]
+	return ok
+}
+
+// qtiElement is an element written in QTI 2.1 naming. QTI 3.0 output
+// renames QTI elements to their qti- prefixed kebab-case form, while plain
+// elements such as the manifest's keep their name.
+type qtiElement struct {
+	name     string
+	attrs    [][2]string
+	children []qtiElement
+	text     string
+	plain    bool
+}
+
+func qtiTag(name string, attrs ...string) qtiElement {
+	element := qtiElement{name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		element.attrs = append(element.attrs, [2]string{attrs[i], attrs[i+1]})
+	}
+	return element
+}
+
+func (element qtiElement) with(children ...qtiElement) qtiElement {
+	element.children = append(element.children, children...)
+	return element
+}
+
+func (element qtiElement) withText(text string) qtiElement {
+	element.text = text
+	return element
+}
+
+func kebabCase(name string) string {
+	var builder strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				builder.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func (element qtiElement) write(w io.Writer, version string, depth int) error {
+	name := element.name
+	if version == QTIVersion30 && !element.plain {
+		name = "qti-" + kebabCase(name)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(strings.Repeat("  ", depth))
+	builder.WriteString("<" + name)
+	for _, attr := range element.attrs {
+		key := attr[0]
+		if version == QTIVersion30 && !strings.HasPrefix(key, "xmlns") {
+			key = kebabCase(key)
+		}
+		builder.WriteString(" " + key + `="`)
+		xml.EscapeText(&builder, []byte(attr[1]))
+		builder.WriteString(`"`)
+	}
+
+	switch {
+	case len(element.children) != 0:
+		builder.WriteString(">\n")
+		if element.text != "" {
+			builder.WriteString(strings.Repeat("  ", depth+1))
+			xml.EscapeText(&builder, []byte(element.text))
+			builder.WriteString("\n")
+		}
+		if _, err := io.WriteString(w, builder.String()); err != nil {
+			return err
+		}
+		for _, child := range element.children {
+			if err := child.write(w, version, depth+1); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, strings.Repeat("  ", depth)+"</"+name+">\n")
+		return err
+	case element.text != "":
+		builder.WriteString(">")
+		xml.EscapeText(&builder, []byte(element.text))
+		builder.WriteString("</" + name + ">\n")
+	default:
+		builder.WriteString("/>\n")
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// QTIItemIdentifier is the identifier an exported question gets in QTI.
+func QTIItemIdentifier(question models.QuestionUnmarshal) string {
+	if question.ID.IsZero() {
+		return "item"
+	}
+	return "Q" + question.ID.Hex()
+}
+
+func qtiChoiceIdentifier(index int) string {
+	return "Choice" + strconv.Itoa(index+1)
+}
+
+// buildQTIItem maps a question onto a QTI assessment item.
+func buildQTIItem(question models.QuestionUnmarshal, version string) qtiElement {
+	multiple := question.Type == models.QuestionTypeMultipleChoice
+	cardinality, maxChoices := "single", "1"
+	correct := map[string]bool{strings.ToLower(question.CorrectAnswer): true}
+	if multiple {
+		cardinality, maxChoices = "multiple", "0"
+		correct = make(map[string]bool)
+		for _, answer := range question.CorrectAnswers {
+			correct[strings.ToLower(answer)] = true
+		}
+	}
+
+	correctResponse := qtiTag("correctResponse")
+	interaction := qtiTag("choiceInteraction", "responseIdentifier", "RESPONSE", "shuffle", "false", "maxChoices", maxChoices)
+	interaction = interaction.with(qtiTag("prompt").withText(question.QuestionName))
+	for i, option := range question.Options {
+		identifier := qtiChoiceIdentifier(i)
+		if correct[strings.ToLower(option)] {
+			correctResponse = correctResponse.with(qtiTag("value").withText(identifier))
+		}
+		choice := qtiTag("simpleChoice", "identifier", identifier).withText(option)
+		if feedback := question.OptionFeedback[option]; feedback != "" {
+			choice = choice.with(qtiTag("feedbackInline", "outcomeIdentifier", "FEEDBACK", "identifier", identifier, "showHide", "show").withText(feedback))
+		}
+		interaction = interaction.with(choice)
+	}
+
+	item := qtiTag("assessmentItem",
+		"xmlns", qtiNamespaces[version],
+		"identifier", QTIItemIdentifier(question),
+		"title", qtiTitle(question.QuestionName),
+		"adaptive", "false",
+		"timeDependent", "false",
+	)
+	item = item.with(qtiTag("responseDeclaration", "identifier", "RESPONSE", "cardinality", cardinality, "baseType", "identifier").with(correctResponse))
+	item = item.with(qtiTag("outcomeDeclaration", "identifier", "SCORE", "cardinality", "single", "baseType", "float").
+		with(qtiTag("defaultValue").with(qtiTag("value").withText("0"))))
+	if question.Points > 0 {
+		item = item.with(qtiTag("outcomeDeclaration", "identifier", "MAXSCORE", "cardinality", "single", "baseType", "float").
+			with(qtiTag("defaultValue").with(qtiTag("value").withText(strconv.FormatFloat(question.Points, 'f', -1, 64)))))
+	}
+	if question.Explanation != "" || len(question.OptionFeedback) != 0 {
+		item = item.with(qtiTag("outcomeDeclaration", "identifier", "FEEDBACK", "cardinality", "single", "baseType", "identifier"))
+	}
+	item = item.with(qtiTag("itemBody").with(interaction))
+	item = item.with(qtiTag("responseProcessing", "template", qtiMatchCorrectTemplates[version]))
+	if question.Explanation != "" {
+		item = item.with(qtiTag("modalFeedback", "outcomeIdentifier", "FEEDBACK", "identifier", "EXPLANATION", "showHide", "hide").withText(question.Explanation))
+	}
+	return item
+}
+
+func qtiTitle(text string) string {
+	runes := []rune(text)
+	if len(runes) > 60 {
+		return string(runes[:57]) + "..."
+	}
+	return text
+}
+
+// WriteQTIItem writes a question as a standalone QTI assessment item.
+func WriteQTIItem(w io.Writer, question models.QuestionUnmarshal, version string) error {
+	if !IsQTIVersion(version) {
+		return fmt.Errorf("unsupported QTI version %q", version)
+	}
+	if question.Template != nil {
+		return ErrQTITemplate
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return buildQTIItem(question, version).write(w, version, 0)
+}
+
+// QTIPackageWriter streams questions into a QTI content package. Items are
+// written as they are added; the manifest is written by Close. Template
+// questions are skipped and noted in Warnings.
+type QTIPackageWriter struct {
+	archive   *zip.Writer
+	version   string
+	resources []qtiElement
+	Warnings  []ImportWarning
+}
+
+func NewQTIPackageWriter(w io.Writer, version string) (*QTIPackageWriter, error) {
+	if !IsQTIVersion(version) {
+		return nil, fmt.Errorf("unsupported QTI version %q", version)
+	}
+	return &QTIPackageWriter{archive: zip.NewWriter(w), version: version}, nil
+}
+
+func (writer *QTIPackageWriter) Add(question models.QuestionUnmarshal) error {
+	identifier := QTIItemIdentifier(question)
+	if question.Template != nil {
+		writer.Warnings = append(writer.Warnings, ImportWarning{Item: identifier, Message: ErrQTITemplate.Error() + "; skipped"})
+		return nil
+	}
+	if identifier == "item" {
+		identifier = fmt.Sprintf("item%d", len(writer.resources)+1)
+	}
+	href := "items/" + identifier + ".xml"
+
+	file, err := writer.archive.Create(href)
+	if err != nil {
+		return err
+	}
+	if err = WriteQTIItem(file, question, writer.version); err != nil {
+		return err
+	}
+
+	resourceType := "imsqti_item_xmlv2p1"
+	if writer.version == QTIVersion30 {
+		resourceType = "imsqti_item_xmlv3p0"
+	}
+	writer.resources = append(writer.resources, qtiManifestTag("resource", "identifier", identifier, "type", resourceType, "href", href).
+		with(qtiManifestTag("file", "href", href)))
+	return nil
+}
+
+func (writer *QTIPackageWriter) Close() error {
+	file, err := writer.archive.Create("imsmanifest.xml")
+	if err != nil {
+		return err
+	}
+
+	namespace, schema, schemaVersion := "http://www.imsglobal.org/xsd/imscp_v1p1", "QTIv2.1 Package", "1.0.0"
+	if writer.version == QTIVersion30 {
+		namespace, schema, schemaVersion = "http://www.imsglobal.org/xsd/qti/qtiv3p0/imscp_v1p1", "QTI Package", "3.0.0"
+	}
+	manifest := qtiManifestTag("manifest", "xmlns", namespace, "identifier", "MANIFEST").with(
+		qtiManifestTag("metadata").with(
+			qtiManifestTag("schema").withText(schema),
+			qtiManifestTag("schemaversion").withText(schemaVersion),
+		),
+		qtiManifestTag("organizations"),
+		qtiManifestTag("resources").with(writer.resources...),
+	)
+
+	if _, err = io.WriteString(file, xml.Header); err != nil {
+		return err
+	}
+	// manifest elements keep their names in every QTI version
+	if err = manifest.write(file, QTIVersion21, 0); err != nil {
+		return err
+	}
+	return writer.archive.Close()
+}
+
+func qtiManifestTag(name string, attrs ...string) qtiElement {
+	element := qtiTag(name, attrs...)
+	element.plain = true
+	return element
+}
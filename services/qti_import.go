@@ -0,0 +1,418 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ImportFormatQTI = "qti"
+
+	QTIVersion21 = "2.1"
+	QTIVersion30 = "3.0"
+)
+
+// qtiNode is a namespace-free view of a QTI document. Element and attribute
+// names are normalized so that QTI 2.1 (choiceInteraction, maxChoices) and
+// QTI 3.0 (qti-choice-interaction, max-choices) read the same way. Text
+// content is kept as children with an empty name.
+type qtiNode struct {
+	Name     string
+	Local    string
+	Attrs    map[string]string
+	Children []*qtiNode
+	Text     string
+}
+
+func normalizeQTIName(name string) string {
+	name = strings.TrimPrefix(strings.ToLower(name), "qti-")
+	return strings.ReplaceAll(name, "-", "")
+}
+
+func parseQTINode(content []byte) (*qtiNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	root := &qtiNode{}
+	stack := []*qtiNode{root}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		parent := stack[len(stack)-1]
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &qtiNode{Name: normalizeQTIName(token.Name.Local), Local: token.Name.Local, Attrs: make(map[string]string)}
+			for _, attr := range token.Attr {
+				node.Attrs[normalizeQTIName(attr.Name.Local)] = attr.Value
+			}
+			parent.Children = append(parent.Children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			parent.Children = append(parent.Children, &qtiNode{Text: string(token)})
+		}
+	}
+	for _, child := range root.Children {
+		if child.Name != "" {
+			return child, nil
+		}
+	}
+	return nil, errors.New("document has no root element")
+}
+
+func (node *qtiNode) find(name string) *qtiNode {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+func (node *qtiNode) findAll(name string) []*qtiNode {
+	found := make([]*qtiNode, 0)
+	for _, child := range node.Children {
+		if child.Name == name {
+			found = append(found, child)
+		}
+	}
+	return found
+}
+
+// walk visits every element below node, stopping at elements for which
+// visit returns false.
+func (node *qtiNode) walk(visit func(*qtiNode) bool) {
+	for _, child := range node.Children {
+		if child.Name != "" && visit(child) {
+			child.walk(visit)
+		}
+	}
+}
+
+// text returns the text of node with whitespace collapsed, leaving out the
+// elements named in skip.
+func (node *qtiNode) text(skip ...string) string {
+	var builder strings.Builder
+	var collect func(*qtiNode)
+	collect = func(current *qtiNode) {
+		for _, child := range current.Children {
+			if child.Name == "" {
+				builder.WriteString(child.Text)
+				builder.WriteString(" ")
+				continue
+			}
+			skipped := false
+			for _, name := range skip {
+				if child.Name == name {
+					skipped = true
+				}
+			}
+			if !skipped {
+				collect(child)
+			}
+		}
+	}
+	collect(node)
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// qtiUnsupportedContent lists body elements whose content can't be stored on
+// a text question.
+var qtiUnsupportedContent = map[string]string{
+	"img":    "image",
+	"object": "embedded object",
+	"math":   "MathML formula",
+	"audio":  "audio",
+	"video":  "video",
+}
+
+// ParseQTIItem maps a QTI 2.1 or 3.0 assessment item onto a question. Items
+// whose interactions can't be represented return a nil question and the
+// reason in the warnings.
+func ParseQTIItem(content []byte) (*models.QuestionUnmarshal, []string, error) {
+	root, err := parseQTINode(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid XML: %v", err)
+	}
+	if root.Name != "assessmentitem" {
+		return nil, nil, fmt.Errorf("expected an assessment item, found <%s>", root.Local)
+	}
+
+	warnings := make([]string, 0)
+	body := root.find("itembody")
+	if body == nil {
+		return nil, []string{"item has no item body"}, nil
+	}
+
+	choiceInteractions := make([]*qtiNode, 0)
+	unsupported := make(map[string]bool)
+	dropped := make(map[string]bool)
+	body.walk(func(node *qtiNode) bool {
+		if node.Name == "choiceinteraction" {
+			choiceInteractions = append(choiceInteractions, node)
+			return true
+		}
+		if strings.HasSuffix(node.Name, "interaction") {
+			unsupported[node.Local] = true
+			return false
+		}
+		if kind, ok := qtiUnsupportedContent[node.Name]; ok {
+			dropped[kind] = true
+		}
+		return true
+	})
+
+	for _, name := range sortedKeys(unsupported) {
+		warnings = append(warnings, fmt.Sprintf("<%s> is not supported", name))
+	}
+	if len(unsupported) != 0 || len(choiceInteractions) != 1 {
+		if len(choiceInteractions) > 1 {
+			warnings = append(warnings, "items with more than one choice interaction are not supported")
+		}
+		if len(choiceInteractions) == 0 && len(unsupported) == 0 {
+			warnings = append(warnings, "item has no choice interaction")
+		}
+		return nil, warnings, nil
+	}
+	for _, kind := range sortedKeys(dropped) {
+		warnings = append(warnings, fmt.Sprintf("%s content was left out because only text is imported", kind))
+	}
+
+	interaction := choiceInteractions[0]
+	question := models.QuestionUnmarshal{
+		Type: models.QuestionTypeSingleChoice,
+	}
+
+	stem := body.text("choiceinteraction", "feedbackblock", "feedbackinline", "rubricblock")
+	if prompt := interaction.find("prompt"); prompt != nil {
+		stem = strings.TrimSpace(stem + " " + prompt.text())
+	}
+	question.QuestionName = stem
+	if stem == "" {
+		question.QuestionName = root.Attrs["title"]
+	}
+
+	choiceText := make(map[string]string)
+	for _, choice := range interaction.findAll("simplechoice") {
+		text := choice.text("feedbackinline")
+		choiceText[choice.Attrs["identifier"]] = text
+		question.Options = append(question.Options, text)
+		if feedback := choice.find("feedbackinline"); feedback != nil {
+			if question.OptionFeedback == nil {
+				question.OptionFeedback = make(map[string]string)
+			}
+			question.OptionFeedback[text] = feedback.text()
+		}
+	}
+
+	responseId := interaction.Attrs["responseidentifier"]
+	var declaration *qtiNode
+	for _, candidate := range root.findAll("responsedeclaration") {
+		if candidate.Attrs["identifier"] == responseId {
+			declaration = candidate
+		}
+	}
+	if declaration != nil {
+		if declaration.Attrs["cardinality"] == "multiple" {
+			question.Type = models.QuestionTypeMultipleChoice
+		}
+		if declaration.find("mapping") != nil {
+			warnings = append(warnings, "response mapping was ignored; the item is scored by the correct response")
+		}
+		if correct := declaration.find("correctresponse"); correct != nil {
+			for _, value := range correct.findAll("value") {
+				text, ok := choiceText[value.text()]
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("correct response %q is not one of the choices", value.text()))
+					continue
+				}
+				question.CorrectAnswers = append(question.CorrectAnswers, text)
+			}
+		}
+	}
+	if question.Type == models.QuestionTypeSingleChoice {
+		if len(question.CorrectAnswers) != 0 {
+			question.CorrectAnswer = question.CorrectAnswers[0]
+		}
+		question.CorrectAnswers = nil
+	}
+	question.Distractors = defaultDistractors(question)
+
+	for _, outcome := range root.findAll("outcomedeclaration") {
+		if outcome.Attrs["identifier"] != "MAXSCORE" {
+			continue
+		}
+		if value := outcome.find("defaultvalue"); value != nil {
+			if points, err := strconv.ParseFloat(value.text(), 64); err == nil {
+				question.Points = points
+			}
+		}
+	}
+
+	explanations := make([]string, 0)
+	for _, feedback := range root.findAll("modalfeedback") {
+		if text := feedback.text(); text != "" {
+			explanations = append(explanations, text)
+		}
+	}
+	question.Explanation = strings.Join(explanations, "\n")
+
+	return &question, warnings, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeQTIQuestions reads either a single assessment item or a content
+// package zip with an imsmanifest.xml.
+func decodeQTIQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	if bytes.HasPrefix(content, []byte("PK")) {
+		return decodeQTIPackage(content, options.maxBytes())
+	}
+
+	result := ImportResult{}
+	question, warnings, err := ParseQTIItem(content)
+	if err != nil {
+		return result, err
+	}
+	appendQTIItem(&result, 1, "", question, warnings)
+	return result, nil
+}
+
+// decodeQTIPackage reads the items of a content package. Files in it are
+// read up to maxBytes each, so a small zip can't unpack into more than an
+// upload may hold.
+func decodeQTIPackage(content []byte, maxBytes int64) (ImportResult, error) {
+	result := ImportResult{}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return result, errors.New("invalid content package. Please upload a valid zip file")
+	}
+
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[path.Clean(file.Name)] = file
+	}
+
+	manifestFile, ok := files["imsmanifest.xml"]
+	if !ok {
+		return result, errors.New("content package has no imsmanifest.xml")
+	}
+	// Every file read counts toward the limit, so a manifest listing the
+	// same large file many times can't unpack past it.
+	unpacked := int64(0)
+	manifestContent, err := readZipFile(manifestFile, maxBytes, &unpacked)
+	if err != nil {
+		return result, err
+	}
+	manifest, err := parseQTINode(manifestContent)
+	if err != nil {
+		return result, fmt.Errorf("invalid imsmanifest.xml: %v", err)
+	}
+
+	resources := manifest.find("resources")
+	if resources == nil {
+		return result, errors.New("imsmanifest.xml lists no resources")
+	}
+
+	row := 0
+	for _, resource := range resources.findAll("resource") {
+		resourceType := resource.Attrs["type"]
+		href := resource.Attrs["href"]
+		if !strings.HasPrefix(resourceType, "imsqti_item_xml") {
+			if strings.HasPrefix(resourceType, "imsqti_test_xml") || strings.HasPrefix(resourceType, "imsqti_section_xml") {
+				result.Warnings = append(result.Warnings, ImportWarning{
+					Item:    href,
+					Message: "assessment tests and sections are not imported; their items are imported when listed as resources",
+				})
+			}
+			continue
+		}
+
+		row++
+		file, ok := files[path.Clean(href)]
+		if !ok {
+			result.Warnings = append(result.Warnings, ImportWarning{Row: row, Item: href, Message: "file listed in the manifest is missing"})
+			continue
+		}
+		itemContent, err := readZipFile(file, maxBytes, &unpacked)
+		if err != nil {
+			return result, err
+		}
+		question, warnings, err := ParseQTIItem(itemContent)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row, Item: href, Errors: []string{err.Error()}})
+			continue
+		}
+		appendQTIItem(&result, row, href, question, warnings)
+	}
+
+	if row == 0 {
+		return result, ErrEmptyImport
+	}
+	return result, nil
+}
+
+func appendQTIItem(result *ImportResult, row int, item string, question *models.QuestionUnmarshal, warnings []string) {
+	for _, warning := range warnings {
+		result.Warnings = append(result.Warnings, ImportWarning{Row: row, Item: item, Message: warning})
+	}
+	if question == nil {
+		return
+	}
+	if errs := ValidateQuestion(question); len(errs) != 0 {
+		result.Errors = append(result.Errors, ImportRowError{Row: row, Item: item, Errors: errs})
+	}
+	result.Questions = append(result.Questions, *question)
+}
+
+// readZipFile reads a file of a content package and adds its size to
+// unpacked, failing once the package as a whole unpacks to more than
+// maxBytes.
+func readZipFile(file *zip.File, maxBytes int64, unpacked *int64) ([]byte, error) {
+	tooLarge := fmt.Errorf("content package is larger than the upload limit of %d bytes once unpacked", maxBytes)
+	remaining := maxBytes - *unpacked
+	if file.UncompressedSize64 > uint64(remaining) {
+		return nil, tooLarge
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", file.Name, err)
+	}
+	defer reader.Close()
+	// the size in the zip header can't be trusted, so stop reading one byte
+	// past the limit
+	content, err := io.ReadAll(io.LimitReader(reader, remaining+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", file.Name, err)
+	}
+	if int64(len(content)) > remaining {
+		return nil, tooLarge
+	}
+	*unpacked += int64(len(content))
+	return content, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"testing"
+)
+
+func qtiTestQuestions() []models.QuestionUnmarshal {
+	return []models.QuestionUnmarshal{
+		{
+			ID:            primitive.NewObjectID(),
+			QuestionName:  "What is the capital of India?",
+			Type:          models.QuestionTypeSingleChoice,
+			Points:        2,
+			Options:       []string{"Mumbai", "Kolkata", "New Delhi", "Bangalore"},
+			CorrectAnswer: "New Delhi",
+			Distractors:   []string{"Mumbai", "Kolkata", "Bangalore"},
+			Explanation:   "New Delhi has been the capital of India since 1931.",
+			OptionFeedback: map[string]string{
+				"Mumbai": "Mumbai is the financial capital, not the seat of government.",
+			},
+		},
+		{
+			ID:             primitive.NewObjectID(),
+			QuestionName:   "Which of these are primary colours? <Pick two & more>",
+			Type:           models.QuestionTypeMultipleChoice,
+			Options:        []string{"Red", "Green", "Blue"},
+			CorrectAnswers: []string{"Red", "Blue"},
+			Distractors:    []string{"Green"},
+		},
+	}
+}
+
+func assertQTIRoundTrip(t *testing.T, expected, actual models.QuestionUnmarshal) {
+	assert.Equal(t, expected.QuestionName, actual.QuestionName)
+	assert.Equal(t, expected.Type, actual.Type)
+	assert.Equal(t, expected.Points, actual.Points)
+	assert.Equal(t, expected.Options, actual.Options)
+	assert.Equal(t, expected.CorrectAnswer, actual.CorrectAnswer)
+	assert.Equal(t, expected.CorrectAnswers, actual.CorrectAnswers)
+	assert.Equal(t, expected.Distractors, actual.Distractors)
+	assert.Equal(t, expected.Explanation, actual.Explanation)
+	assert.Equal(t, expected.OptionFeedback, actual.OptionFeedback)
+}
+
+func TestQTIItemRoundTrip(t *testing.T) {
+	for _, version := range []string{QTIVersion21, QTIVersion30} {
+		for _, question := range qtiTestQuestions() {
+			var buffer bytes.Buffer
+			assert.NoError(t, WriteQTIItem(&buffer, question, version))
+			if version == QTIVersion30 {
+				assert.Contains(t, buffer.String(), "<qti-choice-interaction")
+			} else {
+				assert.Contains(t, buffer.String(), "<choiceInteraction")
+			}
+
+			imported, warnings, err := ParseQTIItem(buffer.Bytes())
+			assert.NoError(t, err)
+			assert.Empty(t, warnings)
+			if assert.NotNil(t, imported) {
+				assertQTIRoundTrip(t, question, *imported)
+			}
+		}
+	}
+}
+
+func TestQTIPackageRoundTrip(t *testing.T) {
+	questions := qtiTestQuestions()
+	for _, version := range []string{QTIVersion21, QTIVersion30} {
+		var buffer bytes.Buffer
+		writer, err := NewQTIPackageWriter(&buffer, version)
+		assert.NoError(t, err)
+		for _, question := range questions {
+			assert.NoError(t, writer.Add(question))
+		}
+		assert.NoError(t, writer.Close())
+
+		result, err := DecodeQuestions(ImportFormatQTI, buffer.Bytes(), ImportOptions{})
+		assert.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Empty(t, result.Warnings)
+		if assert.Len(t, result.Questions, len(questions)) {
+			for i := range questions {
+				assertQTIRoundTrip(t, questions[i], result.Questions[i])
+			}
+		}
+	}
+
+	_, err := NewQTIPackageWriter(&bytes.Buffer{}, "1.2")
+	assert.Error(t, err)
+}
+
+func TestQTIPackageSizeLimit(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewQTIPackageWriter(&buffer, QTIVersion21)
+	assert.NoError(t, err)
+	question := qtiTestQuestions()[0]
+	question.Explanation = strings.Repeat("New Delhi has been the capital since 1931. ", 1000)
+	assert.NoError(t, writer.Add(question))
+	assert.NoError(t, writer.Close())
+
+	// Test case: Files that unpack to more than the limit are refused
+	_, err = DecodeQuestions(ImportFormatQTI, buffer.Bytes(), ImportOptions{MaxBytes: 16 << 10})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "larger than the upload limit")
+	}
+
+	// Test case: The same package is read within the limit
+	result, err := DecodeQuestions(ImportFormatQTI, buffer.Bytes(), ImportOptions{MaxBytes: 1 << 20})
+	assert.NoError(t, err)
+	assert.Len(t, result.Questions, 1)
+
+	// Test case: A manifest listing the same file again and again counts
+	// every read toward the limit
+	question.Explanation = strings.Repeat("New Delhi has been the capital since 1931. ", 100)
+	var item bytes.Buffer
+	assert.NoError(t, WriteQTIItem(&item, question, QTIVersion21))
+	manifest := `<manifest><resources>` +
+		strings.Repeat(`<resource type="imsqti_item_xml_v2p1" href="item.xml"/>`, 100) +
+		`</resources></manifest>`
+
+	var repeated bytes.Buffer
+	archive := zip.NewWriter(&repeated)
+	for name, content := range map[string][]byte{"imsmanifest.xml": []byte(manifest), "item.xml": item.Bytes()} {
+		file, err := archive.Create(name)
+		assert.NoError(t, err)
+		_, err = file.Write(content)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, archive.Close())
+
+	_, err = DecodeQuestions(ImportFormatQTI, repeated.Bytes(), ImportOptions{MaxBytes: 64 << 10})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "larger than the upload limit")
+	}
+}
+
+func TestParseQTIItemWarnings(t *testing.T) {
+	item := `<?xml version="1.0" encoding="UTF-8"?>
+<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="map" title="Map">
+  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier">
+    <correctResponse><value>B</value></correctResponse>
+    <mapping defaultValue="0"><mapEntry mapKey="B" mappedValue="1"/></mapping>
+  </responseDeclaration>
+  <itemBody>
+    <p>Look at the map.</p>
+    <img src="map.png" alt="map"/>
+    <choiceInteraction responseIdentifier="RESPONSE" maxChoices="1">
+      <prompt>Which river is marked?</prompt>
+      <simpleChoice identifier="A">Yamuna</simpleChoice>
+      <simpleChoice identifier="B">Ganga</simpleChoice>
+    </choiceInteraction>
+  </itemBody>
+</assessmentItem>`
+
+	question, warnings, err := ParseQTIItem([]byte(item))
+	assert.NoError(t, err)
+	assert.Equal(t, "Look at the map. Which river is marked?", question.QuestionName)
+	assert.Equal(t, "Ganga", question.CorrectAnswer)
+	assert.Equal(t, []string{"Yamuna"}, question.Distractors)
+	assert.Len(t, warnings, 2)
+
+	// Test case: interactions we can't grade are skipped with a warning
+	item = `<qti-assessment-item xmlns="http://www.imsglobal.org/xsd/imsqtiasi_v3p0" identifier="essay">
+  <qti-item-body>
+    <qti-extended-text-interaction response-identifier="RESPONSE"/>
+  </qti-item-body>
+</qti-assessment-item>`
+	question, warnings, err = ParseQTIItem([]byte(item))
+	assert.NoError(t, err)
+	assert.Nil(t, question)
+	assert.Equal(t, []string{"<qti-extended-text-interaction> is not supported"}, warnings)
+
+	_, _, err = ParseQTIItem([]byte(strings.Replace(item, "qti-assessment-item", "qti-assessment-test", -1)))
+	assert.Error(t, err)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// ExportOptions carries the request options that apply to an export.
+type ExportOptions struct {
+	QTIVersion string
+}
+
+// QuestionEncoder writes questions to an export as they are read from the
+// bank. Close finishes the file.
+type QuestionEncoder interface {
+	Add(question models.QuestionUnmarshal) error
+	Close() error
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	encoder     func(w io.Writer, options ExportOptions) (QuestionEncoder, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	ImportFormatJSON: {contentType: "application/json", extension: "json", encoder: newJSONQuestionEncoder},
+	ImportFormatCSV:  {contentType: "text/csv", extension: "csv", encoder: newCSVQuestionEncoder},
+	ImportFormatQTI: {contentType: "application/zip", extension: "zip", encoder: func(w io.Writer, options ExportOptions) (QuestionEncoder, error) {
+		return NewQTIPackageWriter(w, options.QTIVersion)
+	}},
+}
+
+func IsExportFormat(name string) bool {
+	_, ok := exportFormats[name]
+	return ok
+}
+
+// ExportContentType returns the content type and file extension of an
+// export format.
+func ExportContentType(format string) (string, string) {
+	return exportFormats[format].contentType, exportFormats[format].extension
+}
+
+func NewQuestionEncoder(format string, w io.Writer, options ExportOptions) (QuestionEncoder, error) {
+	exporter, ok := exportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return exporter.encoder(w, options)
+}
+
+// QuestionExportFilter selects the questions of a topic and/or carrying all
+// of the given tags. Without either it selects the whole bank.
+func QuestionExportFilter(topic string, tags []string, status string) bson.M {
+	filter := bson.M{}
+	if topic != "" {
+		filter["topic"] = topic
+	}
+	if tags = NormalizeTags(tags); len(tags) != 0 {
+		filter["tags"] = bson.M{"$all": tags}
+	}
+	if status == models.QuestionStatusPublished {
+		filter["status"] = PublishedStatus()
+	} else if status != "" {
+		filter["status"] = status
+	}
+	return filter
+}
+
+// TemplateQuestionFilter narrows filter to template questions, e.g. to count
+// the ones a QTI export skips.
+func TemplateQuestionFilter(filter bson.M) bson.M {
+	templates := maps.Clone(filter)
+	templates["template"] = bson.M{"$exists": true}
+	return templates
+}
+
+func CountQuestions(ctx context.Context, filter bson.M) (int64, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	return collection.CountDocuments(ctx, filter)
+}
+
+// ExportQuestions streams the matching questions into the encoder one at a
+// time, so that exporting the whole bank does not hold it in memory.
+func ExportQuestions(ctx context.Context, filter bson.M, encoder QuestionEncoder) (int, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	exported := 0
+	for cursor.Next(ctx) {
+		var question models.QuestionUnmarshal
+		if err = cursor.Decode(&question); err != nil {
+			return exported, err
+		}
+		if err = encoder.Add(question); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+	if err = cursor.Err(); err != nil {
+		return exported, err
+	}
+	return exported, encoder.Close()
+}
+
+// exportedQuestion holds the fields of the upload JSON format, so that an
+// export can be uploaded again as it is.
+type exportedQuestion struct {
+	Topic          string                   `json:"topic,omitempty"`
+	QuestionName   string                   `json:"question"`
+	Format         string                   `json:"format,omitempty"`
+	Type           string                   `json:"type,omitempty"`
+	Points         float64                  `json:"points,omitempty"`
+	Options        []string                 `json:"options"`
+	CorrectAnswer  string                   `json:"correct_answer,omitempty"`
+	CorrectAnswers []string                 `json:"correct_answers,omitempty"`
+	Distractors    []string                 `json:"distractors"`
+	Explanation    string                   `json:"explanation,omitempty"`
+	OptionFeedback map[string]string        `json:"option_feedback,omitempty"`
+	IRT            *models.IRTParameters    `json:"irt,omitempty"`
+	Tags           []string                 `json:"tags,omitempty"`
+	Difficulty     string                   `json:"difficulty,omitempty"`
+	Media          []models.MediaReference  `json:"media,omitempty"`
+	Template       *models.QuestionTemplate `json:"template,omitempty"`
+	Translations   models.Translations      `json:"translations,omitempty"`
+}
+
+type jsonQuestionEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONQuestionEncoder(w io.Writer, options ExportOptions) (QuestionEncoder, error) {
+	return &jsonQuestionEncoder{w: w}, nil
+}
+
+func (encoder *jsonQuestionEncoder) Add(question models.QuestionUnmarshal) error {
+	content, err := json.MarshalIndent(exportedQuestion{
+		Topic:          question.Topic,
+		QuestionName:   question.QuestionName,
+		Format:         question.Format,
+		Type:           question.Type,
+		Points:         question.Points,
+		Options:        question.Options,
+		CorrectAnswer:  question.CorrectAnswer,
+		CorrectAnswers: question.CorrectAnswers,
+		Distractors:    question.Distractors,
+		Explanation:    question.Explanation,
+		OptionFeedback: question.OptionFeedback,
+		IRT:            question.IRT,
+		Tags:           question.Tags,
+		Difficulty:     question.Difficulty,
+		Media:          question.Media,
+		Template:       question.Template,
+		Translations:   question.Translations,
+	}, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n  "
+	if encoder.count == 0 {
+		separator = "[\n  "
+	}
+	encoder.count++
+	if _, err = io.WriteString(encoder.w, separator); err != nil {
+		return err
+	}
+	_, err = encoder.w.Write(content)
+	return err
+}
+
+func (encoder *jsonQuestionEncoder) Close() error {
+	if encoder.count == 0 {
+		_, err := io.WriteString(encoder.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(encoder.w, "\n]\n")
+	return err
+}
+
+// csvExportColumns are written in this order; they match the default column
+// mapping of spreadsheet uploads, plus the topic. Templates are written as
+// JSON.
+var csvExportColumns = []string{"topic", "question", "format", "type", "points", "options", "correct_answer", "correct_answers", "distractors", "tags", "difficulty", "explanation", "template"}
+
+type csvQuestionEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVQuestionEncoder(w io.Writer, options ExportOptions) (QuestionEncoder, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvExportColumns); err != nil {
+		return nil, err
+	}
+	return &csvQuestionEncoder{writer: writer}, nil
+}
+
+func (encoder *csvQuestionEncoder) Add(question models.QuestionUnmarshal) error {
+	points := ""
+	if question.Points != 0 {
+		points = strconv.FormatFloat(question.Points, 'f', -1, 64)
+	}
+	template := ""
+	if question.Template != nil {
+		content, err := json.Marshal(question.Template)
+		if err != nil {
+			return err
+		}
+		template = string(content)
+	}
+	return encoder.writer.Write([]string{
+		question.Topic,
+		question.QuestionName,
+		question.Format,
+		question.Type,
+		points,
+		strings.Join(question.Options, listSeparator),
+		question.CorrectAnswer,
+		strings.Join(question.CorrectAnswers, listSeparator),
+		strings.Join(question.Distractors, listSeparator),
+		strings.Join(question.Tags, listSeparator),
+		question.Difficulty,
+		question.Explanation,
+		template,
+	})
+}
+
+func (encoder *csvQuestionEncoder) Close() error {
+	encoder.writer.Flush()
+	return encoder.writer.Error()
+}
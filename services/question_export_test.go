@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func exportTestQuestions() []models.QuestionUnmarshal {
+	return []models.QuestionUnmarshal{
+		{
+			Topic:         "geography",
+			QuestionName:  "What is the capital of India?",
+			Type:          models.QuestionTypeSingleChoice,
+			Points:        2,
+			Options:       []string{"Mumbai", "New Delhi"},
+			CorrectAnswer: "New Delhi",
+			Distractors:   []string{"Mumbai"},
+			Tags:          []string{"chapter-1"},
+			Difficulty:    models.DifficultyEasy,
+			Explanation:   "Since 1931.",
+		},
+		{
+			Topic:          "geography",
+			QuestionName:   "Which rivers flow into the Bay of Bengal?",
+			Type:           models.QuestionTypeMultipleChoice,
+			Options:        []string{"Ganga", "Godavari", "Narmada"},
+			CorrectAnswers: []string{"Ganga", "Godavari"},
+			Distractors:    []string{"Narmada"},
+		},
+	}
+}
+
+func exportQuestions(t *testing.T, format string) []byte {
+	var buffer bytes.Buffer
+	encoder, err := NewQuestionEncoder(format, &buffer, ExportOptions{QTIVersion: QTIVersion21})
+	assert.NoError(t, err)
+	for _, question := range exportTestQuestions() {
+		assert.NoError(t, encoder.Add(question))
+	}
+	assert.NoError(t, encoder.Close())
+	return buffer.Bytes()
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	// Test case: each export format can be uploaded again
+	for _, format := range []string{ImportFormatJSON, ImportFormatCSV, ImportFormatQTI} {
+		result, err := DecodeQuestions(format, exportQuestions(t, format), ImportOptions{})
+		assert.NoError(t, err, format)
+		assert.Empty(t, result.Errors, format)
+		if !assert.Len(t, result.Questions, 2, format) {
+			continue
+		}
+		for i, expected := range exportTestQuestions() {
+			actual := result.Questions[i]
+			assert.Equal(t, expected.QuestionName, actual.QuestionName, format)
+			assert.Equal(t, expected.Options, actual.Options, format)
+			assert.Equal(t, expected.CorrectAnswer, actual.CorrectAnswer, format)
+			assert.Equal(t, expected.CorrectAnswers, actual.CorrectAnswers, format)
+			assert.Equal(t, expected.Distractors, actual.Distractors, format)
+			assert.Equal(t, expected.Points, actual.Points, format)
+			assert.Equal(t, expected.Explanation, actual.Explanation, format)
+		}
+		if format != ImportFormatQTI {
+			assert.Equal(t, []string{"chapter-1"}, result.Questions[0].Tags, format)
+			assert.Equal(t, models.DifficultyEasy, result.Questions[0].Difficulty, format)
+		}
+	}
+
+	// Test case: an empty export is still a valid JSON array
+	var buffer bytes.Buffer
+	encoder, _ := NewQuestionEncoder(ImportFormatJSON, &buffer, ExportOptions{})
+	assert.NoError(t, encoder.Close())
+	assert.Equal(t, "[]\n", buffer.String())
+}
+
+func exportTemplateQuestion() models.QuestionUnmarshal {
+	return models.QuestionUnmarshal{
+		ID:           primitive.NewObjectID(),
+		Topic:        "arithmetic",
+		QuestionName: "What is {a} + {b}?",
+		Type:         models.QuestionTypeSingleChoice,
+		Template: &models.QuestionTemplate{
+			Variables:   []models.TemplateVariable{{Name: "a", Min: 1, Max: 9}, {Name: "b", Min: 1, Max: 9}},
+			Answer:      "a + b",
+			Distractors: []string{"a - b", "a * b"},
+		},
+	}
+}
+
+func TestExportTemplateRoundTrip(t *testing.T) {
+	template := exportTemplateQuestion()
+
+	// Test case: JSON and CSV exports keep the template
+	for _, format := range []string{ImportFormatJSON, ImportFormatCSV} {
+		var buffer bytes.Buffer
+		encoder, err := NewQuestionEncoder(format, &buffer, ExportOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, encoder.Add(template))
+		assert.NoError(t, encoder.Close())
+
+		result, err := DecodeQuestions(format, buffer.Bytes(), ImportOptions{})
+		assert.NoError(t, err, format)
+		assert.Empty(t, result.Errors, format)
+		if assert.Len(t, result.Questions, 1, format) {
+			assert.Equal(t, template.QuestionName, result.Questions[0].QuestionName, format)
+			assert.Equal(t, template.Template, result.Questions[0].Template, format)
+		}
+	}
+
+	// Test case: QTI packages skip template questions with a warning
+	var buffer bytes.Buffer
+	writer, err := NewQTIPackageWriter(&buffer, QTIVersion21)
+	assert.NoError(t, err)
+	for _, question := range append(exportTestQuestions(), template) {
+		assert.NoError(t, writer.Add(question))
+	}
+	assert.NoError(t, writer.Close())
+	if assert.Len(t, writer.Warnings, 1) {
+		assert.Equal(t, QTIItemIdentifier(template), writer.Warnings[0].Item)
+	}
+	result, err := DecodeQuestions(ImportFormatQTI, buffer.Bytes(), ImportOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	assert.Len(t, result.Questions, 2)
+
+	// Test case: A template question can't be written as a QTI item
+	assert.ErrorIs(t, WriteQTIItem(&bytes.Buffer{}, template, QTIVersion21), ErrQTITemplate)
+}
+
+func TestQuestionExportFilter(t *testing.T) {
+	assert.Equal(t, bson.M{}, QuestionExportFilter("", nil, ""))
+	assert.Equal(t, bson.M{
+		"topic": "geography",
+		"tags":  bson.M{"$all": []string{"chapter-1", "maps"}},
+	}, QuestionExportFilter("geography", []string{" Chapter-1", "maps", ""}, ""))
+	assert.Equal(t, bson.M{"status": PublishedStatus()}, QuestionExportFilter("", nil, models.QuestionStatusPublished))
+}
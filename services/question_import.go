@@ -0,0 +1,400 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"github.com/zeekhoks/quiz-backend/models"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ImportFormatJSON = "json"
+	ImportFormatCSV  = "csv"
+	ImportFormatXLSX = "xlsx"
+)
+
+// listSeparator splits list cells such as options or tags in a spreadsheet.
+const listSeparator = "|"
+
+// ImportRowError lists the problems found in one row of an uploaded file.
+// Rows are numbered as the author sees them: for spreadsheets the header is
+// row 1, for JSON the first question is row 1, and for text formats the row
+// is the line the question starts on.
+type ImportRowError struct {
+	Row    int      `json:"row"`
+	Item   string   `json:"item,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// ImportWarning notes something in an uploaded file that was skipped or
+// could not be represented, without stopping the upload.
+type ImportWarning struct {
+	Row     int    `json:"row,omitempty"`
+	Item    string `json:"item,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult is what a decoder read from an uploaded file. Unparsed counts
+// the questions with errors that were too broken to be read at all; they
+// are not in Questions.
+type ImportResult struct {
+	Questions []models.QuestionUnmarshal
+	Errors    []ImportRowError
+	Warnings  []ImportWarning
+	Unparsed  int
+}
+
+// Total is the number of questions found in the file, valid or not.
+func (result ImportResult) Total() int {
+	return len(result.Questions) + result.Unparsed
+}
+
+// ImportOptions carries the request options that apply to an import.
+type ImportOptions struct {
+	// Mapping maps a question field to the spreadsheet column(s) holding it.
+	// List fields may name several columns separated by commas.
+	Mapping map[string]string
+
+	// MaxBytes is the most that is read from one file, including each file
+	// inside a content package. Zero means ImportMaxBytes.
+	MaxBytes int64
+}
+
+const DefaultImportMaxBytes = 20 << 20
+
+// ImportMaxBytes returns the largest question file accepted for upload.
+func ImportMaxBytes() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("IMPORT_MAX_BYTES"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return DefaultImportMaxBytes
+}
+
+func (options ImportOptions) maxBytes() int64 {
+	if options.MaxBytes > 0 {
+		return options.MaxBytes
+	}
+	return ImportMaxBytes()
+}
+
+// QuestionDecoder turns an uploaded file into questions. Row errors in the
+// result are problems with individual questions; the returned error means the
+// file as a whole could not be read.
+type QuestionDecoder func(content []byte, options ImportOptions) (ImportResult, error)
+
+var questionDecoders = map[string]QuestionDecoder{
+	ImportFormatJSON:  decodeJSONQuestions,
+	ImportFormatCSV:   decodeCSVQuestions,
+	ImportFormatXLSX:  decodeXLSXQuestions,
+	ImportFormatQTI:   decodeQTIQuestions,
+	ImportFormatGIFT:  decodeGIFTQuestions,
+	ImportFormatAiken: decodeAikenQuestions,
+	importFormatText:  decodeTextQuestions,
+}
+
+// importExtensions maps file extensions that differ from the format name.
+var importExtensions = map[string]string{
+	"xml": ImportFormatQTI,
+	"zip": ImportFormatQTI,
+	"txt": importFormatText,
+}
+
+// importFormatText is used for .txt uploads without an explicit format, which
+// may hold either GIFT or Aiken questions.
+const importFormatText = "text"
+
+var aikenAnswerLine = regexp.MustCompile(`(?m)^\s*ANSWER:`)
+
+func decodeTextQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	if aikenAnswerLine.Match(content) {
+		return decodeAikenQuestions(content, options)
+	}
+	return decodeGIFTQuestions(content, options)
+}
+
+// RegisterQuestionDecoder makes an upload format available under name.
+func RegisterQuestionDecoder(name string, decoder QuestionDecoder) {
+	questionDecoders[name] = decoder
+}
+
+func IsImportFormat(name string) bool {
+	_, ok := questionDecoders[name]
+	return ok
+}
+
+// DetectImportFormat picks the upload format from an explicit format value
+// or, failing that, the file extension. Unknown extensions are read as JSON.
+func DetectImportFormat(format, filename string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "" {
+		return format
+	}
+	extension := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if format, ok := importExtensions[extension]; ok {
+		return format
+	}
+	if IsImportFormat(extension) {
+		return extension
+	}
+	return ImportFormatJSON
+}
+
+// DecodeQuestions reads an uploaded file in the given format and validates
+// every question in it.
+func DecodeQuestions(format string, content []byte, options ImportOptions) (ImportResult, error) {
+	decoder, ok := questionDecoders[format]
+	if !ok {
+		return ImportResult{}, fmt.Errorf("unsupported format %q", format)
+	}
+	result, err := decoder(content, options)
+	if err != nil {
+		return result, err
+	}
+	if result.Errors == nil {
+		result.Errors = make([]ImportRowError, 0)
+	}
+	if result.Warnings == nil {
+		result.Warnings = make([]ImportWarning, 0)
+	}
+	return result, nil
+}
+
+// ErrEmptyImport is returned when an uploaded file holds no questions.
+var ErrEmptyImport = errors.New("file does not contain any questions")
+
+func decodeJSONQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	var questions []models.QuestionUnmarshal
+	if err := json.Unmarshal(content, &questions); err != nil {
+		return ImportResult{}, errors.New("invalid JSON file. Please upload valid JSON")
+	}
+	if len(questions) == 0 {
+		return ImportResult{}, ErrEmptyImport
+	}
+
+	rowErrors := make([]ImportRowError, 0)
+	for i := range questions {
+		if errs := ValidateQuestion(&questions[i]); len(errs) != 0 {
+			rowErrors = append(rowErrors, ImportRowError{Row: i + 1, Errors: errs})
+		}
+	}
+	return ImportResult{Questions: questions, Errors: rowErrors}, nil
+}
+
+func decodeCSVQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("invalid CSV file: %v", err)
+	}
+	return ParseQuestionTable(rows, options.Mapping)
+}
+
+func decodeXLSXQuestions(content []byte, options ImportOptions) (ImportResult, error) {
+	workbook, err := excelize.OpenReader(bytes.NewReader(content))
+	if err != nil {
+		return ImportResult{}, errors.New("invalid XLSX file. Please upload a valid spreadsheet")
+	}
+	defer workbook.Close()
+
+	sheets := workbook.GetSheetList()
+	if len(sheets) == 0 {
+		return ImportResult{}, ErrEmptyImport
+	}
+	rows, err := workbook.GetRows(sheets[0])
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("unable to read sheet %q: %v", sheets[0], err)
+	}
+	return ParseQuestionTable(rows, options.Mapping)
+}
+
+// DefaultColumnMapping is used for fields the upload does not map
+// explicitly. Column names are matched case-insensitively.
+var DefaultColumnMapping = map[string]string{
+	"question":        "question",
+	"format":          "format",
+	"type":            "type",
+	"points":          "points",
+	"options":         "options",
+	"correct_answer":  "correct_answer",
+	"correct_answers": "correct_answers",
+	"distractors":     "distractors",
+	"tags":            "tags",
+	"difficulty":      "difficulty",
+	"explanation":     "explanation",
+	"template":        "template",
+}
+
+// ParseQuestionTable turns spreadsheet rows into questions. The first row
+// holds the column names; empty rows are skipped.
+func ParseQuestionTable(rows [][]string, mapping map[string]string) (ImportResult, error) {
+	if len(rows) < 2 {
+		return ImportResult{}, ErrEmptyImport
+	}
+
+	header := make(map[string]int)
+	for i, name := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make(map[string][]int)
+	for field, defaultColumns := range DefaultColumnMapping {
+		names, mapped := mapping[field]
+		if !mapped {
+			names = defaultColumns
+		}
+		for _, name := range strings.Split(names, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			index, ok := header[name]
+			if !ok {
+				if mapped {
+					return ImportResult{}, fmt.Errorf("column %q mapped to %s is not in the header row", name, field)
+				}
+				continue
+			}
+			columns[field] = append(columns[field], index)
+		}
+	}
+	for field := range mapping {
+		if _, ok := DefaultColumnMapping[field]; !ok {
+			return ImportResult{}, fmt.Errorf("%q is not a question field that can be mapped", field)
+		}
+	}
+	if len(columns["question"]) == 0 || len(columns["options"]) == 0 {
+		return ImportResult{}, errors.New("the header row needs question and options columns")
+	}
+
+	questions := make([]models.QuestionUnmarshal, 0, len(rows)-1)
+	rowErrors := make([]ImportRowError, 0)
+	for i, row := range rows[1:] {
+		if isBlankRow(row) {
+			continue
+		}
+
+		cell := func(field string) string {
+			values := make([]string, 0, 1)
+			for _, index := range columns[field] {
+				if index < len(row) && strings.TrimSpace(row[index]) != "" {
+					values = append(values, strings.TrimSpace(row[index]))
+				}
+			}
+			return strings.Join(values, listSeparator)
+		}
+
+		question := models.QuestionUnmarshal{
+			QuestionName:   cell("question"),
+			Format:         cell("format"),
+			Type:           cell("type"),
+			Options:        splitList(cell("options")),
+			CorrectAnswer:  cell("correct_answer"),
+			CorrectAnswers: splitList(cell("correct_answers")),
+			Distractors:    splitList(cell("distractors")),
+			Tags:           splitList(cell("tags")),
+			Difficulty:     cell("difficulty"),
+			Explanation:    cell("explanation"),
+		}
+
+		errs := make([]string, 0)
+		if points := cell("points"); points != "" {
+			value, err := strconv.ParseFloat(points, 64)
+			if err != nil {
+				errs = append(errs, "points should be a number")
+			}
+			question.Points = value
+		}
+		question.CorrectAnswer = resolveOptionLetter(question.CorrectAnswer, question.Options)
+		for j, answer := range question.CorrectAnswers {
+			question.CorrectAnswers[j] = resolveOptionLetter(answer, question.Options)
+		}
+		if len(question.CorrectAnswers) > 1 && question.Type == "" {
+			question.Type = models.QuestionTypeMultipleChoice
+		}
+		if template := cell("template"); template != "" {
+			if err := json.Unmarshal([]byte(template), &question.Template); err != nil {
+				errs = append(errs, "template should be valid JSON")
+			}
+		}
+		if len(question.Distractors) == 0 && question.Template == nil {
+			question.Distractors = defaultDistractors(question)
+		}
+
+		errs = append(errs, ValidateQuestion(&question)...)
+		if len(errs) != 0 {
+			rowErrors = append(rowErrors, ImportRowError{Row: i + 2, Errors: errs})
+		}
+		questions = append(questions, question)
+	}
+
+	if len(questions) == 0 {
+		return ImportResult{}, ErrEmptyImport
+	}
+	return ImportResult{Questions: questions, Errors: rowErrors}, nil
+}
+
+func isBlankRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	parts := strings.Split(value, listSeparator)
+	list := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// resolveOptionLetter lets spreadsheets give the answer as an option letter
+// ("B") instead of repeating the option text.
+func resolveOptionLetter(answer string, options []string) string {
+	if len(answer) != 1 {
+		return answer
+	}
+	for _, option := range options {
+		if strings.EqualFold(option, answer) {
+			return answer
+		}
+	}
+	index := int(strings.ToUpper(answer)[0]) - 'A'
+	if index >= 0 && index < len(options) {
+		return options[index]
+	}
+	return answer
+}
+
+// defaultDistractors returns the options that are not correct answers.
+func defaultDistractors(question models.QuestionUnmarshal) []string {
+	correct := make(map[string]bool)
+	correct[strings.ToLower(question.CorrectAnswer)] = true
+	for _, answer := range question.CorrectAnswers {
+		correct[strings.ToLower(answer)] = true
+	}
+	distractors := make([]string, 0, len(question.Options))
+	for _, option := range question.Options {
+		if !correct[strings.ToLower(option)] {
+			distractors = append(distractors, option)
+		}
+	}
+	return distractors
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+)
+
+func TestDecodeCSVQuestions(t *testing.T) {
+	content := []byte("Question,Options,Answer,Tags,Difficulty,Explanation\n" +
+		"What is the capital of India?,Mumbai|Kolkata|New Delhi,New Delhi,chapter-1|Capitals,Easy,Since 1931.\n" +
+		",,,,,\n" +
+		"Which river is the holiest?,Yamuna|Ganga,B,,,\n")
+
+	result, err := DecodeQuestions(ImportFormatCSV, content, ImportOptions{
+		Mapping: map[string]string{"correct_answer": "Answer"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	questions := result.Questions
+	assert.Len(t, questions, 2)
+
+	assert.Equal(t, []string{"Mumbai", "Kolkata", "New Delhi"}, questions[0].Options)
+	assert.Equal(t, []string{"Mumbai", "Kolkata"}, questions[0].Distractors)
+	assert.Equal(t, []string{"chapter-1", "capitals"}, questions[0].Tags)
+	assert.Equal(t, models.DifficultyEasy, questions[0].Difficulty)
+	assert.Equal(t, "Since 1931.", questions[0].Explanation)
+
+	// Test case: answers given as option letters are resolved
+	assert.Equal(t, "Ganga", questions[1].CorrectAnswer)
+}
+
+func TestDecodeCSVQuestionsRowErrors(t *testing.T) {
+	content := []byte("question,option a,option b,option c,correct_answer,points\n" +
+		"2 + 2?,3,4,5,4,1\n" +
+		"3 + 3?,6,,,7,two\n")
+
+	result, err := DecodeQuestions(ImportFormatCSV, content, ImportOptions{
+		Mapping: map[string]string{"options": "option a, option b, option c"},
+	})
+	assert.NoError(t, err)
+	questions, rowErrors := result.Questions, result.Errors
+	assert.Len(t, questions, 2)
+	assert.Equal(t, []string{"3", "4", "5"}, questions[0].Options)
+
+	// Test case: rows are numbered with the header as row 1
+	assert.Len(t, rowErrors, 1)
+	assert.Equal(t, 3, rowErrors[0].Row)
+	assert.Contains(t, rowErrors[0].Errors, "points should be a number")
+	assert.Contains(t, rowErrors[0].Errors, "options should contain at least two choices")
+	assert.Contains(t, rowErrors[0].Errors, "correct_answer should be one of the options")
+
+	// Test case: mapping a column that does not exist
+	_, err = DecodeQuestions(ImportFormatCSV, content, ImportOptions{
+		Mapping: map[string]string{"options": "choices"},
+	})
+	assert.Error(t, err)
+}
+
+func TestDecodeXLSXQuestions(t *testing.T) {
+	workbook := excelize.NewFile()
+	rows := [][]interface{}{
+		{"question", "options", "correct_answers", "points"},
+		{"Which are primary colours?", "Red|Green|Blue|Purple", "Red|Blue", 2},
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		assert.NoError(t, workbook.SetSheetRow("Sheet1", cell, &row))
+	}
+	var buffer bytes.Buffer
+	assert.NoError(t, workbook.Write(&buffer))
+
+	result, err := DecodeQuestions(ImportFormatXLSX, buffer.Bytes(), ImportOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	questions := result.Questions
+	assert.Len(t, questions, 1)
+	assert.Equal(t, models.QuestionTypeMultipleChoice, questions[0].Type)
+	assert.Equal(t, []string{"Red", "Blue"}, questions[0].CorrectAnswers)
+	assert.Equal(t, []string{"Green", "Purple"}, questions[0].Distractors)
+	assert.Equal(t, 2.0, questions[0].Points)
+}
+
+func TestDetectImportFormat(t *testing.T) {
+	assert.Equal(t, ImportFormatCSV, DetectImportFormat("", "bank.CSV"))
+	assert.Equal(t, ImportFormatXLSX, DetectImportFormat("", "bank.xlsx"))
+	assert.Equal(t, ImportFormatJSON, DetectImportFormat("", "upload.json"))
+	assert.Equal(t, ImportFormatCSV, DetectImportFormat("CSV", "bank.txt"))
+}
@@ -1 +1,346 @@
 package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrRevisionConflict is returned when a question was changed by someone else
+// since the revision the edit was based on.
+var ErrRevisionConflict = errors.New("question was modified by another edit")
+
+func GetQuestionById(ctx context.Context, id primitive.ObjectID) (models.QuestionUnmarshal, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	var question models.QuestionUnmarshal
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&question)
+	return question, err
+}
+
+// DiffQuestions lists the fields, by their upload name, that differ between
+// two versions of a question.
+func DiffQuestions(before, after models.QuestionUnmarshal) []string {
+	changes := make([]string, 0)
+	compare := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, name)
+		}
+	}
+	compare("topic", before.Topic, after.Topic)
+	compare("question", before.QuestionName, after.QuestionName)
+	compare("format", before.Format, after.Format)
+	compare("type", before.Type, after.Type)
+	compare("points", before.Points, after.Points)
+	compare("options", before.Options, after.Options)
+	compare("correct_answer", before.CorrectAnswer, after.CorrectAnswer)
+	compare("correct_answers", before.CorrectAnswers, after.CorrectAnswers)
+	compare("distractors", before.Distractors, after.Distractors)
+	compare("explanation", before.Explanation, after.Explanation)
+	compare("option_feedback", before.OptionFeedback, after.OptionFeedback)
+	compare("irt", before.IRT, after.IRT)
+	compare("tags", before.Tags, after.Tags)
+	compare("difficulty", before.Difficulty, after.Difficulty)
+	compare("media", before.Media, after.Media)
+	compare("template", before.Template, after.Template)
+	compare("translations", before.Translations, after.Translations)
+	return changes
+}
+
+// AnswerKeyChanged reports whether an edit affects how responses are graded.
+func AnswerKeyChanged(changes []string) bool {
+	for _, change := range changes {
+		switch change {
+		case "correct_answer", "correct_answers", "template":
+			return true
+		}
+	}
+	return false
+}
+
+// RecordQuestionRevision stores a snapshot of a question in its history.
+func RecordQuestionRevision(ctx context.Context, question models.QuestionUnmarshal, changes []string, comment, changedBy string, changedAt time.Time) error {
+	client := GetConnection()
+	collection := GetCollection(client, "question_revisions")
+	_, err := collection.InsertOne(ctx, models.QuestionRevision{
+		QuestionId: question.ID,
+		Revision:   question.Revision,
+		Snapshot:   question,
+		Changes:    changes,
+		Comment:    comment,
+		ChangedBy:  changedBy,
+		ChangedAt:  changedAt,
+	})
+	return err
+}
+
+// UpdateQuestion saves an edited question as a new revision. The edit only
+// applies if the stored question is still at the revision it was based on.
+// Questions uploaded before versioning get their original state recorded as
+// revision one first.
+func UpdateQuestion(ctx context.Context, current, updated models.QuestionUnmarshal, comment, changedBy string) (models.QuestionUnmarshal, []string, error) {
+	changes := DiffQuestions(current, updated)
+	if len(changes) == 0 {
+		return current, changes, nil
+	}
+
+	now := time.Now()
+	basedOn := current.Revision
+	if current.Revision == 0 {
+		current.Revision = 1
+		if err := RecordQuestionRevision(ctx, current, []string{}, "Original upload", changedBy, now); err != nil {
+			return current, nil, err
+		}
+	}
+
+	updated.ID = current.ID
+	updated.Revision = current.Revision + 1
+	updated.UpdatedBy = changedBy
+	updated.UpdatedAt = &now
+	updated.Status = current.Status
+	updated.CreatedBy = current.CreatedBy
+	updated.ReviewLog = current.ReviewLog
+	// Editing a rejected question moves it back to draft for another review.
+	if to, ok := NextQuestionStatus(QuestionStatus(current), WorkflowEdit); ok {
+		updated.Status = to
+		updated.ReviewLog = append(updated.ReviewLog, models.ReviewEvent{
+			Action:  WorkflowEdit,
+			From:    current.Status,
+			To:      to,
+			By:      changedBy,
+			Comment: comment,
+			At:      now,
+		})
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	res, err := collection.ReplaceOne(ctx, bson.M{
+		"_id":      current.ID,
+		"revision": basedOn,
+	}, updated)
+	if err != nil {
+		return current, nil, err
+	}
+	if res.MatchedCount == 0 {
+		return current, nil, ErrRevisionConflict
+	}
+
+	if err = RecordQuestionRevision(ctx, updated, changes, comment, changedBy, now); err != nil {
+		return updated, changes, err
+	}
+	return updated, changes, nil
+}
+
+func GetQuestionRevisions(ctx context.Context, questionId primitive.ObjectID) ([]models.QuestionRevision, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "question_revisions")
+	cursor, err := collection.Find(ctx, bson.M{"question_id": questionId}, options.Find().SetSort(bson.M{"revision": 1}))
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]models.QuestionRevision, 0)
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func GetQuestionRevision(ctx context.Context, questionId primitive.ObjectID, revision int) (models.QuestionRevision, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "question_revisions")
+	var questionRevision models.QuestionRevision
+	err := collection.FindOne(ctx, bson.M{"question_id": questionId, "revision": revision}).Decode(&questionRevision)
+	return questionRevision, err
+}
+
+// RecordUploadedRevisions stores revision one of freshly uploaded questions.
+func RecordUploadedRevisions(ctx context.Context, questions []models.QuestionUnmarshal, changedBy string, changedAt time.Time) error {
+	if len(questions) == 0 {
+		return nil
+	}
+	revisions := make([]interface{}, 0, len(questions))
+	for _, question := range questions {
+		revisions = append(revisions, models.QuestionRevision{
+			QuestionId: question.ID,
+			Revision:   question.Revision,
+			Snapshot:   question,
+			Changes:    []string{},
+			Comment:    "Uploaded",
+			ChangedBy:  changedBy,
+			ChangedAt:  changedAt,
+		})
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "question_revisions")
+	_, err := collection.InsertMany(ctx, revisions)
+	return err
+}
+
+// ValidateQuestion normalizes a question in place and returns the problems
+// that would stop it from being graded.
+func ValidateQuestion(question *models.QuestionUnmarshal) []string {
+	errorStrings := make([]string, 0)
+
+	question.QuestionName = strings.TrimSpace(question.QuestionName)
+	question.Type = strings.ToLower(strings.TrimSpace(question.Type))
+	question.Difficulty = strings.ToLower(strings.TrimSpace(question.Difficulty))
+	question.Tags = NormalizeTags(question.Tags)
+	question.Format = strings.ToLower(strings.TrimSpace(question.Format))
+	if question.Format == models.ContentFormatPlain {
+		question.Format = ""
+	}
+
+	if question.Format != "" && !IsContentFormat(question.Format) {
+		errorStrings = append(errorStrings, "format should be plain, markdown or markdown_math")
+	} else {
+		errorStrings = append(errorStrings, sanitizeQuestionContent(question)...)
+	}
+
+	if question.QuestionName == "" {
+		errorStrings = append(errorStrings, "question should not be empty")
+	}
+
+	options := make(map[string]bool)
+	if question.Template != nil {
+		errorStrings = append(errorStrings, ValidateTemplate(question)...)
+	} else {
+		errorStrings = append(errorStrings, validateAnswerKey(question, options)...)
+	}
+
+	if question.Points < 0 {
+		errorStrings = append(errorStrings, "points should not be negative")
+	}
+	if question.Difficulty != "" && !models.IsDifficulty(question.Difficulty) {
+		errorStrings = append(errorStrings, "difficulty should be one of easy, medium or hard")
+	}
+	for _, reference := range question.Media {
+		if reference.MediaId.IsZero() {
+			errorStrings = append(errorStrings, "media should have a media_id")
+		}
+		if reference.Option != "" && !options[strings.ToLower(strings.TrimSpace(reference.Option))] {
+			errorStrings = append(errorStrings, fmt.Sprintf("media option %q should be one of the options", reference.Option))
+		}
+	}
+	errorStrings = append(errorStrings, validateTranslations(question)...)
+
+	return errorStrings
+}
+
+// validateAnswerKey checks the options and correct answers of a question,
+// collecting the normalized options into options.
+func validateAnswerKey(question *models.QuestionUnmarshal, options map[string]bool) []string {
+	errorStrings := make([]string, 0)
+	if len(question.Options) < 2 {
+		errorStrings = append(errorStrings, "options should contain at least two choices")
+	}
+
+	for _, option := range question.Options {
+		key := strings.ToLower(strings.TrimSpace(option))
+		if key == "" {
+			errorStrings = append(errorStrings, "options should not contain empty values")
+			continue
+		}
+		if options[key] {
+			errorStrings = append(errorStrings, fmt.Sprintf("option %q is repeated", option))
+		}
+		options[key] = true
+	}
+
+	switch question.Type {
+	case "", models.QuestionTypeSingleChoice:
+		if question.CorrectAnswer == "" {
+			errorStrings = append(errorStrings, "correct_answer should not be empty")
+		} else if !options[strings.ToLower(strings.TrimSpace(question.CorrectAnswer))] {
+			errorStrings = append(errorStrings, "correct_answer should be one of the options")
+		}
+	case models.QuestionTypeMultipleChoice:
+		if len(question.CorrectAnswers) == 0 {
+			errorStrings = append(errorStrings, "correct_answers should not be empty for multiple choice questions")
+		}
+		for _, answer := range question.CorrectAnswers {
+			if !options[strings.ToLower(strings.TrimSpace(answer))] {
+				errorStrings = append(errorStrings, fmt.Sprintf("correct answer %q should be one of the options", answer))
+			}
+		}
+	default:
+		errorStrings = append(errorStrings, "type should be single_choice or multiple_choice")
+	}
+	return errorStrings
+}
+
+// sanitizeQuestionContent sanitizes every text of a question the same way,
+// so that answers still match the options they were copied from.
+func sanitizeQuestionContent(question *models.QuestionUnmarshal) []string {
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	sanitize := func(content string) string {
+		sanitized, found := SanitizeContent(question.Format, content)
+		for _, problem := range found {
+			if !seen[problem] {
+				seen[problem] = true
+				problems = append(problems, problem)
+			}
+		}
+		return sanitized
+	}
+	sanitizeAll := func(values []string) {
+		for i := range values {
+			values[i] = sanitize(values[i])
+		}
+	}
+
+	question.QuestionName = strings.TrimSpace(sanitize(question.QuestionName))
+	question.CorrectAnswer = sanitize(question.CorrectAnswer)
+	question.Explanation = sanitize(question.Explanation)
+	sanitizeAll(question.Options)
+	sanitizeAll(question.CorrectAnswers)
+	sanitizeAll(question.Distractors)
+	if len(question.OptionFeedback) != 0 {
+		feedback := make(map[string]string, len(question.OptionFeedback))
+		for option, text := range question.OptionFeedback {
+			feedback[sanitize(option)] = sanitize(text)
+		}
+		question.OptionFeedback = feedback
+	}
+	for i := range question.Media {
+		question.Media[i].Option = sanitize(question.Media[i].Option)
+	}
+	for locale, translation := range question.Translations {
+		translation.Question = sanitize(translation.Question)
+		translation.Explanation = sanitize(translation.Explanation)
+		sanitizeAll(translation.Options)
+		if len(translation.OptionFeedback) != 0 {
+			feedback := make(map[string]string, len(translation.OptionFeedback))
+			for option, text := range translation.OptionFeedback {
+				feedback[sanitize(option)] = sanitize(text)
+			}
+			translation.OptionFeedback = feedback
+		}
+		question.Translations[locale] = translation
+	}
+	return problems
+}
+
+// NormalizeTags trims and lowercases tags and drops empty or repeated ones.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
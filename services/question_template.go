@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// templateDraws is how often a quiz draws new values when a draw gives
+	// an answer that can't be computed or equals a distractor.
+	templateDraws = 50
+
+	maxTemplateDecimals = 10
+	maxTemplateValues   = 1000000
+)
+
+var (
+	templateVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	templatePlaceholder  = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+)
+
+type compiledTemplate struct {
+	answer      *Formula
+	distractors []*Formula
+	decimals    int
+}
+
+func templateVariableNames(template *models.QuestionTemplate) []string {
+	names := make([]string, 0, len(template.Variables))
+	for _, variable := range template.Variables {
+		names = append(names, variable.Name)
+	}
+	return names
+}
+
+func compileTemplate(template *models.QuestionTemplate) (compiledTemplate, []string) {
+	problems := make([]string, 0)
+	names := templateVariableNames(template)
+	compiled := compiledTemplate{decimals: template.AnswerDecimals()}
+
+	answer, err := ParseFormula(template.Answer, names)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("template answer %q: %v", template.Answer, err))
+	}
+	compiled.answer = answer
+
+	for _, source := range template.Distractors {
+		distractor, err := ParseFormula(source, names)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("template distractor %q: %v", source, err))
+			continue
+		}
+		compiled.distractors = append(compiled.distractors, distractor)
+	}
+	return compiled, problems
+}
+
+// ValidateTemplate checks the variables and formulas of a template question
+// and draws one set of values to make sure a quiz can use it. Options and
+// answers are computed for every quiz, so any given with a template are
+// dropped.
+func ValidateTemplate(question *models.QuestionUnmarshal) []string {
+	template := question.Template
+	problems := make([]string, 0)
+
+	question.Options, question.CorrectAnswer, question.CorrectAnswers, question.Distractors = nil, "", nil, nil
+	question.OptionFeedback = nil
+
+	if question.Type != "" && question.Type != models.QuestionTypeSingleChoice {
+		problems = append(problems, "template questions should be single_choice")
+	}
+	if len(template.Variables) == 0 {
+		problems = append(problems, "template should declare at least one variable")
+	}
+	seen := make(map[string]bool)
+	for _, variable := range template.Variables {
+		switch {
+		case !templateVariableName.MatchString(variable.Name):
+			problems = append(problems, fmt.Sprintf("template variable %q should be a letter followed by letters, digits or underscores", variable.Name))
+		case seen[variable.Name]:
+			problems = append(problems, fmt.Sprintf("template variable %q is declared twice", variable.Name))
+		}
+		seen[variable.Name] = true
+		if variable.Min > variable.Max {
+			problems = append(problems, fmt.Sprintf("template variable %q should have min at most max", variable.Name))
+		}
+		if variable.Step < 0 {
+			problems = append(problems, fmt.Sprintf("template variable %q should have a positive step", variable.Name))
+		} else if (variable.Max-variable.Min)/variable.VariableStep() > maxTemplateValues {
+			problems = append(problems, fmt.Sprintf("template variable %q should have at most %d values", variable.Name, maxTemplateValues))
+		}
+	}
+	if len(template.Distractors) == 0 {
+		problems = append(problems, "template should have at least one distractor formula")
+	}
+	if decimals := template.AnswerDecimals(); decimals < 0 || decimals > maxTemplateDecimals {
+		problems = append(problems, fmt.Sprintf("template decimals should be between 0 and %d", maxTemplateDecimals))
+	}
+	for _, reference := range question.Media {
+		if reference.Option != "" {
+			problems = append(problems, "template questions can't attach media to options")
+			break
+		}
+	}
+
+	_, formulaProblems := compileTemplate(template)
+	problems = append(problems, formulaProblems...)
+	if len(problems) != 0 {
+		return problems
+	}
+
+	sample := models.Question{QuestionName: question.QuestionName, Template: template}
+	if err := InstantiateQuestion(&sample, rand.New(rand.NewSource(1))); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// InstantiateQuestion draws values for a template question and turns it
+// into the concrete question one student sees: placeholders are filled in
+// and the options are the computed answer and distractors in random order.
+// The values are kept on the question so the quiz can be graded and
+// re-graded against them. Questions without a template, or that already
+// have values, are left as they are.
+func InstantiateQuestion(question *models.Question, random *rand.Rand) error {
+	template := question.Template
+	if template == nil || question.Variables != nil {
+		return nil
+	}
+	compiled, problems := compileTemplate(template)
+	if len(problems) != 0 {
+		return fmt.Errorf("question template is invalid: %s", strings.Join(problems, "; "))
+	}
+
+	for draw := 0; draw < templateDraws; draw++ {
+		values := drawTemplateValues(template.Variables, random)
+		answer, distractors, ok := evaluateTemplate(compiled, values)
+		if !ok {
+			continue
+		}
+
+		options := append([]string{answer}, distractors...)
+		random.Shuffle(len(options), func(i, j int) {
+			options[i], options[j] = options[j], options[i]
+		})
+
+		question.Variables = values
+		question.QuestionName = FillTemplate(question.QuestionName, values)
+		question.Explanation = FillTemplate(question.Explanation, values)
+		question.Type = models.QuestionTypeSingleChoice
+		question.Options = options
+		question.CorrectAnswer = answer
+		question.CorrectAnswers = nil
+		question.Distractors = distractors
+		question.OptionFeedback = nil
+		return nil
+	}
+	return fmt.Errorf("template values could not be drawn so that the answer can be computed and differs from every distractor (%d tries)", templateDraws)
+}
+
+// InstantiateQuestions instantiates every template question of a new quiz.
+func InstantiateQuestions(questions []models.Question, random *rand.Rand) error {
+	for i := range questions {
+		if err := InstantiateQuestion(&questions[i], random); err != nil {
+			return fmt.Errorf("question %s: %v", questions[i].ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// TemplateAnswer computes the answer of a template for values that were
+// drawn earlier, e.g. to re-grade a quiz after the formula was fixed.
+func TemplateAnswer(template *models.QuestionTemplate, values map[string]float64) (string, error) {
+	answer, err := ParseFormula(template.Answer, templateVariableNames(template))
+	if err != nil {
+		return "", err
+	}
+	value, err := answer.Evaluate(values)
+	if err != nil {
+		return "", err
+	}
+	return FormatTemplateNumber(value, template.AnswerDecimals()), nil
+}
+
+func drawTemplateValues(variables []models.TemplateVariable, random *rand.Rand) map[string]float64 {
+	values := make(map[string]float64, len(variables))
+	for _, variable := range variables {
+		step := variable.VariableStep()
+		count := int64(math.Floor((variable.Max-variable.Min)/step+1e-9)) + 1
+		if count < 1 {
+			count = 1
+		}
+		value := variable.Min + float64(random.Int63n(count))*step
+		values[variable.Name] = math.Round(value*1e9) / 1e9
+	}
+	return values
+}
+
+// evaluateTemplate computes the options for one set of values. It fails when
+// a formula can't be computed or two options would read the same.
+func evaluateTemplate(compiled compiledTemplate, values map[string]float64) (string, []string, bool) {
+	value, err := compiled.answer.Evaluate(values)
+	if err != nil {
+		return "", nil, false
+	}
+	answer := FormatTemplateNumber(value, compiled.decimals)
+	seen := map[string]bool{answer: true}
+
+	distractors := make([]string, 0, len(compiled.distractors))
+	for _, formula := range compiled.distractors {
+		value, err := formula.Evaluate(values)
+		if err != nil {
+			return "", nil, false
+		}
+		distractor := FormatTemplateNumber(value, compiled.decimals)
+		if seen[distractor] {
+			return "", nil, false
+		}
+		seen[distractor] = true
+		distractors = append(distractors, distractor)
+	}
+	return answer, distractors, true
+}
+
+// FillTemplate replaces {name} placeholders of declared variables with their
+// values. Other braces, such as those of LaTeX formulas, are left alone.
+func FillTemplate(text string, values map[string]float64) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := values[placeholder[1:len(placeholder)-1]]
+		if !ok {
+			return placeholder
+		}
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	})
+}
+
+// FormatTemplateNumber rounds a computed value to the given number of
+// decimal places and drops trailing zeros.
+func FormatTemplateNumber(value float64, decimals int) string {
+	scale := math.Pow(10, float64(decimals))
+	rounded := math.Round(value*scale) / scale
+	if rounded == 0 {
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestParseFormula(t *testing.T) {
+	values := map[string]float64{"a": 3, "b": 4}
+	evaluate := func(source string) float64 {
+		formula, err := ParseFormula(source, []string{"a", "b"})
+		if !assert.NoError(t, err, source) {
+			return 0
+		}
+		value, err := formula.Evaluate(values)
+		assert.NoError(t, err, source)
+		return value
+	}
+
+	// Test case: Precedence and associativity
+	assert.Equal(t, 11.0, evaluate("a + 2 * b"))
+	assert.Equal(t, 28.0, evaluate("(a + b) * b"))
+	assert.Equal(t, -9.0, evaluate("-a^2"))
+	assert.Equal(t, 512.0, evaluate("2^3^2"))
+	assert.Equal(t, 1.0, evaluate("b % a"))
+	assert.Equal(t, 2.0, evaluate("10 - a - 5"))
+
+	// Test case: Functions and constants
+	assert.Equal(t, 5.0, evaluate("sqrt(a^2 + b^2)"))
+	assert.Equal(t, 4.0, evaluate("max(a, b)"))
+	assert.InDelta(t, 28.27, evaluate("pi * a^2"), 0.01)
+
+	// Test case: Invalid formulas
+	for _, source := range []string{"a +", "(a + b", "c * 2", "foo(a)", "max(a)", "a b", "1.2.3"} {
+		_, err := ParseFormula(source, []string{"a", "b"})
+		assert.Error(t, err, source)
+	}
+
+	// Test case: Errors while evaluating
+	formula, _ := ParseFormula("a / (b - 4)", []string{"a", "b"})
+	_, err := formula.Evaluate(values)
+	assert.ErrorIs(t, err, ErrDivisionByZero)
+	formula, _ = ParseFormula("sqrt(a - b)", []string{"a", "b"})
+	_, err = formula.Evaluate(values)
+	assert.Error(t, err)
+}
+
+func additionTemplate() *models.QuestionTemplate {
+	return &models.QuestionTemplate{
+		Variables: []models.TemplateVariable{
+			{Name: "a", Min: 1, Max: 9},
+			{Name: "b", Min: 1, Max: 9},
+		},
+		Answer:      "a + b",
+		Distractors: []string{"a * b", "a - b", "a + b + 1"},
+	}
+}
+
+func TestInstantiateQuestion(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+
+	// Test case: Values fill the text and the answer is computed from them
+	for i := 0; i < 20; i++ {
+		question := models.Question{QuestionName: "What is {a} + {b}? Keep $x^{2}$ and {c}.", Template: additionTemplate()}
+		assert.NoError(t, InstantiateQuestion(&question, random))
+
+		a, b := question.Variables["a"], question.Variables["b"]
+		assert.True(t, a >= 1 && a <= 9 && b >= 1 && b <= 9)
+		assert.Equal(t, "What is "+FormatTemplateNumber(a, 0)+" + "+FormatTemplateNumber(b, 0)+"? Keep $x^{2}$ and {c}.", question.QuestionName)
+		assert.Equal(t, FormatTemplateNumber(a+b, 0), question.CorrectAnswer)
+		assert.Len(t, question.Options, 4)
+		assert.Contains(t, question.Options, question.CorrectAnswer)
+
+		// Test case: Options are always distinct
+		sorted := slices.Clone(question.Options)
+		slices.Sort(sorted)
+		assert.Len(t, slices.Compact(sorted), 4)
+	}
+
+	// Test case: Instantiated questions keep their values
+	question := models.Question{QuestionName: "{a}", Template: additionTemplate(), Variables: map[string]float64{"a": 1, "b": 2}}
+	assert.NoError(t, InstantiateQuestion(&question, random))
+	assert.Equal(t, "{a}", question.QuestionName)
+
+	// Test case: Steps and decimals
+	decimals := 1
+	question = models.Question{
+		QuestionName: "{x}",
+		Template: &models.QuestionTemplate{
+			Variables:   []models.TemplateVariable{{Name: "x", Min: 0.5, Max: 1.5, Step: 0.25}},
+			Answer:      "x / 3",
+			Distractors: []string{"x * 3"},
+			Decimals:    &decimals,
+		},
+	}
+	assert.NoError(t, InstantiateQuestion(&question, random))
+	x := question.Variables["x"]
+	assert.Contains(t, []float64{0.5, 0.75, 1, 1.25, 1.5}, x)
+	assert.Equal(t, strconv.FormatFloat(x, 'f', -1, 64), question.QuestionName)
+	assert.Equal(t, FormatTemplateNumber(x/3, 1), question.CorrectAnswer)
+
+	// Test case: Templates whose options always collide can't be used
+	question = models.Question{
+		Template: &models.QuestionTemplate{
+			Variables:   []models.TemplateVariable{{Name: "a", Min: 2, Max: 2}},
+			Answer:      "a + a",
+			Distractors: []string{"a * a"},
+		},
+	}
+	assert.Error(t, InstantiateQuestion(&question, random))
+}
+
+func TestValidateTemplate(t *testing.T) {
+	// Test case: Valid template questions need no options
+	question := models.QuestionUnmarshal{
+		QuestionName: "What is {a} + {b}?",
+		Options:      []string{"ignored"},
+		Template:     additionTemplate(),
+	}
+	assert.Empty(t, ValidateQuestion(&question))
+	assert.Nil(t, question.Options)
+
+	// Test case: Formula and variable problems are reported
+	question.Template = &models.QuestionTemplate{
+		Variables:   []models.TemplateVariable{{Name: "a", Min: 5, Max: 1}, {Name: "a"}, {Name: "2x"}},
+		Answer:      "a + c",
+		Distractors: []string{},
+	}
+	assert.ElementsMatch(t, []string{
+		`template variable "a" should have min at most max`,
+		`template variable "a" is declared twice`,
+		`template variable "2x" should be a letter followed by letters, digits or underscores`,
+		"template should have at least one distractor formula",
+		`template answer "a + c": unknown variable "c"`,
+	}, ValidateQuestion(&question))
+
+	// Test case: Template questions are single choice
+	question.Type = models.QuestionTypeMultipleChoice
+	question.Template = additionTemplate()
+	assert.Equal(t, []string{"template questions should be single_choice"}, ValidateQuestion(&question))
+}
+
+func TestTemplateAnswer(t *testing.T) {
+	// Test case: Regrading computes the answer from the stored values
+	answer, err := TemplateAnswer(additionTemplate(), map[string]float64{"a": 2, "b": 5})
+	assert.NoError(t, err)
+	assert.Equal(t, "7", answer)
+
+	// Test case: Negative zero and trailing zeros are dropped
+	assert.Equal(t, "0", FormatTemplateNumber(-0.001, 2))
+	assert.Equal(t, "2.5", FormatTemplateNumber(2.5, 3))
+}
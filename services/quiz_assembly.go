@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AssemblyError explains why the questions for a new attempt could not be
+// put together, usually because the question pool is too small.
+type AssemblyError struct {
+	Message string
+	Status  int
+	Details []string
+}
+
+func (e *AssemblyError) Error() string {
+	return e.Message
+}
+
+// AssembleQuestions picks the questions a new attempt starts with, puts them
+// in the student's language and draws the values of template questions for
+// it.
+func AssembleQuestions(ctx context.Context, assessment models.Assessment, locale string) ([]models.Question, error) {
+	questions, err := pickQuestions(ctx, assessment)
+	if err != nil {
+		return nil, err
+	}
+	LocalizeQuestions(questions, locale)
+	if err = InstantiateQuestions(questions, rand.New(rand.NewSource(time.Now().UnixNano()))); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// pickQuestions draws questions from the bank. Adaptive assessments start
+// with the single most informative calibrated question.
+func pickQuestions(ctx context.Context, assessment models.Assessment) ([]models.Question, error) {
+	if assessment.IsAdaptive() {
+		pool, err := CalibratedQuestions(ctx, assessment.Topic, nil)
+		if err != nil {
+			return nil, err
+		}
+		first := SelectNextItem(0, assessment.Adaptive.Model, pool)
+		if first < 0 {
+			return nil, &AssemblyError{
+				Message: "No calibrated questions found with this topic",
+				Status:  http.StatusUnprocessableEntity,
+			}
+		}
+		return []models.Question{pool[first]}, nil
+	}
+
+	if assessment.Blueprint != nil && len(assessment.Blueprint.Sections) != 0 {
+		return assembleFromBlueprint(ctx, assessment)
+	}
+
+	questions, err := SampleQuestions(ctx, assessment.Topic, assessment.NumQuestions)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(questions) == 0 {
+		return nil, &AssemblyError{
+			Message: "No questions found with this topic",
+			Status:  http.StatusNotFound,
+		}
+	}
+
+	if len(questions) < assessment.NumQuestions {
+		return nil, &AssemblyError{
+			Message: fmt.Sprintf("Assessment requires %d questions but only %d are available", assessment.NumQuestions, len(questions)),
+			Status:  http.StatusUnprocessableEntity,
+		}
+	}
+
+	return questions, nil
+}
+
+// assembleFromBlueprint draws the questions of each section in order, never
+// picking the same question twice. Every section that cannot be filled is
+// reported so authors can fix the pool in one go.
+func assembleFromBlueprint(ctx context.Context, assessment models.Assessment) ([]models.Question, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+
+	questions := make([]models.Question, 0, assessment.Blueprint.TotalQuestions())
+	chosen := make([]primitive.ObjectID, 0, assessment.Blueprint.TotalQuestions())
+	shortfalls := make([]string, 0)
+
+	for i, section := range assessment.Blueprint.Sections {
+		filter := BlueprintSectionFilter(section, assessment.Topic)
+		filter["status"] = PublishedStatus()
+		if len(chosen) != 0 {
+			filter["_id"] = bson.M{"$nin": chosen}
+		}
+
+		cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$sample", Value: bson.M{"size": section.Count}}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		sampled := make([]models.Question, 0, section.Count)
+		if err = cursor.All(ctx, &sampled); err != nil {
+			return nil, err
+		}
+
+		if len(sampled) < section.Count {
+			name := section.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			shortfalls = append(shortfalls, fmt.Sprintf("Section %s requires %d questions (%s) but only %d are available",
+				name, section.Count, describeBlueprintSection(section, assessment.Topic), len(sampled)))
+			continue
+		}
+
+		for _, question := range sampled {
+			questions = append(questions, question)
+			chosen = append(chosen, question.ID)
+		}
+	}
+
+	if len(shortfalls) != 0 {
+		return nil, &AssemblyError{
+			Message: "Question pool is too small for this assessment's blueprint",
+			Status:  http.StatusUnprocessableEntity,
+			Details: shortfalls,
+		}
+	}
+
+	return questions, nil
+}
+
+// BlueprintSectionFilter builds the question filter for a blueprint section.
+// Sections without a topic draw from the assessment's topic.
+func BlueprintSectionFilter(section models.BlueprintSection, defaultTopic string) bson.M {
+	filter := bson.M{}
+	topic := section.Topic
+	if topic == "" {
+		topic = defaultTopic
+	}
+	if topic != "" {
+		filter["topic"] = topic
+	}
+	if len(section.Tags) != 0 {
+		filter["tags"] = bson.M{"$all": section.Tags}
+	}
+	if section.Difficulty != "" {
+		filter["difficulty"] = section.Difficulty
+	}
+	return filter
+}
+
+func describeBlueprintSection(section models.BlueprintSection, defaultTopic string) string {
+	criteria := make([]string, 0, 3)
+	topic := section.Topic
+	if topic == "" {
+		topic = defaultTopic
+	}
+	if topic != "" {
+		criteria = append(criteria, "topic "+topic)
+	}
+	if len(section.Tags) != 0 {
+		criteria = append(criteria, "tags "+strings.Join(section.Tags, ", "))
+	}
+	if section.Difficulty != "" {
+		criteria = append(criteria, "difficulty "+section.Difficulty)
+	}
+	return strings.Join(criteria, "; ")
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestBlueprintSectionFilter(t *testing.T) {
+	section := models.BlueprintSection{Count: 5, Tags: []string{"chapter-2"}, Difficulty: models.DifficultyEasy}
+	assert.Equal(t, bson.M{
+		"topic":      "geography",
+		"tags":       bson.M{"$all": []string{"chapter-2"}},
+		"difficulty": "easy",
+	}, BlueprintSectionFilter(section, "geography"))
+
+	// Test case: section topic overrides the assessment topic
+	section = models.BlueprintSection{Count: 2, Topic: "history"}
+	assert.Equal(t, bson.M{"topic": "history"}, BlueprintSectionFilter(section, "geography"))
+
+	blueprint := models.Blueprint{Sections: []models.BlueprintSection{{Count: 5}, {Count: 3}, {Count: 2}}}
+	assert.Equal(t, 10, blueprint.TotalQuestions())
+}
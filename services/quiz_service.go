@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const DefaultQuizDurationMinutes = 30
+
+func GetQuizById(ctx context.Context, id primitive.ObjectID) (models.Quiz, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "quizzes")
+	var quiz models.Quiz
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&quiz)
+	return quiz, err
+}
+
+// FinalizeQuiz marks a quiz that ran out of time as completed and stores its
+// score the first time a finished quiz without one is seen.
+func FinalizeQuiz(ctx context.Context, quiz *models.Quiz, now time.Time) error {
+	if !quiz.Completed && !quiz.Paused && quiz.Remaining(now) <= 0 {
+		quiz.Completed = true
+		completedAt := AttemptFinishedAt(*quiz)
+		quiz.CompletedAt = &completedAt
+	}
+
+	if !quiz.Completed || quiz.Score != nil {
+		return nil
+	}
+
+	score := ScoreQuiz(*quiz, now)
+	quiz.Score = &score
+
+	client := GetConnection()
+	collection := GetCollection(client, "quizzes")
+	_, err := collection.UpdateByID(ctx, quiz.Id, bson.M{
+		"$set": bson.M{
+			"completed":    quiz.Completed,
+			"completed_at": quiz.CompletedAt,
+			"score":        quiz.Score,
+		},
+	})
+	return err
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+	"time"
+)
+
+// RegradeQuiz applies the given answer keys to the question snapshots of a
+// quiz and re-grades the affected responses. The stem and options the
+// student saw are left untouched. It returns how many responses changed
+// result or credit, whether anything in the quiz was updated, and the
+// questions whose new key couldn't be mapped to the options the student saw.
+func RegradeQuiz(quiz *models.Quiz, keys map[primitive.ObjectID]models.QuestionUnmarshal, now time.Time) (int, bool, []primitive.ObjectID) {
+	questions := make(map[primitive.ObjectID]models.Question)
+	skipped := make([]primitive.ObjectID, 0)
+	updated := false
+
+	for i, question := range quiz.Questions {
+		key, ok := keys[question.ID]
+		if !ok {
+			continue
+		}
+		if question.Variables != nil {
+			// Template questions are re-graded against the values the
+			// student was given. The options they saw were computed from
+			// the old formulas, so a new answer that isn't one of them
+			// can't be graded and the question is left as it was.
+			if key.Template == nil {
+				skipped = append(skipped, question.ID)
+				continue
+			}
+			answer, err := TemplateAnswer(key.Template, question.Variables)
+			if err != nil {
+				skipped = append(skipped, question.ID)
+				continue
+			}
+			position := optionPosition(question.Options, answer)
+			if position < 0 {
+				skipped = append(skipped, question.ID)
+				continue
+			}
+			question.Template = key.Template
+			question.CorrectAnswer = question.Options[position]
+		} else {
+			answer, answers, ok := snapshotAnswerKey(question, key)
+			if !ok {
+				skipped = append(skipped, question.ID)
+				continue
+			}
+			question.CorrectAnswer, question.CorrectAnswers = answer, answers
+		}
+		quiz.Questions[i] = question
+		questions[question.ID] = question
+		updated = true
+	}
+
+	changed := 0
+	for i, response := range quiz.UserResponses {
+		question, ok := questions[response.QuestionId]
+		if !ok {
+			continue
+		}
+
+		choices := response.Responses
+		if len(choices) == 0 {
+			choices = []string{response.Response}
+		}
+		result, credit := GradeResponse(question, choices)
+		if result != response.Result || credit != response.Credit {
+			changed++
+		}
+
+		response.Result, response.Credit = result, credit
+		response.AnswerKeyRevision = keys[question.ID].Revision
+		response.CorrectAnswer = strings.ToLower(question.CorrectAnswer)
+		if question.IsMultipleChoice() {
+			response.CorrectAnswer = strings.ToLower(strings.Join(question.CorrectAnswers, ", "))
+		}
+		quiz.UserResponses[i] = response
+	}
+
+	if changed > 0 {
+		if quiz.Score != nil {
+			score := ScoreQuiz(*quiz, now)
+			quiz.Score = &score
+		}
+		if quiz.Adaptive != nil {
+			estimate := EstimateAbility(AdaptiveResponses(*quiz))
+			quiz.Ability = &estimate
+		}
+	}
+
+	return changed, updated, skipped
+}
+
+// snapshotAnswerKey maps an answer key onto the options of a quiz snapshot.
+// Localized snapshots are matched against the translated answers. An answer
+// whose text the snapshot doesn't have is matched by its position among the
+// question's current options, so an option that was reworded still grades
+// the option the student saw. It reports false when the question changed
+// between single and multiple choice, or an answer has no option to map to.
+func snapshotAnswerKey(question models.Question, key models.QuestionUnmarshal) (string, []string, bool) {
+	if (key.Type == models.QuestionTypeMultipleChoice) != question.IsMultipleChoice() {
+		return "", nil, false
+	}
+	answer, answers := LocalizeAnswerKey(key, question.Locale)
+	if len(question.Options) == 0 {
+		return answer, answers, true
+	}
+
+	option := func(localized, original string) (string, bool) {
+		position := optionPosition(question.Options, localized)
+		if position < 0 && len(key.Options) == len(question.Options) {
+			position = optionPosition(key.Options, original)
+		}
+		if position < 0 {
+			return "", false
+		}
+		return question.Options[position], true
+	}
+
+	if !question.IsMultipleChoice() {
+		mapped, ok := option(answer, key.CorrectAnswer)
+		return mapped, nil, ok
+	}
+	if len(answers) == 0 {
+		return "", nil, false
+	}
+	mapped := make([]string, 0, len(answers))
+	for i, localized := range answers {
+		correct, ok := option(localized, key.CorrectAnswers[i])
+		if !ok {
+			return "", nil, false
+		}
+		mapped = append(mapped, correct)
+	}
+	return "", mapped, true
+}
+
+const regradeBatchSize = 500
+
+// RegradeRequest describes which answer keys to re-apply and who asked.
+type RegradeRequest struct {
+	Scope       string
+	Topic       string
+	Questions   []models.QuestionUnmarshal
+	Reason      string
+	RequestedBy string
+	Notify      bool
+}
+
+// Regrade re-grades every quiz containing one of the requested questions
+// against their current answer keys. The run is recorded in an audit with
+// the score of each affected quiz before and after, and students whose score
+// changed are notified when requested.
+func Regrade(ctx context.Context, request RegradeRequest) (models.RegradeAudit, error) {
+	client := GetConnection()
+	quizCollection := GetCollection(client, "quizzes")
+	auditCollection := GetCollection(client, "regrade_audits")
+	changeCollection := GetCollection(client, "regrade_changes")
+
+	keysById := make(map[primitive.ObjectID]models.QuestionUnmarshal)
+	ids := make([]primitive.ObjectID, 0, len(request.Questions))
+	for _, key := range request.Questions {
+		keysById[key.ID] = key
+		ids = append(ids, key.ID)
+	}
+
+	audit := models.RegradeAudit{
+		Scope:       request.Scope,
+		Topic:       request.Topic,
+		QuestionIds: ids,
+		Reason:      request.Reason,
+		RequestedBy: request.RequestedBy,
+		Notify:      request.Notify,
+		StartedAt:   time.Now(),
+	}
+	res, err := auditCollection.InsertOne(ctx, audit)
+	if err != nil {
+		return audit, err
+	}
+	audit.ID = res.InsertedID.(primitive.ObjectID)
+
+	err = regradeQuizzes(ctx, quizCollection, changeCollection, keysById, &audit)
+
+	finishedAt := time.Now()
+	audit.FinishedAt = &finishedAt
+	if err != nil {
+		audit.Error = err.Error()
+	}
+	if _, updateErr := auditCollection.ReplaceOne(ctx, bson.M{"_id": audit.ID}, audit); updateErr != nil && err == nil {
+		err = updateErr
+	}
+	return audit, err
+}
+
+func regradeQuizzes(ctx context.Context, quizCollection, changeCollection *mongo.Collection, keys map[primitive.ObjectID]models.QuestionUnmarshal, audit *models.RegradeAudit) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	cursor, err := quizCollection.Find(ctx, bson.M{"questions._id": bson.M{"$in": audit.QuestionIds}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	changes := make([]interface{}, 0, regradeBatchSize)
+	notifications := make([]models.Notification, 0)
+	flush := func() error {
+		if len(changes) != 0 {
+			if _, err := changeCollection.InsertMany(ctx, changes); err != nil {
+				return err
+			}
+			changes = changes[:0]
+		}
+		if len(notifications) != 0 {
+			if err := CreateNotifications(ctx, notifications); err != nil {
+				return err
+			}
+			audit.NotificationsSent += len(notifications)
+			notifications = notifications[:0]
+		}
+		return nil
+	}
+
+	skippedIds := make(map[primitive.ObjectID]bool)
+	now := time.Now()
+	for cursor.Next(ctx) {
+		var quiz models.Quiz
+		if err = cursor.Decode(&quiz); err != nil {
+			return err
+		}
+
+		before := quiz.Score
+		changed, updated, skipped := RegradeQuiz(&quiz, keys, now)
+		for _, id := range skipped {
+			if !skippedIds[id] {
+				skippedIds[id] = true
+				audit.SkippedQuestionIds = append(audit.SkippedQuestionIds, id)
+			}
+		}
+		if !updated {
+			continue
+		}
+
+		_, err = quizCollection.UpdateByID(ctx, quiz.Id, bson.M{
+			"$set": bson.M{
+				"questions":      quiz.Questions,
+				"user_responses": quiz.UserResponses,
+				"score":          quiz.Score,
+				"ability":        quiz.Ability,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		audit.QuizzesUpdated++
+		audit.ResponsesChanged += changed
+		if changed == 0 {
+			continue
+		}
+
+		changes = append(changes, models.RegradeChange{
+			AuditId:          audit.ID,
+			QuizId:           quiz.Id,
+			Username:         quiz.User.Username,
+			ResponsesChanged: changed,
+			ScoreBefore:      before,
+			ScoreAfter:       quiz.Score,
+		})
+
+		if before != nil && quiz.Score != nil && before.Percentage != quiz.Score.Percentage {
+			audit.ScoresChanged++
+			if audit.Notify {
+				notifications = append(notifications, models.Notification{
+					Username: quiz.User.Username,
+					Type:     models.NotificationTypeRegrade,
+					Message: fmt.Sprintf("Your score for %s was updated from %.2f%% to %.2f%% after an answer key correction",
+						quiz.Topic, before.Percentage, quiz.Score.Percentage),
+					QuizId:    quiz.Id,
+					CreatedAt: now,
+				})
+			}
+		}
+
+		if len(changes) >= regradeBatchSize {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func GetRegradeAudits(ctx context.Context) ([]models.RegradeAudit, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "regrade_audits")
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"started_at": -1}).SetLimit(100))
+	if err != nil {
+		return nil, err
+	}
+	audits := make([]models.RegradeAudit, 0)
+	if err = cursor.All(ctx, &audits); err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
+
+func GetRegradeAudit(ctx context.Context, id primitive.ObjectID) (models.RegradeAudit, []models.RegradeChange, error) {
+	client := GetConnection()
+	var audit models.RegradeAudit
+	err := GetCollection(client, "regrade_audits").FindOne(ctx, bson.M{"_id": id}).Decode(&audit)
+	if err != nil {
+		return audit, nil, err
+	}
+
+	cursor, err := GetCollection(client, "regrade_changes").Find(ctx, bson.M{"audit_id": id})
+	if err != nil {
+		return audit, nil, err
+	}
+	changes := make([]models.RegradeChange, 0)
+	if err = cursor.All(ctx, &changes); err != nil {
+		return audit, nil, err
+	}
+	return audit, changes, nil
+}
+
+func GetQuestionsByTopic(ctx context.Context, topic string) ([]models.QuestionUnmarshal, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	cursor, err := collection.Find(ctx, bson.M{"topic": topic})
+	if err != nil {
+		return nil, err
+	}
+	questions := make([]models.QuestionUnmarshal, 0)
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestRegradeQuiz(t *testing.T) {
+	miskeyed := models.Question{
+		ID:            primitive.NewObjectID(),
+		QuestionName:  "Which river is considered the holiest in India?",
+		Options:       []string{"Yamuna", "Godavari", "Ganga", "Narmada"},
+		CorrectAnswer: "Yamuna",
+		Revision:      1,
+	}
+	other := models.Question{ID: primitive.NewObjectID(), Options: []string{"a", "b"}, CorrectAnswer: "a", Revision: 1}
+
+	quiz := models.Quiz{
+		Questions: []models.Question{miskeyed, other},
+		UserResponses: []models.UserResponse{
+			{QuestionId: miskeyed.ID, Response: "ganga", Result: ResultWrong, CorrectAnswer: "yamuna", QuestionRevision: 1, AnswerKeyRevision: 1},
+			{QuestionId: other.ID, Response: "a", Result: ResultRight, Credit: 1, QuestionRevision: 1, AnswerKeyRevision: 1},
+		},
+		Completed: true,
+	}
+	score := ScoreQuiz(quiz, time.Now())
+	quiz.Score = &score
+	assert.Equal(t, 50.0, quiz.Score.Percentage)
+
+	keys := map[primitive.ObjectID]models.QuestionUnmarshal{
+		miskeyed.ID: {ID: miskeyed.ID, CorrectAnswer: "Ganga", Revision: 2},
+	}
+	changed, updated, skipped := RegradeQuiz(&quiz, keys, time.Now())
+
+	assert.True(t, updated)
+	assert.Equal(t, 1, changed)
+	assert.Empty(t, skipped)
+	assert.Equal(t, "Ganga", quiz.Questions[0].CorrectAnswer)
+	assert.Equal(t, "Yamuna", quiz.Questions[0].Options[0])
+	assert.Equal(t, ResultRight, quiz.UserResponses[0].Result)
+	assert.Equal(t, "ganga", quiz.UserResponses[0].CorrectAnswer)
+	assert.Equal(t, 1, quiz.UserResponses[0].QuestionRevision)
+	assert.Equal(t, 2, quiz.UserResponses[0].AnswerKeyRevision)
+	assert.Equal(t, 100.0, quiz.Score.Percentage)
+}
+
+func TestRegradeEditedOptions(t *testing.T) {
+	question := models.Question{
+		ID:            primitive.NewObjectID(),
+		Options:       []string{"Yamuna", "Godavri", "Ganga"},
+		CorrectAnswer: "Yamuna",
+	}
+	quiz := models.Quiz{
+		Questions: []models.Question{question},
+		UserResponses: []models.UserResponse{
+			{QuestionId: question.ID, Response: "godavri", Result: ResultWrong, CorrectAnswer: "yamuna"},
+		},
+	}
+
+	// Test case: An answer whose option was reworded after the quiz is
+	// mapped to the option the student saw
+	keys := map[primitive.ObjectID]models.QuestionUnmarshal{
+		question.ID: {
+			ID:            question.ID,
+			Options:       []string{"Yamuna", "Godavari", "Ganga"},
+			CorrectAnswer: "Godavari",
+			Revision:      2,
+		},
+	}
+	changed, updated, skipped := RegradeQuiz(&quiz, keys, time.Now())
+
+	assert.True(t, updated)
+	assert.Equal(t, 1, changed)
+	assert.Empty(t, skipped)
+	assert.Equal(t, "Godavri", quiz.Questions[0].CorrectAnswer)
+	assert.Equal(t, ResultRight, quiz.UserResponses[0].Result)
+
+	// Test case: A question that became multiple choice is skipped
+	keys[question.ID] = models.QuestionUnmarshal{
+		ID:             question.ID,
+		Type:           models.QuestionTypeMultipleChoice,
+		Options:        []string{"Yamuna", "Godavari", "Ganga"},
+		CorrectAnswers: []string{"Godavari", "Ganga"},
+		Revision:       3,
+	}
+	changed, updated, skipped = RegradeQuiz(&quiz, keys, time.Now())
+
+	assert.False(t, updated)
+	assert.Equal(t, 0, changed)
+	assert.Equal(t, []primitive.ObjectID{question.ID}, skipped)
+	assert.Equal(t, ResultRight, quiz.UserResponses[0].Result)
+
+	// Test case: An answer that isn't one of the options once an option was
+	// added is skipped
+	keys[question.ID] = models.QuestionUnmarshal{
+		ID:            question.ID,
+		Options:       []string{"Yamuna", "Godavari", "Ganga", "Kaveri"},
+		CorrectAnswer: "Kaveri",
+		Revision:      4,
+	}
+	changed, updated, skipped = RegradeQuiz(&quiz, keys, time.Now())
+
+	assert.False(t, updated)
+	assert.Equal(t, 0, changed)
+	assert.Equal(t, []primitive.ObjectID{question.ID}, skipped)
+	assert.Equal(t, "Godavri", quiz.Questions[0].CorrectAnswer)
+}
+
+func TestRegradeTemplateQuiz(t *testing.T) {
+	// Test case: A fixed formula is applied to the values the student saw
+	wrong := additionTemplate()
+	wrong.Answer = "a - b"
+	question := models.Question{
+		ID:            primitive.NewObjectID(),
+		Options:       []string{"-3", "7", "10", "8"},
+		CorrectAnswer: "-3",
+		Template:      wrong,
+		Variables:     map[string]float64{"a": 2, "b": 5},
+	}
+	quiz := models.Quiz{
+		Questions: []models.Question{question},
+		UserResponses: []models.UserResponse{
+			{QuestionId: question.ID, Response: "7", Result: ResultWrong, CorrectAnswer: "-3"},
+		},
+	}
+
+	keys := map[primitive.ObjectID]models.QuestionUnmarshal{
+		question.ID: {ID: question.ID, Template: additionTemplate(), Revision: 2},
+	}
+	changed, updated, _ := RegradeQuiz(&quiz, keys, time.Now())
+
+	assert.True(t, updated)
+	assert.Equal(t, 1, changed)
+	assert.Equal(t, "7", quiz.Questions[0].CorrectAnswer)
+	assert.Equal(t, ResultRight, quiz.UserResponses[0].Result)
+
+	// Test case: An answer that none of the options the student saw has
+	// leaves the question as it was
+	changedFormula := additionTemplate()
+	changedFormula.Answer = "a * b * 2"
+	keys[question.ID] = models.QuestionUnmarshal{ID: question.ID, Template: changedFormula, Revision: 3}
+	changed, updated, skipped := RegradeQuiz(&quiz, keys, time.Now())
+
+	assert.False(t, updated)
+	assert.Equal(t, 0, changed)
+	assert.Equal(t, []primitive.ObjectID{question.ID}, skipped)
+	assert.Equal(t, "7", quiz.Questions[0].CorrectAnswer)
+	assert.Equal(t, ResultRight, quiz.UserResponses[0].Result)
+}
+
+func TestDiffQuestions(t *testing.T) {
+	before := models.QuestionUnmarshal{
+		QuestionName:  "What is the capital of India?",
+		Options:       []string{"Mumbai", "New Delhi"},
+		CorrectAnswer: "Mumbai",
+	}
+	after := before
+	after.CorrectAnswer = "New Delhi"
+	after.Explanation = "New Delhi has been the capital since 1931."
+
+	changes := DiffQuestions(before, after)
+	assert.Equal(t, []string{"correct_answer", "explanation"}, changes)
+	assert.True(t, AnswerKeyChanged(changes))
+	assert.False(t, AnswerKeyChanged([]string{"explanation"}))
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"github.com/zeekhoks/quiz-backend/models"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	ResultRight   = "Right"
+	ResultWrong   = "Wrong"
+	ResultPartial = "Partial"
+)
+
+// ScoringPolicy turns the graded responses of a quiz into a score.
+type ScoringPolicy interface {
+	Score(quiz models.Quiz) models.QuizScore
+}
+
+var scoringPolicies = map[string]func(config models.ScoringConfig) ScoringPolicy{
+	models.ScoringPolicyPercentage: func(models.ScoringConfig) ScoringPolicy { return percentagePolicy{} },
+	models.ScoringPolicyWeighted:   func(config models.ScoringConfig) ScoringPolicy { return weightedPolicy{config: config} },
+}
+
+// RegisterScoringPolicy makes a scoring policy available to assessments
+// under the given name.
+func RegisterScoringPolicy(name string, factory func(config models.ScoringConfig) ScoringPolicy) {
+	scoringPolicies[name] = factory
+}
+
+func IsScoringPolicy(name string) bool {
+	_, ok := scoringPolicies[name]
+	return ok
+}
+
+// GetScoringPolicy returns the policy configured on a quiz. Quizzes without
+// a policy are scored as a plain percentage of correct answers.
+func GetScoringPolicy(config models.ScoringConfig) ScoringPolicy {
+	factory, ok := scoringPolicies[config.Policy]
+	if !ok {
+		factory = scoringPolicies[models.ScoringPolicyPercentage]
+	}
+	return factory(config)
+}
+
+// ScoreQuiz computes the score of a quiz with its configured policy. Quizzes
+// of an assignment that were finished after the due date lose the late
+// penalty before passing is decided.
+func ScoreQuiz(quiz models.Quiz, now time.Time) models.QuizScore {
+	score := GetScoringPolicy(quiz.Scoring).Score(quiz)
+	if quiz.DueAt != nil {
+		if late := AttemptFinishedAt(quiz).Sub(*quiz.DueAt); late > 0 {
+			score.Late = true
+			if quiz.LatePolicy != nil {
+				score.LatePenalty = quiz.LatePolicy.Penalty(late)
+			}
+			score.RawPoints, score.RawPercentage = score.Points, score.Percentage
+			score.Points *= 1 - score.LatePenalty/100
+			score.Percentage *= 1 - score.LatePenalty/100
+		}
+	}
+	score.Passed = score.Percentage >= quiz.PassingScore
+	score.ComputedAt = now
+	return score
+}
+
+// GradeResponse compares the selected options with the answer key of a
+// question. Multiple choice questions earn partial credit for each correct
+// option, minus one share for each incorrect one. A repeated choice is only
+// counted once.
+func GradeResponse(question models.Question, choices []string) (string, float64) {
+	if !question.IsMultipleChoice() {
+		if len(choices) == 1 && choices[0] == strings.ToLower(question.CorrectAnswer) {
+			return ResultRight, 1
+		}
+		return ResultWrong, 0
+	}
+
+	correct := make(map[string]bool)
+	for _, answer := range question.CorrectAnswers {
+		correct[strings.ToLower(answer)] = true
+	}
+	if len(correct) == 0 {
+		return ResultWrong, 0
+	}
+
+	hits, misses := 0, 0
+	seen := make(map[string]bool)
+	for _, choice := range choices {
+		if seen[choice] {
+			continue
+		}
+		seen[choice] = true
+		if correct[choice] {
+			hits++
+		} else {
+			misses++
+		}
+	}
+
+	credit := math.Max(0, float64(hits-misses)/float64(len(correct)))
+	switch {
+	case hits == len(correct) && misses == 0:
+		return ResultRight, 1
+	case credit > 0:
+		return ResultPartial, credit
+	default:
+		return ResultWrong, 0
+	}
+}
+
+type percentagePolicy struct{}
+
+func (percentagePolicy) Score(quiz models.Quiz) models.QuizScore {
+	score := models.QuizScore{
+		Policy:    models.ScoringPolicyPercentage,
+		MaxPoints: float64(len(quiz.Questions)),
+	}
+	for _, response := range quiz.UserResponses {
+		switch response.Result {
+		case ResultRight:
+			score.Correct++
+			score.Points++
+		case ResultPartial:
+			score.Partial++
+		default:
+			score.Incorrect++
+		}
+	}
+	score.Unanswered = len(quiz.Questions) - len(quiz.UserResponses)
+	if score.MaxPoints > 0 {
+		score.Percentage = score.Points / score.MaxPoints * 100
+	}
+	return score
+}
+
+type weightedPolicy struct {
+	config models.ScoringConfig
+}
+
+func (policy weightedPolicy) Score(quiz models.Quiz) models.QuizScore {
+	score := models.QuizScore{Policy: models.ScoringPolicyWeighted}
+
+	responses := make(map[string]models.UserResponse)
+	for _, response := range quiz.UserResponses {
+		responses[response.QuestionId.Hex()] = response
+	}
+
+	for _, question := range quiz.Questions {
+		points := question.MaxPoints()
+		score.MaxPoints += points
+
+		response, answered := responses[question.ID.Hex()]
+		if !answered {
+			score.Unanswered++
+			continue
+		}
+
+		switch response.Result {
+		case ResultRight:
+			score.Correct++
+			score.Points += points
+		case ResultPartial:
+			score.Partial++
+			if policy.config.PartialCredit {
+				score.Points += points * response.Credit
+			}
+		default:
+			score.Incorrect++
+			score.Points -= points * policy.config.NegativeMarking
+		}
+	}
+
+	if score.Points < 0 {
+		score.Points = 0
+	}
+	if score.MaxPoints > 0 {
+		score.Percentage = score.Points / score.MaxPoints * 100
+	}
+	return score
+}
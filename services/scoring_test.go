@@ -0,0 +1,83 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestGradeResponse(t *testing.T) {
+	single := models.Question{CorrectAnswer: "New Delhi"}
+	result, credit := GradeResponse(single, []string{"new delhi"})
+	assert.Equal(t, ResultRight, result)
+	assert.Equal(t, 1.0, credit)
+
+	result, credit = GradeResponse(single, []string{"mumbai"})
+	assert.Equal(t, ResultWrong, result)
+	assert.Equal(t, 0.0, credit)
+
+	multiple := models.Question{
+		Type:           models.QuestionTypeMultipleChoice,
+		CorrectAnswers: []string{"Ganga", "Yamuna", "Narmada", "Godavari"},
+	}
+	result, credit = GradeResponse(multiple, []string{"ganga", "yamuna", "narmada", "godavari"})
+	assert.Equal(t, ResultRight, result)
+	assert.Equal(t, 1.0, credit)
+
+	result, credit = GradeResponse(multiple, []string{"ganga", "yamuna", "thames"})
+	assert.Equal(t, ResultPartial, result)
+	assert.Equal(t, 0.25, credit)
+
+	result, credit = GradeResponse(multiple, []string{"ganga", "thames"})
+	assert.Equal(t, ResultWrong, result)
+	assert.Equal(t, 0.0, credit)
+
+	pair := models.Question{
+		Type:           models.QuestionTypeMultipleChoice,
+		CorrectAnswers: []string{"Ganga", "Yamuna"},
+	}
+	result, credit = GradeResponse(pair, []string{"ganga", "ganga"})
+	assert.Equal(t, ResultPartial, result)
+	assert.Equal(t, 0.5, credit)
+}
+
+func TestWeightedScoringPolicy(t *testing.T) {
+	questions := []models.Question{
+		{ID: primitive.NewObjectID(), Points: 2},
+		{ID: primitive.NewObjectID(), Points: 4, Type: models.QuestionTypeMultipleChoice},
+		{ID: primitive.NewObjectID()},
+		{ID: primitive.NewObjectID(), Points: 3},
+	}
+	quiz := models.Quiz{
+		Questions:    questions,
+		PassingScore: 50,
+		Scoring: models.ScoringConfig{
+			Policy:          models.ScoringPolicyWeighted,
+			PartialCredit:   true,
+			NegativeMarking: 0.5,
+		},
+		UserResponses: []models.UserResponse{
+			{QuestionId: questions[0].ID, Result: ResultRight, Credit: 1},
+			{QuestionId: questions[1].ID, Result: ResultPartial, Credit: 0.5},
+			{QuestionId: questions[2].ID, Result: ResultWrong},
+		},
+	}
+
+	score := ScoreQuiz(quiz, time.Now())
+	assert.Equal(t, 10.0, score.MaxPoints)
+	assert.Equal(t, 3.5, score.Points)
+	assert.Equal(t, 35.0, score.Percentage)
+	assert.False(t, score.Passed)
+	assert.Equal(t, 1, score.Correct)
+	assert.Equal(t, 1, score.Partial)
+	assert.Equal(t, 1, score.Incorrect)
+	assert.Equal(t, 1, score.Unanswered)
+
+	// Test case: default policy ignores weights
+	quiz.Scoring = models.ScoringConfig{}
+	score = ScoreQuiz(quiz, time.Now())
+	assert.Equal(t, 25.0, score.Percentage)
+	assert.Equal(t, models.ScoringPolicyPercentage, score.Policy)
+}
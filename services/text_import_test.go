@@ -0,0 +1,131 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+)
+
+func TestDecodeGIFTQuestions(t *testing.T) {
+	content := []byte(`// geography bank
+$CATEGORY: $course$/Geography
+
+::Capital::What is the capital of India? {
+  =New Delhi#Correct, since 1931.
+  ~Mumbai#Mumbai is the financial capital.
+  ~Kolkata
+  ####New Delhi became the capital in 1931.
+}
+
+The Ganga is the holiest river in India.{T#Hindus hold the Ganga sacred.#Right, it is worshipped as a goddess.}
+
+Which of these are primary colours? {
+  ~%50%Red
+  ~%50%Blue
+  ~%-100%Green
+}
+
+Name the longest river. {=Ganga =Ganges}
+
+The Ganga flows into the {=Bay of Bengal ~Arabian Sea} near Kolkata.
+`)
+
+	result, err := DecodeQuestions(ImportFormatGIFT, content, ImportOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, result.Errors)
+	if assert.Len(t, result.Questions, 4) {
+		capital := result.Questions[0]
+		assert.Equal(t, "What is the capital of India?", capital.QuestionName)
+		assert.Equal(t, []string{"New Delhi", "Mumbai", "Kolkata"}, capital.Options)
+		assert.Equal(t, "New Delhi", capital.CorrectAnswer)
+		assert.Equal(t, []string{"Mumbai", "Kolkata"}, capital.Distractors)
+		assert.Equal(t, "Mumbai is the financial capital.", capital.OptionFeedback["Mumbai"])
+		assert.Equal(t, "New Delhi became the capital in 1931.", capital.Explanation)
+
+		assert.Equal(t, "True", result.Questions[1].CorrectAnswer)
+		// Test case: true/false feedback is split into wrong and right feedback
+		assert.Equal(t, map[string]string{
+			"False": "Hindus hold the Ganga sacred.",
+			"True":  "Right, it is worshipped as a goddess.",
+		}, result.Questions[1].OptionFeedback)
+
+		assert.Equal(t, models.QuestionTypeMultipleChoice, result.Questions[2].Type)
+		assert.Equal(t, []string{"Red", "Blue"}, result.Questions[2].CorrectAnswers)
+
+		// Test case: missing word questions keep a blank in the stem
+		assert.Equal(t, "The Ganga flows into the _____ near Kolkata.", result.Questions[3].QuestionName)
+	}
+
+	// Test case: short answer questions are skipped with their line number
+	if assert.Len(t, result.Warnings, 1) {
+		assert.Equal(t, 19, result.Warnings[0].Row)
+	}
+}
+
+func TestDecodeGIFTQuestionsErrors(t *testing.T) {
+	content := []byte(`What is 2 + 2? {~3 ~4 ~5}
+
+What is 3 + 3? {
+  =6
+  ~7
+`)
+
+	result, err := DecodeQuestions(ImportFormatGIFT, content, ImportOptions{})
+	assert.NoError(t, err)
+	if assert.Len(t, result.Errors, 2) {
+		assert.Equal(t, 1, result.Errors[0].Row)
+		assert.Contains(t, result.Errors[0].Errors[0], "no answer is marked correct")
+		assert.Equal(t, 3, result.Errors[1].Row)
+		assert.Equal(t, []string{"line 3: answers are missing a closing }"}, result.Errors[1].Errors)
+	}
+
+	// Test case: Questions too broken to read still count toward the total
+	assert.Equal(t, 1, result.Unparsed)
+	assert.Equal(t, 2, result.Total())
+
+	// Test case: A file with nothing but skipped questions is empty
+	result, err = DecodeQuestions(importFormatText, []byte("Describe the water cycle. {}\n"), ImportOptions{})
+	assert.ErrorIs(t, err, ErrEmptyImport)
+	assert.Len(t, result.Warnings, 1)
+}
+
+func TestDecodeAikenQuestions(t *testing.T) {
+	content := []byte(`What is the capital of India?
+A. Mumbai
+B. Kolkata
+C) New Delhi
+ANSWER: C
+
+Which river is the holiest?
+A. Yamuna
+C. Ganga
+ANSWER: B
+
+Which ocean lies south of India?
+A. Indian Ocean
+B. Pacific Ocean
+`)
+
+	result, err := DecodeQuestions(ImportFormatAiken, content, ImportOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, result.Questions, 3)
+	assert.Equal(t, "New Delhi", result.Questions[0].CorrectAnswer)
+	assert.Equal(t, []string{"Mumbai", "Kolkata"}, result.Questions[0].Distractors)
+
+	if assert.Len(t, result.Errors, 2) {
+		assert.Equal(t, 7, result.Errors[0].Row)
+		assert.Equal(t, []string{"line 9: expected option B but found C"}, result.Errors[0].Errors)
+		assert.Equal(t, 12, result.Errors[1].Row)
+		assert.Equal(t, []string{"question has no ANSWER line"}, result.Errors[1].Errors)
+	}
+}
+
+func TestDetectTextImportFormat(t *testing.T) {
+	assert.Equal(t, ImportFormatGIFT, DetectImportFormat("", "bank.gift"))
+	assert.Equal(t, ImportFormatAiken, DetectImportFormat("aiken", "bank.txt"))
+
+	// Test case: .txt files are read as Aiken when they have ANSWER lines
+	result, err := DecodeQuestions(DetectImportFormat("", "bank.txt"), []byte("2 + 2?\nA. 3\nB. 4\nANSWER: B\n"), ImportOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "4", result.Questions[0].CorrectAnswer)
+}
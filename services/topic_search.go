@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orders topics can be listed in. Any of them can be reversed with a
+// leading "-".
+const (
+	TopicSortName               = "name"
+	TopicSortRelevance          = "relevance"
+	TopicSortPublishedQuestions = "published_questions"
+	TopicSortAttempts           = "attempts"
+	TopicSortCreatedAt          = "created_at"
+)
+
+const (
+	DefaultTopicPageSize = 50
+	MaxTopicPageSize     = 200
+)
+
+// Ranks of a search match, best first.
+const (
+	topicMatchExact = iota
+	topicMatchPrefix
+	topicMatchWordPrefix
+	topicMatchSubstring
+	topicMatchFuzzy
+)
+
+// ErrInvalidTopicCursor is returned for a cursor that wasn't handed out for
+// the same search and order.
+var ErrInvalidTopicCursor = errors.New("cursor is invalid for this search")
+
+// TopicQuery describes one page of a topic listing.
+type TopicQuery struct {
+	Search     string
+	Sort       string
+	Descending bool
+	Limit      int
+	Cursor     string
+}
+
+type TopicPage struct {
+	Topics     []models.Topic
+	Total      int
+	NextCursor string
+}
+
+// topicCursor is the sort key of the last topic on a page. The next page
+// starts after it, so topics added or removed meanwhile don't shift pages.
+type topicCursor struct {
+	Search     string             `json:"q,omitempty"`
+	Sort       string             `json:"s"`
+	Descending bool               `json:"d,omitempty"`
+	Rank       int                `json:"r,omitempty"`
+	Name       string             `json:"n"`
+	Count      int                `json:"c,omitempty"`
+	CreatedAt  *time.Time         `json:"t,omitempty"`
+	ID         primitive.ObjectID `json:"id"`
+}
+
+type rankedTopic struct {
+	topic models.Topic
+	rank  int
+}
+
+func IsTopicSort(value string) bool {
+	switch value {
+	case TopicSortName, TopicSortRelevance, TopicSortPublishedQuestions, TopicSortAttempts, TopicSortCreatedAt:
+		return true
+	}
+	return false
+}
+
+// MatchTopic reports whether a topic name matches a search and how well:
+// the whole name, the start of the name, the start of a word, anywhere in
+// the name, or a word within a typo or two of the search.
+func MatchTopic(name, search string) (int, bool) {
+	name = strings.ToLower(name)
+	search = strings.ToLower(strings.TrimSpace(search))
+	switch {
+	case name == search:
+		return topicMatchExact, true
+	case strings.HasPrefix(name, search):
+		return topicMatchPrefix, true
+	}
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_' || r == '/' || r == ','
+	})
+	for _, word := range words {
+		if strings.HasPrefix(word, search) {
+			return topicMatchWordPrefix, true
+		}
+	}
+	if strings.Contains(name, search) {
+		return topicMatchSubstring, true
+	}
+
+	allowed := fuzzyEdits(search)
+	if allowed == 0 {
+		return 0, false
+	}
+	for _, word := range append(words, name) {
+		if fuzzyPrefix(word, search, allowed) {
+			return topicMatchFuzzy, true
+		}
+	}
+	return 0, false
+}
+
+// fuzzyPrefix reports whether word starts with search give or take allowed
+// typos, e.g. "algbra" in "algebraic".
+func fuzzyPrefix(word, search string, allowed int) bool {
+	runes := []rune(word)
+	length := len([]rune(search))
+	for cut := max(length-allowed, 1); cut <= min(length+allowed, len(runes)); cut++ {
+		if editDistance(string(runes[:cut]), search) <= allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyEdits is how many typos a search of this length may contain. Short
+// searches must match exactly.
+func fuzzyEdits(search string) int {
+	switch length := len([]rune(search)); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance counts the insertions, deletions and substitutions that turn
+// a into b.
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+// SearchTopics filters, orders and pages topics. Without a search they are
+// ordered by name, and by relevance otherwise.
+func SearchTopics(topics []models.Topic, query TopicQuery) (TopicPage, error) {
+	if query.Sort == "" {
+		query.Sort = TopicSortName
+		if query.Search != "" {
+			query.Sort = TopicSortRelevance
+		}
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultTopicPageSize
+	}
+
+	ranked := make([]rankedTopic, 0, len(topics))
+	for _, topic := range topics {
+		rank := 0
+		if query.Search != "" {
+			var ok bool
+			if rank, ok = MatchTopic(topic.Name, query.Search); !ok {
+				continue
+			}
+		}
+		ranked = append(ranked, rankedTopic{topic: topic, rank: rank})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		return compareTopics(ranked[i], ranked[j], query.Sort, query.Descending) < 0
+	})
+
+	start := 0
+	if query.Cursor != "" {
+		after, err := decodeTopicCursor(query)
+		if err != nil {
+			return TopicPage{}, err
+		}
+		start = sort.Search(len(ranked), func(i int) bool {
+			return compareTopics(ranked[i], after, query.Sort, query.Descending) > 0
+		})
+	}
+
+	end := min(start+query.Limit, len(ranked))
+	page := TopicPage{Topics: make([]models.Topic, 0, end-start), Total: len(ranked)}
+	for _, item := range ranked[start:end] {
+		page.Topics = append(page.Topics, item.topic)
+	}
+	if end < len(ranked) {
+		page.NextCursor = encodeTopicCursor(ranked[end-1], query)
+	}
+	return page, nil
+}
+
+// compareTopics orders two topics by the sort key, then by name and ID so
+// that every topic has a stable place for cursors to point at.
+func compareTopics(a, b rankedTopic, sortBy string, descending bool) int {
+	byName := strings.Compare(strings.ToLower(a.topic.Name), strings.ToLower(b.topic.Name))
+	order := 0
+	switch sortBy {
+	case TopicSortName:
+		order = byName
+	case TopicSortRelevance:
+		order = a.rank - b.rank
+	case TopicSortPublishedQuestions:
+		order = a.topic.PublishedQuestionCount - b.topic.PublishedQuestionCount
+	case TopicSortAttempts:
+		order = a.topic.AttemptCount - b.topic.AttemptCount
+	case TopicSortCreatedAt:
+		order = topicCreatedAt(a.topic).Compare(topicCreatedAt(b.topic))
+	}
+	if descending {
+		order = -order
+	}
+	if order == 0 {
+		order = byName
+	}
+	if order == 0 {
+		order = strings.Compare(a.topic.ID.Hex(), b.topic.ID.Hex())
+	}
+	return order
+}
+
+func topicCreatedAt(topic models.Topic) time.Time {
+	if topic.CreatedAt != nil {
+		return *topic.CreatedAt
+	}
+	// Topics stored by older uploads have no creation time, but their ID
+	// holds one.
+	return topic.ID.Timestamp()
+}
+
+func encodeTopicCursor(last rankedTopic, query TopicQuery) string {
+	cursor := topicCursor{
+		Search:     query.Search,
+		Sort:       query.Sort,
+		Descending: query.Descending,
+		Rank:       last.rank,
+		Name:       last.topic.Name,
+		CreatedAt:  last.topic.CreatedAt,
+		ID:         last.topic.ID,
+	}
+	switch query.Sort {
+	case TopicSortPublishedQuestions:
+		cursor.Count = last.topic.PublishedQuestionCount
+	case TopicSortAttempts:
+		cursor.Count = last.topic.AttemptCount
+	}
+	content, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func decodeTopicCursor(query TopicQuery) (rankedTopic, error) {
+	content, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return rankedTopic{}, ErrInvalidTopicCursor
+	}
+	var cursor topicCursor
+	if err = json.Unmarshal(content, &cursor); err != nil {
+		return rankedTopic{}, ErrInvalidTopicCursor
+	}
+	if cursor.Search != query.Search || cursor.Sort != query.Sort || cursor.Descending != query.Descending {
+		return rankedTopic{}, ErrInvalidTopicCursor
+	}
+	return rankedTopic{
+		topic: models.Topic{
+			ID:                     cursor.ID,
+			Name:                   cursor.Name,
+			CreatedAt:              cursor.CreatedAt,
+			PublishedQuestionCount: cursor.Count,
+			AttemptCount:           cursor.Count,
+		},
+		rank: cursor.Rank,
+	}, nil
+}
+
+// OpenTopicNames returns the topics of the assessments that members of the
+// given classes can start at the given time, including the topics their
+// blueprint sections draw from.
+func OpenTopicNames(ctx context.Context, now time.Time, classIds []primitive.ObjectID) (map[string]bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "assessments")
+	filter := StudentAssessmentFilter(now, classIds)
+	open := make(map[string]bool)
+	for _, field := range []string{"topic", "blueprint.sections.topic"} {
+		names, err := collection.Distinct(ctx, field, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if topic, ok := name.(string); ok && topic != "" {
+				open[topic] = true
+			}
+		}
+	}
+	return open, nil
+}
+
+// OpenAssessmentFilter matches the assessments whose window includes now.
+func OpenAssessmentFilter(now time.Time) bson.M {
+	return bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{bson.M{"opens_at": bson.M{"$exists": false}}, bson.M{"opens_at": bson.M{"$lte": now}}}},
+		bson.M{"$or": bson.A{bson.M{"closes_at": bson.M{"$exists": false}}, bson.M{"closes_at": bson.M{"$gte": now}}}},
+	}}
+}
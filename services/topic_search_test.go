@@ -0,0 +1,89 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func TestMatchTopic(t *testing.T) {
+	// Test case: Better matches rank first
+	rank, ok := MatchTopic("Algebra", "algebra")
+	assert.True(t, ok)
+	assert.Equal(t, topicMatchExact, rank)
+	rank, _ = MatchTopic("Algebra II", "alg")
+	assert.Equal(t, topicMatchPrefix, rank)
+	rank, _ = MatchTopic("Linear Algebra", "alg")
+	assert.Equal(t, topicMatchWordPrefix, rank)
+	rank, _ = MatchTopic("Photosynthesis", "synth")
+	assert.Equal(t, topicMatchSubstring, rank)
+
+	// Test case: Typos are forgiven in longer searches
+	rank, ok = MatchTopic("Linear Algebraic Equations", "algbra")
+	assert.True(t, ok)
+	assert.Equal(t, topicMatchFuzzy, rank)
+	_, ok = MatchTopic("Geometry", "geomtery")
+	assert.True(t, ok)
+
+	// Test case: Short searches must match exactly
+	_, ok = MatchTopic("Art", "arc")
+	assert.False(t, ok)
+}
+
+func topicListFixture() []models.Topic {
+	names := []string{"Geometry", "Algebra", "Linear Algebra", "Biology", "algebra basics"}
+	topics := make([]models.Topic, 0, len(names))
+	for i, name := range names {
+		topics = append(topics, models.Topic{ID: primitive.NewObjectID(), Name: name, PublishedQuestionCount: i % 2, AttemptCount: i})
+	}
+	return topics
+}
+
+func TestSearchTopics(t *testing.T) {
+	topics := topicListFixture()
+
+	// Test case: Names are ordered without regard to case
+	page, err := SearchTopics(topics, TopicQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Algebra", "algebra basics", "Biology", "Geometry", "Linear Algebra"}, topicNames(page.Topics))
+
+	// Test case: Searches are ordered by relevance
+	page, _ = SearchTopics(topics, TopicQuery{Search: "algebra"})
+	assert.Equal(t, []string{"Algebra", "algebra basics", "Linear Algebra"}, topicNames(page.Topics))
+
+	// Test case: Descending counts break ties by name
+	page, _ = SearchTopics(topics, TopicQuery{Sort: TopicSortPublishedQuestions, Descending: true})
+	assert.Equal(t, []string{"Algebra", "Biology", "algebra basics", "Geometry", "Linear Algebra"}, topicNames(page.Topics))
+}
+
+func TestSearchTopicsCursor(t *testing.T) {
+	topics := topicListFixture()
+	query := TopicQuery{Sort: TopicSortAttempts, Descending: true, Limit: 2}
+
+	// Test case: Pages follow each other until the last one
+	seen := make([]string, 0)
+	for {
+		page, err := SearchTopics(topics, query)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, page.Total)
+		seen = append(seen, topicNames(page.Topics)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"algebra basics", "Biology", "Linear Algebra", "Algebra", "Geometry"}, seen)
+
+	// Test case: Topics added before the cursor don't repeat a topic
+	first, _ := SearchTopics(topics, TopicQuery{Limit: 2})
+	topics = append(topics, models.Topic{ID: primitive.NewObjectID(), Name: "Acoustics"})
+	second, _ := SearchTopics(topics, TopicQuery{Limit: 2, Cursor: first.NextCursor})
+	assert.Equal(t, []string{"Biology", "Geometry"}, topicNames(second.Topics))
+
+	// Test case: Cursors only work for the search they came from
+	_, err := SearchTopics(topics, TopicQuery{Limit: 2, Search: "bio", Cursor: first.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidTopicCursor)
+	_, err = SearchTopics(topics, TopicQuery{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, ErrInvalidTopicCursor)
+}
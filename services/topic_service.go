@@ -0,0 +1,425 @@
+package services
+
+import (
+	"context"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"time"
+)
+
+// TopicError is a refused change to a topic with the status to answer it
+// with.
+type TopicError struct {
+	Message string
+	Status  int
+}
+
+func (err *TopicError) Error() string {
+	return err.Message
+}
+
+// GetTopics returns every topic by name. Uploads used to store a topic once
+// per upload, so repeated names are collapsed into the oldest document.
+func GetTopics(ctx context.Context) ([]models.Topic, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "topics")
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "topic", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	stored := make([]models.Topic, 0)
+	if err = cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	topics := make([]models.Topic, 0, len(stored))
+	for _, topic := range stored {
+		if len(topics) != 0 && topics[len(topics)-1].Name == topic.Name {
+			continue
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// CountTopics fills in the question and attempt counts of topics, a page
+// taken from all. Only those topics, and the subtopics whose questions add
+// up to their totals, are counted.
+func CountTopics(ctx context.Context, all []models.Topic, topics []models.Topic) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	subtree := TopicSubtreeNames(all, topics)
+	counts, err := countByTopic(ctx, "questions", bson.M{
+		"topic":  bson.M{"$in": subtree},
+		"status": bson.M{"$ne": models.QuestionStatusRetired},
+	})
+	if err != nil {
+		return err
+	}
+	// Totals are summed over a copy of every topic so that subtopics
+	// outside the page still add to their parents.
+	counted := append([]models.Topic(nil), all...)
+	CountTopicQuestions(counted, counts)
+	byId := make(map[primitive.ObjectID]models.Topic, len(counted))
+	for _, topic := range counted {
+		byId[topic.ID] = topic
+	}
+
+	names := topicNames(topics)
+	published, err := countByTopic(ctx, "questions", bson.M{"topic": bson.M{"$in": names}, "status": PublishedStatus()})
+	if err != nil {
+		return err
+	}
+	attempts, err := countByTopic(ctx, "quizzes", bson.M{"topic": bson.M{"$in": names}})
+	if err != nil {
+		return err
+	}
+	for i := range topics {
+		topics[i].QuestionCount = byId[topics[i].ID].QuestionCount
+		topics[i].TotalQuestionCount = byId[topics[i].ID].TotalQuestionCount
+		topics[i].PublishedQuestionCount = published[topics[i].Name]
+		topics[i].AttemptCount = attempts[topics[i].Name]
+	}
+	return nil
+}
+
+// CountTopicSortKey fills in the count topics are to be sorted by, if the
+// order is by a count. The other counts are left for CountTopics to fill in
+// for the page that is returned.
+func CountTopicSortKey(ctx context.Context, topics []models.Topic, sortBy string) error {
+	names := topicNames(topics)
+	switch sortBy {
+	case TopicSortPublishedQuestions:
+		published, err := countByTopic(ctx, "questions", bson.M{"topic": bson.M{"$in": names}, "status": PublishedStatus()})
+		if err != nil {
+			return err
+		}
+		for i := range topics {
+			topics[i].PublishedQuestionCount = published[topics[i].Name]
+		}
+	case TopicSortAttempts:
+		attempts, err := countByTopic(ctx, "quizzes", bson.M{"topic": bson.M{"$in": names}})
+		if err != nil {
+			return err
+		}
+		for i := range topics {
+			topics[i].AttemptCount = attempts[topics[i].Name]
+		}
+	}
+	return nil
+}
+
+// TopicSubtreeNames lists the names of the given topics and of every topic
+// below them in all.
+func TopicSubtreeNames(all []models.Topic, topics []models.Topic) []string {
+	wanted := make(map[primitive.ObjectID]bool, len(topics))
+	for _, topic := range topics {
+		wanted[topic.ID] = true
+	}
+	index := make(map[primitive.ObjectID]int, len(all))
+	for i := range all {
+		index[all[i].ID] = i
+	}
+	names := make([]string, 0, len(topics))
+	for i := range all {
+		for _, j := range topicLineage(all, index, i) {
+			if wanted[all[j].ID] {
+				names = append(names, all[i].Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+func topicNames(topics []models.Topic) []string {
+	names := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+	return names
+}
+
+func GetTopicById(ctx context.Context, id primitive.ObjectID) (models.Topic, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "topics")
+	var topic models.Topic
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&topic)
+	return topic, err
+}
+
+// countByTopic counts the documents matching filter in a collection for
+// each topic name.
+func countByTopic(ctx context.Context, collectionName string, filter bson.M) (map[string]int, error) {
+	client := GetConnection()
+	collection := GetCollection(client, collectionName)
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{"_id": "$topic", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Topic string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(groups))
+	for _, group := range groups {
+		counts[group.Topic] = group.Count
+	}
+	return counts, nil
+}
+
+// CountTopicQuestions sets the question counts of each topic, adding the
+// questions of a subtopic to every topic above it.
+func CountTopicQuestions(topics []models.Topic, counts map[string]int) {
+	index := make(map[primitive.ObjectID]int, len(topics))
+	for i := range topics {
+		index[topics[i].ID] = i
+		topics[i].QuestionCount = counts[topics[i].Name]
+		topics[i].TotalQuestionCount = 0
+	}
+	for i := range topics {
+		for _, j := range topicLineage(topics, index, i) {
+			topics[j].TotalQuestionCount += topics[i].QuestionCount
+		}
+	}
+}
+
+// topicLineage lists the index of a topic followed by those of its parent,
+// grandparent and so on. A broken chain of parents ends the list.
+func topicLineage(topics []models.Topic, index map[primitive.ObjectID]int, i int) []int {
+	lineage := []int{i}
+	for len(lineage) <= len(topics) {
+		parentId := topics[lineage[len(lineage)-1]].ParentId
+		if parentId == nil {
+			break
+		}
+		parent, ok := index[*parentId]
+		if !ok || parent == i {
+			break
+		}
+		lineage = append(lineage, parent)
+	}
+	return lineage
+}
+
+// TopicAncestors returns the topics above the given one, from its parent up
+// to the top-level subject.
+func TopicAncestors(topics []models.Topic, id primitive.ObjectID) []models.Topic {
+	index := make(map[primitive.ObjectID]int, len(topics))
+	for i := range topics {
+		index[topics[i].ID] = i
+	}
+	i, ok := index[id]
+	if !ok {
+		return nil
+	}
+	ancestors := make([]models.Topic, 0)
+	for _, j := range topicLineage(topics, index, i)[1:] {
+		ancestors = append(ancestors, topics[j])
+	}
+	return ancestors
+}
+
+// SubTopics returns the topics directly under the given one.
+func SubTopics(topics []models.Topic, id primitive.ObjectID) []models.Topic {
+	subtopics := make([]models.Topic, 0)
+	for _, topic := range topics {
+		if topic.ParentId != nil && *topic.ParentId == id {
+			subtopics = append(subtopics, topic)
+		}
+	}
+	return subtopics
+}
+
+// topicCreatesCycle reports whether moving a topic under parentId would put
+// it under itself.
+func topicCreatesCycle(topics []models.Topic, id, parentId primitive.ObjectID) bool {
+	if id == parentId {
+		return true
+	}
+	for _, ancestor := range TopicAncestors(topics, parentId) {
+		if ancestor.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureTopic stores a topic by name unless one already exists.
+func EnsureTopic(ctx context.Context, name, createdBy string) error {
+	client := GetConnection()
+	collection := GetCollection(client, "topics")
+	now := time.Now()
+	_, err := collection.UpdateOne(ctx, bson.M{"topic": name}, bson.M{
+		"$setOnInsert": bson.M{"created_by": createdBy, "created_at": now},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// checkTopicName refuses a name that another topic already has.
+func checkTopicName(ctx context.Context, name string, id primitive.ObjectID) error {
+	client := GetConnection()
+	collection := GetCollection(client, "topics")
+	count, err := collection.CountDocuments(ctx, bson.M{"topic": name, "_id": bson.M{"$ne": id}})
+	if err != nil {
+		return err
+	}
+	if count != 0 {
+		return &TopicError{Message: "A topic with this name already exists", Status: http.StatusConflict}
+	}
+	return nil
+}
+
+// checkTopicParent makes sure the parent of a topic exists and isn't the
+// topic itself or one of its subtopics.
+func checkTopicParent(ctx context.Context, topic models.Topic) error {
+	if topic.ParentId == nil {
+		return nil
+	}
+	topics, err := GetTopics(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsTopic(topics, *topic.ParentId) {
+		return &TopicError{Message: "Parent topic not found", Status: http.StatusBadRequest}
+	}
+	if !topic.ID.IsZero() && topicCreatesCycle(topics, topic.ID, *topic.ParentId) {
+		return &TopicError{Message: "A topic can't be moved under itself or one of its subtopics", Status: http.StatusBadRequest}
+	}
+	return nil
+}
+
+func containsTopic(topics []models.Topic, id primitive.ObjectID) bool {
+	for _, topic := range topics {
+		if topic.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func CreateTopic(ctx context.Context, topic models.Topic) (models.Topic, error) {
+	if err := checkTopicName(ctx, topic.Name, primitive.NilObjectID); err != nil {
+		return topic, err
+	}
+	if err := checkTopicParent(ctx, topic); err != nil {
+		return topic, err
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "topics")
+	res, err := collection.InsertOne(ctx, topic)
+	if err != nil {
+		return topic, err
+	}
+	topic.ID = res.InsertedID.(primitive.ObjectID)
+	return topic, nil
+}
+
+// UpdateTopic saves a described, moved or renamed topic. A new name is
+// carried over to the questions, assessments and quizzes that use the old
+// one before the topic itself is renamed, so a failed rename can be retried.
+func UpdateTopic(ctx context.Context, current, updated models.Topic) (models.Topic, error) {
+	updated.ID = current.ID
+	updated.CreatedBy = current.CreatedBy
+	updated.CreatedAt = current.CreatedAt
+
+	renamed := updated.Name != current.Name
+	if renamed {
+		if err := checkTopicName(ctx, updated.Name, current.ID); err != nil {
+			return current, err
+		}
+	}
+	if err := checkTopicParent(ctx, updated); err != nil {
+		return current, err
+	}
+
+	if renamed {
+		if err := renameTopicReferences(ctx, current.Name, updated.Name); err != nil {
+			return current, err
+		}
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "topics")
+	if _, err := collection.ReplaceOne(ctx, bson.M{"_id": current.ID}, updated); err != nil {
+		return current, err
+	}
+	if renamed {
+		if _, err := collection.DeleteMany(ctx, bson.M{"topic": current.Name}); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// renameTopicReferences moves everything that refers to a topic by name over
+// to its new name.
+func renameTopicReferences(ctx context.Context, from, to string) error {
+	client := GetConnection()
+	updates := []struct {
+		collection string
+		filter     bson.M
+		update     bson.M
+		options    *options.UpdateOptions
+	}{
+		{"questions", bson.M{"topic": from}, bson.M{"$set": bson.M{"topic": to}}, nil},
+		{"item_statistics", bson.M{"topic": from}, bson.M{"$set": bson.M{"topic": to}}, nil},
+		{"assessments", bson.M{"topic": from}, bson.M{"$set": bson.M{"topic": to}}, nil},
+		{"assessments", bson.M{"blueprint.sections.topic": from}, bson.M{"$set": bson.M{"blueprint.sections.$[section].topic": to}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"section.topic": from}}})},
+		{"quizzes", bson.M{"topic": from}, bson.M{"$set": bson.M{"topic": to}}, nil},
+		{"quizzes", bson.M{"questions.topic": from}, bson.M{"$set": bson.M{"questions.$[question].topic": to}},
+			options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"question.topic": from}}})},
+	}
+	for _, update := range updates {
+		opts := update.options
+		if opts == nil {
+			opts = options.Update()
+		}
+		if _, err := GetCollection(client, update.collection).UpdateMany(ctx, update.filter, update.update, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTopic removes a topic that nothing depends on any more. Topics with
+// subtopics, questions (retired ones included), assessments or quiz
+// attempts are kept.
+func DeleteTopic(ctx context.Context, topic models.Topic) error {
+	client := GetConnection()
+	checks := []struct {
+		collection string
+		filter     bson.M
+		message    string
+	}{
+		{"topics", bson.M{"parent_id": topic.ID}, "Topic has subtopics. Move or delete them first"},
+		{"questions", bson.M{"topic": topic.Name}, "Topic has questions. Move or delete them first"},
+		{"assessments", bson.M{"$or": bson.A{bson.M{"topic": topic.Name}, bson.M{"blueprint.sections.topic": topic.Name}}}, "Topic is used by an assessment"},
+		{"quizzes", bson.M{"topic": topic.Name}, "Topic has quiz attempts and cannot be deleted"},
+	}
+	for _, check := range checks {
+		count, err := GetCollection(client, check.collection).CountDocuments(ctx, check.filter)
+		if err != nil {
+			return err
+		}
+		if count != 0 {
+			return &TopicError{Message: check.message, Status: http.StatusConflict}
+		}
+	}
+
+	_, err := GetCollection(client, "topics").DeleteMany(ctx, bson.M{"topic": topic.Name})
+	return err
+}
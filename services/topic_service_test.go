@@ -0,0 +1,63 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+func topicTreeFixture() []models.Topic {
+	mathematics := models.Topic{ID: primitive.NewObjectID(), Name: "Mathematics"}
+	algebra := models.Topic{ID: primitive.NewObjectID(), Name: "Algebra", ParentId: &mathematics.ID}
+	equations := models.Topic{ID: primitive.NewObjectID(), Name: "Equations", ParentId: &algebra.ID}
+	geometry := models.Topic{ID: primitive.NewObjectID(), Name: "Geometry", ParentId: &mathematics.ID}
+	return []models.Topic{mathematics, algebra, equations, geometry}
+}
+
+func TestCountTopicQuestions(t *testing.T) {
+	topics := topicTreeFixture()
+	CountTopicQuestions(topics, map[string]int{"Mathematics": 1, "Algebra": 2, "Equations": 4, "Geometry": 8})
+
+	// Test case: Subtopic questions add up in every topic above them
+	assert.Equal(t, 1, topics[0].QuestionCount)
+	assert.Equal(t, 15, topics[0].TotalQuestionCount)
+	assert.Equal(t, 6, topics[1].TotalQuestionCount)
+	assert.Equal(t, 4, topics[2].TotalQuestionCount)
+
+	// Test case: A corrupted cycle of parents doesn't loop forever
+	topics[0].ParentId = &topics[2].ID
+	CountTopicQuestions(topics, map[string]int{"Equations": 1})
+	assert.Equal(t, 1, topics[0].TotalQuestionCount)
+	assert.Equal(t, 1, topics[2].TotalQuestionCount)
+}
+
+func TestTopicSubtreeNames(t *testing.T) {
+	topics := topicTreeFixture()
+
+	// Test case: A page counts its topics and everything below them
+	assert.ElementsMatch(t, []string{"Algebra", "Equations"}, TopicSubtreeNames(topics, topics[1:2]))
+	assert.ElementsMatch(t, []string{"Equations", "Geometry"}, TopicSubtreeNames(topics, []models.Topic{topics[2], topics[3]}))
+	assert.Len(t, TopicSubtreeNames(topics, topics[:1]), 4)
+}
+
+func TestTopicHierarchy(t *testing.T) {
+	topics := topicTreeFixture()
+
+	// Test case: Ancestors run from the parent up to the subject
+	ancestors := TopicAncestors(topics, topics[2].ID)
+	assert.Len(t, ancestors, 2)
+	assert.Equal(t, "Algebra", ancestors[0].Name)
+	assert.Equal(t, "Mathematics", ancestors[1].Name)
+
+	// Test case: Only direct children are subtopics
+	subtopics := SubTopics(topics, topics[0].ID)
+	assert.Len(t, subtopics, 2)
+	assert.Equal(t, "Algebra", subtopics[0].Name)
+	assert.Equal(t, "Geometry", subtopics[1].Name)
+
+	// Test case: A topic can't move under itself or its subtopics
+	assert.True(t, topicCreatesCycle(topics, topics[0].ID, topics[0].ID))
+	assert.True(t, topicCreatesCycle(topics, topics[0].ID, topics[2].ID))
+	assert.False(t, topicCreatesCycle(topics, topics[3].ID, topics[1].ID))
+}
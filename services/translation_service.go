@@ -0,0 +1,218 @@
+package services
+
+import (
+	"fmt"
+	"github.com/zeekhoks/quiz-backend/models"
+	"golang.org/x/text/language"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultLocale is the language questions and API messages are written in.
+const DefaultLocale = "en"
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// NormalizeLocale lowercases a locale and separates its parts with hyphens,
+// so that "hi_IN" and "hi-in" name the same translation.
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// IsLocale reports whether a normalized locale looks like a language tag.
+func IsLocale(locale string) bool {
+	return localePattern.MatchString(locale)
+}
+
+// PreferredLocale returns the language a client asked for first in an
+// Accept-Language header, or "" when it didn't ask for any.
+func PreferredLocale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 || tags[0] == language.Und {
+		return ""
+	}
+	return NormalizeLocale(tags[0].String())
+}
+
+// localeFallbacks lists a locale followed by its less specific parents, e.g.
+// "hi-in" then "hi".
+func localeFallbacks(locale string) []string {
+	fallbacks := make([]string, 0, 2)
+	for locale != "" {
+		fallbacks = append(fallbacks, locale)
+		cut := strings.LastIndex(locale, "-")
+		if cut < 0 {
+			break
+		}
+		locale = locale[:cut]
+	}
+	return fallbacks
+}
+
+// findTranslation returns the translation closest to locale and the locale
+// it was written for.
+func findTranslation(translations models.Translations, locale string) (models.QuestionTranslation, string, bool) {
+	for _, candidate := range localeFallbacks(NormalizeLocale(locale)) {
+		if translation, ok := translations[candidate]; ok {
+			return translation, candidate, true
+		}
+	}
+	return models.QuestionTranslation{}, "", false
+}
+
+// LocalizeQuestion replaces the text of a question snapshot with its
+// translation for locale, falling back from "hi-in" to "hi" and then to the
+// original text. Answers, distractors and feedback follow their options, so
+// the snapshot is graded in the language it is shown in.
+func LocalizeQuestion(question *models.Question, locale string) {
+	translation, found, ok := findTranslation(question.Translations, locale)
+	question.Translations = nil
+	if !ok {
+		return
+	}
+	question.Locale = found
+
+	if translation.Question != "" {
+		question.QuestionName = translation.Question
+	}
+	if translation.Explanation != "" {
+		question.Explanation = translation.Explanation
+	}
+
+	translated := func(text string) string { return text }
+	if len(translation.Options) != 0 && len(translation.Options) == len(question.Options) {
+		translated = translateOptions(question.Options, translation.Options)
+		question.CorrectAnswer = translated(question.CorrectAnswer)
+		for i := range question.CorrectAnswers {
+			question.CorrectAnswers[i] = translated(question.CorrectAnswers[i])
+		}
+		for i := range question.Distractors {
+			question.Distractors[i] = translated(question.Distractors[i])
+		}
+		for i := range question.Media {
+			if question.Media[i].Option != "" {
+				question.Media[i].Option = translated(question.Media[i].Option)
+			}
+		}
+		question.Options = slices.Clone(translation.Options)
+	}
+	if len(question.OptionFeedback) != 0 || len(translation.OptionFeedback) != 0 {
+		feedback := make(map[string]string, len(question.OptionFeedback))
+		for option, text := range question.OptionFeedback {
+			feedback[translated(option)] = text
+		}
+		maps.Copy(feedback, translation.OptionFeedback)
+		question.OptionFeedback = feedback
+	}
+}
+
+// LocalizeQuestions localizes every question of a new attempt.
+func LocalizeQuestions(questions []models.Question, locale string) {
+	for i := range questions {
+		LocalizeQuestion(&questions[i], locale)
+	}
+}
+
+// LocalizeAnswerKey returns the correct answers of a question in the
+// language of a localized snapshot, for re-grading it.
+func LocalizeAnswerKey(key models.QuestionUnmarshal, locale string) (string, []string) {
+	translation, _, ok := findTranslation(key.Translations, locale)
+	if !ok || len(translation.Options) == 0 || len(translation.Options) != len(key.Options) {
+		return key.CorrectAnswer, key.CorrectAnswers
+	}
+	translated := translateOptions(key.Options, translation.Options)
+	answers := make([]string, 0, len(key.CorrectAnswers))
+	for _, answer := range key.CorrectAnswers {
+		answers = append(answers, translated(answer))
+	}
+	if key.CorrectAnswers == nil {
+		answers = nil
+	}
+	return translated(key.CorrectAnswer), answers
+}
+
+// translateOptions maps option text to the translated option at the same
+// position, ignoring case and surrounding space like grading does. Text
+// that isn't an option is left as it is.
+func translateOptions(options, translations []string) func(string) string {
+	byOption := make(map[string]string, len(options))
+	for i, option := range options {
+		byOption[strings.ToLower(strings.TrimSpace(option))] = translations[i]
+	}
+	return func(text string) string {
+		if translation, ok := byOption[strings.ToLower(strings.TrimSpace(text))]; ok {
+			return translation
+		}
+		return text
+	}
+}
+
+// validateTranslations normalizes the locales of a question's translations
+// and checks that they line up with its options.
+func validateTranslations(question *models.QuestionUnmarshal) []string {
+	errorStrings := make([]string, 0)
+	if len(question.Translations) == 0 {
+		question.Translations = nil
+		return errorStrings
+	}
+
+	locales := make([]string, 0, len(question.Translations))
+	for locale := range question.Translations {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	translations := make(models.Translations, len(locales))
+	for _, raw := range locales {
+		locale := NormalizeLocale(raw)
+		translation := question.Translations[raw]
+		if !IsLocale(locale) {
+			errorStrings = append(errorStrings, fmt.Sprintf("translation locale %q should be a language tag such as hi or hi-IN", raw))
+			continue
+		}
+		if _, ok := translations[locale]; ok {
+			errorStrings = append(errorStrings, fmt.Sprintf("translation locale %q is repeated", raw))
+			continue
+		}
+
+		translation.Question = strings.TrimSpace(translation.Question)
+		translation.Explanation = strings.TrimSpace(translation.Explanation)
+		if translation.Question == "" {
+			errorStrings = append(errorStrings, fmt.Sprintf("translation %q should have a question", locale))
+		}
+
+		if len(translation.Options) != 0 {
+			if question.Template != nil {
+				errorStrings = append(errorStrings, fmt.Sprintf("translation %q should not have options for a template question", locale))
+			} else if len(translation.Options) != len(question.Options) {
+				errorStrings = append(errorStrings, fmt.Sprintf("translation %q should have %d options", locale, len(question.Options)))
+			}
+		}
+		options := make(map[string]bool, len(translation.Options))
+		for _, option := range translation.Options {
+			key := strings.ToLower(strings.TrimSpace(option))
+			if key == "" {
+				errorStrings = append(errorStrings, fmt.Sprintf("translation %q should not have empty options", locale))
+				continue
+			}
+			if options[key] {
+				errorStrings = append(errorStrings, fmt.Sprintf("translation %q repeats option %q", locale, option))
+			}
+			options[key] = true
+		}
+		if len(translation.Options) == 0 {
+			for _, option := range question.Options {
+				options[strings.ToLower(strings.TrimSpace(option))] = true
+			}
+		}
+		for option := range translation.OptionFeedback {
+			if !options[strings.ToLower(strings.TrimSpace(option))] {
+				errorStrings = append(errorStrings, fmt.Sprintf("translation %q has feedback for %q, which is not one of its options", locale, option))
+			}
+		}
+		translations[locale] = translation
+	}
+	question.Translations = translations
+	return errorStrings
+}
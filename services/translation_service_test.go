@@ -0,0 +1,97 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+)
+
+func translatedQuestion() models.Question {
+	return models.Question{
+		QuestionName:   "Which river is considered the holiest in India?",
+		Options:        []string{"Yamuna", "Ganga"},
+		CorrectAnswer:  "ganga",
+		Distractors:    []string{"Yamuna"},
+		Explanation:    "The Ganga is sacred in Hinduism.",
+		OptionFeedback: map[string]string{"Yamuna": "It joins the Ganga at Prayagraj."},
+		Translations: models.Translations{
+			"hi": {
+				Question: "भारत में सबसे पवित्र नदी कौन सी मानी जाती है?",
+				Options:  []string{"यमुना", "गंगा"},
+			},
+		},
+	}
+}
+
+func TestLocalizeQuestion(t *testing.T) {
+	// Test case: Options, answers and feedback follow the translation
+	question := translatedQuestion()
+	LocalizeQuestion(&question, "hi-IN")
+	assert.Equal(t, "hi", question.Locale)
+	assert.Equal(t, "भारत में सबसे पवित्र नदी कौन सी मानी जाती है?", question.QuestionName)
+	assert.Equal(t, []string{"यमुना", "गंगा"}, question.Options)
+	assert.Equal(t, "गंगा", question.CorrectAnswer)
+	assert.Equal(t, []string{"यमुना"}, question.Distractors)
+	assert.Equal(t, map[string]string{"यमुना": "It joins the Ganga at Prayagraj."}, question.OptionFeedback)
+	assert.Nil(t, question.Translations)
+
+	// Test case: Missing parts of a translation stay in the original language
+	assert.Equal(t, "The Ganga is sacred in Hinduism.", question.Explanation)
+
+	// Test case: Languages without a translation fall back to the original
+	question = translatedQuestion()
+	LocalizeQuestion(&question, "fr")
+	assert.Equal(t, "", question.Locale)
+	assert.Equal(t, []string{"Yamuna", "Ganga"}, question.Options)
+	assert.Nil(t, question.Translations)
+}
+
+func TestLocalizeAnswerKey(t *testing.T) {
+	key := models.QuestionUnmarshal{
+		Options:       []string{"Yamuna", "Ganga"},
+		CorrectAnswer: "Ganga",
+		Translations:  models.Translations{"hi": {Question: "?", Options: []string{"यमुना", "गंगा"}}},
+	}
+
+	// Test case: Localized snapshots are re-graded in their language
+	answer, answers := LocalizeAnswerKey(key, "hi")
+	assert.Equal(t, "गंगा", answer)
+	assert.Nil(t, answers)
+
+	// Test case: Snapshots in the original language keep the key
+	answer, _ = LocalizeAnswerKey(key, "")
+	assert.Equal(t, "Ganga", answer)
+}
+
+func TestValidateTranslations(t *testing.T) {
+	// Test case: Locales are normalized
+	question := models.QuestionUnmarshal{
+		QuestionName:  "Pick one",
+		Options:       []string{"a", "b"},
+		CorrectAnswer: "a",
+		Translations:  models.Translations{"hi_IN": {Question: " एक चुनें ", Options: []string{"क", "ख"}}},
+	}
+	assert.Empty(t, ValidateQuestion(&question))
+	assert.Equal(t, models.Translations{"hi-in": {Question: "एक चुनें", Options: []string{"क", "ख"}}}, question.Translations)
+
+	// Test case: Translations must line up with the options
+	question.Translations = models.Translations{
+		"HI":      {Question: "एक चुनें", Options: []string{"क"}, OptionFeedback: map[string]string{"ग": "?"}},
+		"hi":      {Question: "एक चुनें"},
+		"english": {Question: "Pick one"},
+		"mr":      {Options: []string{"क", "क"}},
+	}
+	assert.ElementsMatch(t, []string{
+		`translation locale "english" should be a language tag such as hi or hi-IN`,
+		`translation locale "hi" is repeated`,
+		`translation "hi" should have 2 options`,
+		`translation "hi" has feedback for "ग", which is not one of its options`,
+		`translation "mr" should have a question`,
+		`translation "mr" repeats option "क"`,
+	}, ValidateQuestion(&question))
+}
+
+func TestPreferredLocale(t *testing.T) {
+	assert.Equal(t, "hi-in", PreferredLocale("en;q=0.5, hi-IN"))
+	assert.Equal(t, "", PreferredLocale(""))
+}
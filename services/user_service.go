@@ -45,6 +45,34 @@ func UserExists(username string) (bool, error) {
 	return true, nil
 }
 
+// SetUserRoles replaces the content roles held by a user. Changes apply from
+// the user's next login, when a new token is issued.
+func SetUserRoles(ctx context.Context, username string, roles []string) (bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "users")
+	res, err := collection.UpdateOne(ctx, bson.M{"username": username}, bson.M{"$set": bson.M{"roles": roles}})
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount != 0, nil
+}
+
+// SetUserLocale stores the language a user prefers for quizzes. Like roles,
+// it applies from the user's next login.
+func SetUserLocale(ctx context.Context, username string, locale string) (bool, error) {
+	client := GetConnection()
+	collection := GetCollection(client, "users")
+	update := bson.M{"$set": bson.M{"locale": locale}}
+	if locale == "" {
+		update = bson.M{"$unset": bson.M{"locale": ""}}
+	}
+	res, err := collection.UpdateOne(ctx, bson.M{"username": username}, update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount != 0, nil
+}
+
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
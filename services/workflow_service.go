@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/zeekhoks/quiz-backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"time"
+)
+
+const (
+	WorkflowSubmit  = "submit"
+	WorkflowApprove = "approve"
+	WorkflowReject  = "reject"
+	WorkflowRetire  = "retire"
+	WorkflowPublish = "publish"
+	WorkflowEdit    = "edit"
+)
+
+// ErrInvalidTransition is returned when a workflow action does not apply to
+// the current status of a question.
+var ErrInvalidTransition = errors.New("action is not allowed in the question's current status")
+
+// workflowTransitions maps each action to the statuses it may start from and
+// the status it leads to.
+var workflowTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	WorkflowSubmit:  {from: []string{models.QuestionStatusDraft, models.QuestionStatusRejected}, to: models.QuestionStatusInReview},
+	WorkflowApprove: {from: []string{models.QuestionStatusInReview}, to: models.QuestionStatusPublished},
+	WorkflowReject:  {from: []string{models.QuestionStatusInReview}, to: models.QuestionStatusRejected},
+	WorkflowRetire:  {from: []string{models.QuestionStatusPublished}, to: models.QuestionStatusRetired},
+	WorkflowPublish: {from: []string{models.QuestionStatusDraft, models.QuestionStatusInReview, models.QuestionStatusRejected, models.QuestionStatusRetired}, to: models.QuestionStatusPublished},
+	WorkflowEdit:    {from: []string{models.QuestionStatusRejected}, to: models.QuestionStatusDraft},
+}
+
+// AuthorCanEdit reports whether the author of a question may still edit it.
+// Once it is submitted only admins can change it.
+func AuthorCanEdit(question models.QuestionUnmarshal) bool {
+	status := QuestionStatus(question)
+	return status == models.QuestionStatusDraft || status == models.QuestionStatusRejected
+}
+
+// QuestionStatus returns the workflow status of a question. Questions
+// uploaded before the workflow existed count as published.
+func QuestionStatus(question models.QuestionUnmarshal) string {
+	if question.Status == "" {
+		return models.QuestionStatusPublished
+	}
+	return question.Status
+}
+
+// PublishedStatus is the status condition for questions that may be used in
+// new quizzes.
+func PublishedStatus() bson.M {
+	return bson.M{"$in": bson.A{models.QuestionStatusPublished, nil}}
+}
+
+// NextQuestionStatus returns the status a question in status from moves to
+// when the action is applied, and whether the action is allowed at all.
+func NextQuestionStatus(from, action string) (string, bool) {
+	transition, ok := workflowTransitions[action]
+	if !ok {
+		return "", false
+	}
+	for _, status := range transition.from {
+		if status == from {
+			return transition.to, true
+		}
+	}
+	return "", false
+}
+
+// TransitionQuestion applies a workflow action to a question and appends it
+// to the question's review log. The update only applies if the status has
+// not changed in the meantime.
+func TransitionQuestion(ctx context.Context, question *models.QuestionUnmarshal, action, by, comment string) error {
+	from := QuestionStatus(*question)
+	to, ok := NextQuestionStatus(from, action)
+	if !ok {
+		return ErrInvalidTransition
+	}
+
+	event := models.ReviewEvent{
+		Action:  action,
+		From:    from,
+		To:      to,
+		By:      by,
+		Comment: comment,
+		At:      time.Now(),
+	}
+
+	filter := bson.M{"_id": question.ID, "status": question.Status}
+	if question.Status == "" {
+		filter["status"] = bson.M{"$exists": false}
+	}
+
+	client := GetConnection()
+	collection := GetCollection(client, "questions")
+	res, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$set":  bson.M{"status": to},
+		"$push": bson.M{"review_log": event},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrInvalidTransition
+	}
+
+	question.Status = to
+	question.ReviewLog = append(question.ReviewLog, event)
+	return nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zeekhoks/quiz-backend/models"
+	"testing"
+)
+
+func TestNextQuestionStatus(t *testing.T) {
+	to, ok := NextQuestionStatus(models.QuestionStatusDraft, WorkflowSubmit)
+	assert.True(t, ok)
+	assert.Equal(t, models.QuestionStatusInReview, to)
+
+	to, ok = NextQuestionStatus(models.QuestionStatusInReview, WorkflowApprove)
+	assert.True(t, ok)
+	assert.Equal(t, models.QuestionStatusPublished, to)
+
+	// Test case: rejected questions can be resubmitted after changes
+	to, ok = NextQuestionStatus(models.QuestionStatusRejected, WorkflowSubmit)
+	assert.True(t, ok)
+	assert.Equal(t, models.QuestionStatusInReview, to)
+
+	// Test case: drafts can't be approved without going through review
+	_, ok = NextQuestionStatus(models.QuestionStatusDraft, WorkflowApprove)
+	assert.False(t, ok)
+
+	// Test case: retired questions stay retired unless an admin republishes
+	_, ok = NextQuestionStatus(models.QuestionStatusRetired, WorkflowSubmit)
+	assert.False(t, ok)
+
+	// Test case: editing a rejected question moves it back to draft
+	to, ok = NextQuestionStatus(models.QuestionStatusRejected, WorkflowEdit)
+	assert.True(t, ok)
+	assert.Equal(t, models.QuestionStatusDraft, to)
+
+	_, ok = NextQuestionStatus(models.QuestionStatusPublished, WorkflowEdit)
+	assert.False(t, ok)
+
+	_, ok = NextQuestionStatus(models.QuestionStatusDraft, "delete")
+	assert.False(t, ok)
+}
+
+func TestAuthorCanEdit(t *testing.T) {
+	assert.True(t, AuthorCanEdit(models.QuestionUnmarshal{Status: models.QuestionStatusDraft}))
+	assert.True(t, AuthorCanEdit(models.QuestionUnmarshal{Status: models.QuestionStatusRejected}))
+	assert.False(t, AuthorCanEdit(models.QuestionUnmarshal{Status: models.QuestionStatusInReview}))
+	// Test case: questions uploaded before the workflow count as published
+	assert.False(t, AuthorCanEdit(models.QuestionUnmarshal{}))
+}
+
+func TestQuestionStatus(t *testing.T) {
+	// Test case: questions uploaded before the workflow count as published
+	assert.Equal(t, models.QuestionStatusPublished, QuestionStatus(models.QuestionUnmarshal{}))
+	assert.Equal(t, models.QuestionStatusDraft, QuestionStatus(models.QuestionUnmarshal{Status: models.QuestionStatusDraft}))
+}